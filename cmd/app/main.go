@@ -2,38 +2,147 @@ package main
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
 	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/eliassebastian/r6index-recommendation/internal/audit"
+	"github.com/eliassebastian/r6index-recommendation/internal/auth"
+	"github.com/eliassebastian/r6index-recommendation/internal/calibration"
+	"github.com/eliassebastian/r6index-recommendation/internal/exclusion"
+	"github.com/eliassebastian/r6index-recommendation/internal/logging"
+	"github.com/eliassebastian/r6index-recommendation/internal/ratelimit"
+	"github.com/eliassebastian/r6index-recommendation/internal/resilient"
 	"github.com/eliassebastian/r6index-recommendation/internal/server"
+	"github.com/eliassebastian/r6index-recommendation/internal/statistics"
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+	"github.com/eliassebastian/r6index-recommendation/internal/tenant"
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
+	"github.com/eliassebastian/r6index-recommendation/internal/weaviate"
 	pb "github.com/eliassebastian/r6index-recommendation/pkg/proto/server"
+	weaviateclient "github.com/weaviate/weaviate-go-client/v4/weaviate"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
+func getenv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+
+	return fallback
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
+}
+
 func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	log.Println("---Recommendation Service Starting---")
+	logger, err := logging.New(os.Stderr, getenv("LOG_LEVEL", "info"), getenv("LOG_FORMAT", "json"))
+	if err != nil {
+		fatal("configuring logging", err)
+	}
+	slog.SetDefault(logger)
+
+	slog.Info("recommendation service starting")
 
 	listener, err := net.Listen("tcp", ":50051")
 	if err != nil {
-		log.Fatalln(err)
+		fatal("listening", err)
+	}
+
+	client := weaviateclient.New(weaviateclient.Config{
+		Host:   getenv("WEAVIATE_HOST", "localhost:6464"),
+		Scheme: getenv("WEAVIATE_SCHEME", "http"),
+	})
+
+	// hedged nearest neighbour queries go to a replica when there is one
+	var replica *weaviateclient.Client
+	if host := getenv("WEAVIATE_REPLICA_HOST", ""); host != "" {
+		replica = weaviateclient.New(weaviateclient.Config{
+			Host:   host,
+			Scheme: getenv("WEAVIATE_SCHEME", "http"),
+		})
+	}
+
+	// a schema config picks other encodings or a refit normalization for new collections
+	// and re-indexes, tenants keep serving their stored schema until re-indexed
+	schemaConfig, err := vectors.LoadConfig(getenv("VECTOR_SCHEMA_PATH", "schema.json"))
+	if err != nil {
+		fatal("loading the vector schema", err)
+	}
+
+	if schemaConfig != nil {
+		schema, err := schemaConfig.Schema()
+		if err != nil {
+			fatal("deriving the vector schema", err)
+		}
+
+		if err := vectors.Register(schema); err != nil {
+			fatal("registering the vector schema", err)
+		}
+		vectors.Current = schema
+	}
+
+	auditLog, err := audit.Open(getenv("AUDIT_LOG_PATH", "audit.log"))
+	if err != nil {
+		fatal("opening the audit log", err)
+	}
+	defer auditLog.Close()
+
+	// the servers read and write through aliases, re-indexes switch them between
+	// versioned classes. Without a tenants file a single tenant serves every request
+	aliases := weaviate.NewAliases(client)
+	if err := aliases.Load(ctx); err != nil {
+		fatal("loading collection aliases", err)
+	}
+	// replicas switch when another one promotes or rolls back
+	go aliases.Run(ctx, 10*time.Second)
+
+	configs, err := tenant.Load(getenv("TENANTS_PATH", "tenants.json"), tenant.Configs{
+		Default: "default",
+		Tenants: []tenant.Config{{
+			Name:       "default",
+			Collection: getenv("WEAVIATE_COLLECTION", "Players"),
+			Class:      getenv("WEAVIATE_CLASS", "Player"),
+		}},
+	})
+	if err != nil {
+		fatal("loading tenants", err)
+	}
+
+	router, err := server.NewTenantRouter(configs, func(config tenant.Config) (*server.RecommendationServer, error) {
+		return openTenant(ctx, client, replica, aliases, auditLog, config, len(configs.Tenants) > 1)
+	})
+	if err != nil {
+		fatal("opening tenants", err)
+	}
+
+	options, err := serverOptions(ctx)
+	if err != nil {
+		fatal("configuring the gRPC server", err)
 	}
 
-	grpcServer := grpc.NewServer()
-	pb.RegisterRecommendationServiceServer(grpcServer, &server.RecommendationServer{})
+	grpcServer := grpc.NewServer(options...)
+	pb.RegisterRecommendationServiceServer(grpcServer, router)
 
 	wg := sync.WaitGroup{}
 	wg.Add(1)
 	go func() {
 		<-ctx.Done()
-		log.Printf("got signal %v, attempting graceful shutdown", ctx.Err())
+		slog.Info("attempting graceful shutdown", "reason", ctx.Err())
 		stop()
 
 		grpcServer.GracefulStop()
@@ -45,9 +154,195 @@ func main() {
 
 	err = grpcServer.Serve(listener)
 	if err != nil {
-		log.Fatalf("could not serve: %v", err)
+		fatal("serving", err)
 	}
 
 	wg.Wait()
-	log.Println("clean shutdown")
+	slog.Info("clean shutdown")
+}
+
+// serverOptions returns the TLS credentials and interceptors of the gRPC server. TLS
+// is on when TLS_CERT_PATH is set, mTLS when TLS_CLIENT_CA_PATH is too. Every request
+// is given an ID and logged, authenticated unless AUTH_DISABLED is true, then rate
+// limited
+func serverOptions(ctx context.Context) ([]grpc.ServerOption, error) {
+	var options []grpc.ServerOption
+	interceptors := []grpc.UnaryServerInterceptor{logging.UnaryServerInterceptor}
+
+	if certPath := getenv("TLS_CERT_PATH", ""); certPath != "" {
+		config, err := auth.TLSConfig(certPath, getenv("TLS_KEY_PATH", ""), getenv("TLS_CLIENT_CA_PATH", ""))
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, grpc.Creds(credentials.NewTLS(config)))
+	} else {
+		slog.Warn("TLS_CERT_PATH is not set, serving without TLS")
+	}
+
+	if getenv("AUTH_DISABLED", "false") == "true" {
+		slog.Warn("AUTH_DISABLED is true, every caller may call every method")
+	} else {
+		config, err := auth.Load(getenv("AUTH_PATH", "auth.json"))
+		if err != nil {
+			return nil, err
+		}
+
+		authenticator, err := auth.New(config, server.Permissions)
+		if err != nil {
+			return nil, err
+		}
+		interceptors = append(interceptors, authenticator.UnaryServerInterceptor)
+	}
+
+	// limits are keyed by the authenticated client and reloaded when the file changes
+	rateLimitPath := getenv("RATE_LIMIT_PATH", "ratelimit.json")
+	limits, err := ratelimit.Load(rateLimitPath)
+	if err != nil {
+		return nil, err
+	}
+
+	limiter := ratelimit.New(limits)
+	go limiter.Run(ctx, rateLimitPath, 10*time.Second)
+	interceptors = append(interceptors, limiter.UnaryServerInterceptor)
+
+	return append(options, grpc.ChainUnaryInterceptor(interceptors...)), nil
+}
+
+// openTenant returns the server of a tenant, reading and writing through the alias of
+// its collection. Files of several tenants are told apart by the tenant's name
+func openTenant(ctx context.Context, client, replica *weaviateclient.Client, aliases *weaviate.Aliases, auditLog *audit.Log, config tenant.Config, named bool) (*server.RecommendationServer, error) {
+	path := func(name string) string {
+		if !named {
+			return name
+		}
+		extension := filepath.Ext(name)
+		return strings.TrimSuffix(name, extension) + "." + config.Name + extension
+	}
+
+	class := config.Class
+	if class == "" {
+		class = weaviate.VersionedClass(config.Collection, vectors.Current.Version)
+	}
+
+	alias, err := aliases.Create(ctx, config.Collection, class, vectors.Current.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	// the class was created by an older release or by hand, bring it up to date
+	if err := weaviate.Migrate(ctx, client, weaviate.Class(alias.Class)); err != nil {
+		return nil, err
+	}
+
+	// the stored vectors keep being served with their schema, new ones only take over
+	// once a re-index to them is promoted
+	schema, ok := vectors.Schemas[alias.SchemaVersion]
+	if !ok {
+		return nil, fmt.Errorf("weaviate: alias %s serves unknown schema version %d", alias.Name, alias.SchemaVersion)
+	}
+
+	if schema.Version != vectors.Current.Version {
+		slog.Warn("tenant serves an older schema until it is re-indexed", "tenant", config.Name, "schema_version", schema.Version, "current_schema_version", vectors.Current.Version)
+	}
+	collection := aliases.Collection(alias.Name)
+
+	// refit the similarity scores from a sample of recently indexed players, seeded
+	// from the stored ones so scores keep their meaning across restarts
+	calibrator := calibration.New(500)
+
+	// feature distribution estimates survive restarts so normalization can be refit
+	statisticsPath := path(getenv("STATISTICS_PATH", "statistics.json"))
+	collector, err := statistics.Load(statisticsPath, schema)
+	if err != nil {
+		return nil, err
+	}
+	go collector.Run(ctx, statisticsPath, time.Minute)
+
+	// blocks and recent teammates are kept per tenant like the audit log, so they
+	// survive restarts
+	exclusions, err := exclusion.Open(path(getenv("EXCLUSIONS_PATH", "exclusions.log")), 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	maxBatchSize, maxBatchWait := 100, 5*time.Second
+	if config.MaxBatchSize > 0 {
+		maxBatchSize = config.MaxBatchSize
+	}
+	if config.MaxBatchWait.Duration > 0 {
+		maxBatchWait = config.MaxBatchWait.Duration
+	}
+
+	options := []server.Option{
+		server.WithCalibrator(calibrator),
+		server.WithStatistics(collector),
+		server.WithExclusions(exclusions),
+		server.WithReindex(ctx, collection, path(getenv("REINDEX_CHECKPOINT_PATH", "reindex.checkpoint"))),
+	}
+
+	// popular players are recommended over and over, their results are served from
+	// memory for up to RECOMMEND_CACHE_TTL. A size of 0 turns the cache off
+	cacheSize, err := strconv.Atoi(getenv("RECOMMEND_CACHE_SIZE", "10000"))
+	if err != nil {
+		return nil, fmt.Errorf("RECOMMEND_CACHE_SIZE: %w", err)
+	}
+
+	cacheTTL, err := time.ParseDuration(getenv("RECOMMEND_CACHE_TTL", "30s"))
+	if err != nil {
+		return nil, fmt.Errorf("RECOMMEND_CACHE_TTL: %w", err)
+	}
+
+	// weighted queries re-rank RECOMMEND_WEIGHTED_OVERFETCH nearest players per result
+	overfetch, err := strconv.Atoi(getenv("RECOMMEND_WEIGHTED_OVERFETCH", "4"))
+	if err != nil || overfetch < 1 {
+		return nil, fmt.Errorf("RECOMMEND_WEIGHTED_OVERFETCH: must be a positive number")
+	}
+	options = append(options, server.WithWeightedOverfetch(overfetch))
+
+	// page tokens are signed, replicas only accept each other's with a shared key
+	if key := getenv("PAGE_TOKEN_KEY", ""); key != "" {
+		options = append(options, server.WithPageTokenKey([]byte(key)))
+	} else {
+		slog.Warn("PAGE_TOKEN_KEY is not set, page tokens are only valid on this replica until it restarts")
+	}
+
+	if cacheSize > 0 {
+		options = append(options, server.WithRecommendationCache(cacheSize, cacheTTL, getenv("RECOMMEND_CACHE_COALESCE", "true") == "true"))
+	}
+
+	slog.Info("serving tenant", "tenant", config.Name, "collection", alias.Name, "class", alias.Class, "schema_version", alias.SchemaVersion)
+	players, err := resilientStore(collection.Store(), replica)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		if err := calibrator.Seed(ctx, players, schema.Version); err != nil {
+			slog.Warn("seeding score calibration", "tenant", config.Name, "err", err)
+		}
+		calibrator.Run(ctx, time.Minute)
+	}()
+	return server.NewRecommendationServer(players, auditLog, maxBatchSize, maxBatchWait, options...), nil
+}
+
+// resilientStore bounds every store call by STORE_READ_TIMEOUT or STORE_WRITE_TIMEOUT,
+// hedges slow queries to the replica when there is one, and fails fast while Weaviate
+// is degraded
+func resilientStore(primary *weaviate.Store, replica *weaviateclient.Client) (*resilient.Store, error) {
+	config := resilient.DefaultConfig()
+
+	var err error
+	if config.ReadTimeout, err = time.ParseDuration(getenv("STORE_READ_TIMEOUT", config.ReadTimeout.String())); err != nil {
+		return nil, fmt.Errorf("STORE_READ_TIMEOUT: %w", err)
+	}
+
+	if config.WriteTimeout, err = time.ParseDuration(getenv("STORE_WRITE_TIMEOUT", config.WriteTimeout.String())); err != nil {
+		return nil, fmt.Errorf("STORE_WRITE_TIMEOUT: %w", err)
+	}
+
+	var hedge store.Store
+	if replica != nil {
+		hedge = primary.WithClient(replica)
+	}
+	return resilient.New(primary, hedge, config), nil
 }
@@ -1,6 +1,6 @@
 module github.com/eliassebastian/r6index-recommendation
 
-go 1.20
+go 1.21
 
 require (
 	github.com/go-openapi/strfmt v0.21.3
@@ -0,0 +1,30 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	for _, content := range []string{`{"v":1}`, `{"v":2}`} {
+		if err := WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile() error = %v, want nil", err)
+		}
+
+		if got, err := os.ReadFile(path); err != nil || string(got) != content {
+			t.Errorf("os.ReadFile() = %s, %v, want %s", got, err, content)
+		}
+	}
+
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("os.Stat(tmp) error = %v, want the temporary file renamed", err)
+	}
+
+	// a missing directory fails without leaving anything behind
+	if err := WriteFile(filepath.Join(t.TempDir(), "missing", "state.json"), nil, 0o644); err == nil {
+		t.Errorf("WriteFile() error = nil, want an error for a missing directory")
+	}
+}
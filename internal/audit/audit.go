@@ -0,0 +1,170 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	ActionDelete  = "delete"
+	ActionErasure = "erasure"
+)
+
+// Entry is a single line of the audit log, a bulk request is a single entry of all
+// its players in PlayerIDs
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	PlayerID  string    `json:"player_id,omitempty"`
+	PlayerIDs []string  `json:"player_ids,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	// Collection is the logical collection a delete was made in, tenants share the log.
+	// An erasure applies to every collection
+	Collection string `json:"collection,omitempty"`
+}
+
+// Players returns the ids of the players the entry is about
+func (e Entry) Players() []string {
+	if e.PlayerID == "" {
+		return e.PlayerIDs
+	}
+	return append([]string{e.PlayerID}, e.PlayerIDs...)
+}
+
+// Log is an append-only JSON lines audit log, it also keeps the erasure tombstones
+// so a stale Index call can't re-create a player after a data-subject request
+type Log struct {
+	mutex      *sync.RWMutex
+	writer     io.Writer
+	tombstones map[string]time.Time
+	// path is the file the log was opened from, empty when it can't be read back
+	path string
+}
+
+func New(writer io.Writer) *Log {
+	return &Log{
+		mutex:      &sync.RWMutex{},
+		writer:     writer,
+		tombstones: make(map[string]time.Time),
+	}
+}
+
+// Open opens (or creates) the audit log at path and replays it to restore the tombstones
+func Open(path string) (*Log, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	log := New(file)
+	log.path = path
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		log.apply(entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return log, nil
+}
+
+// Record appends the entry to the log, erasures become tombstones once written
+func (l *Log) Record(entry Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if _, err := l.writer.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	l.apply(entry)
+	return nil
+}
+
+// Erased reports whether the player has an erasure tombstone
+func (l *Log) Erased(id string) bool {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	_, ok := l.tombstones[id]
+	return ok
+}
+
+// Deleted returns the players deleted from collection or erased since, in the order
+// they were recorded. A log that wasn't opened from a file has nothing to read back
+func (l *Log) Deleted(collection string, since time.Time) ([]string, error) {
+	if l.path == "" {
+		return nil, nil
+	}
+
+	// holding the lock keeps Record from appending a partial line while it's read
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var deleted []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+
+		if entry.Time.Before(since) {
+			continue
+		}
+
+		if entry.Action == ActionErasure || entry.Collection == collection {
+			deleted = append(deleted, entry.Players()...)
+		}
+	}
+
+	return deleted, scanner.Err()
+}
+
+func (l *Log) apply(entry Entry) {
+	if entry.Action != ActionErasure {
+		return
+	}
+
+	for _, id := range entry.Players() {
+		l.tombstones[id] = entry.Time
+	}
+}
+
+// Close closes the underlying writer if it needs closing
+func (l *Log) Close() error {
+	if closer, ok := l.writer.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
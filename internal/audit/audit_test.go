@@ -0,0 +1,125 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLogRecord(t *testing.T) {
+	var buffer bytes.Buffer
+	log := New(&buffer)
+
+	if err := log.Record(Entry{Action: ActionDelete, PlayerID: "6844b415-aa94-43c9-8823-9389e4816902"}); err != nil {
+		t.Fatalf("Log.Record() error = %v, want nil", err)
+	}
+
+	if err := log.Record(Entry{Action: ActionErasure, PlayerID: "460a3311-fe2f-489c-ba95-73370cbaddfa", Reason: "gdpr"}); err != nil {
+		t.Fatalf("Log.Record() error = %v, want nil", err)
+	}
+
+	if log.Erased("6844b415-aa94-43c9-8823-9389e4816902") {
+		t.Errorf("Log.Erased() = true for a plain delete, want false")
+	}
+
+	if !log.Erased("460a3311-fe2f-489c-ba95-73370cbaddfa") {
+		t.Errorf("Log.Erased() = false for an erasure, want true")
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(buffer.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("audit lines = %v, want %v", len(lines), 2)
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(lines[1], &entry); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v, want nil", err)
+	}
+
+	if entry.Reason != "gdpr" || entry.Time.IsZero() {
+		t.Errorf("audit entry = %+v, want reason and time set", entry)
+	}
+}
+
+func TestOpenReplaysTombstones(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	log, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v, want nil", err)
+	}
+
+	if err := log.Record(Entry{Action: ActionErasure, PlayerID: "460a3311-fe2f-489c-ba95-73370cbaddfa"}); err != nil {
+		t.Fatalf("Log.Record() error = %v, want nil", err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v, want nil", err)
+	}
+
+	if !reopened.Erased("460a3311-fe2f-489c-ba95-73370cbaddfa") {
+		t.Errorf("Log.Erased() = false after reopening, want true")
+	}
+}
+
+func TestOpenReplaysBulkTombstones(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	log, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v, want nil", err)
+	}
+
+	ids := []string{"460a3311-fe2f-489c-ba95-73370cbaddfa", "6844b415-aa94-43c9-8823-9389e4816918"}
+	if err := log.Record(Entry{Action: ActionErasure, PlayerIDs: ids}); err != nil {
+		t.Fatalf("Log.Record() error = %v, want nil", err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v, want nil", err)
+	}
+
+	for _, id := range ids {
+		if !reopened.Erased(id) {
+			t.Errorf("Log.Erased(%s) = false after reopening, want true", id)
+		}
+	}
+}
+
+func TestLogDeleted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	log, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v, want nil", err)
+	}
+
+	promoted := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Time: promoted.Add(-time.Hour), Action: ActionDelete, PlayerID: "before", Collection: "Players"},
+		{Time: promoted.Add(time.Hour), Action: ActionDelete, PlayerIDs: []string{"bulk-1", "bulk-2"}, Collection: "Players"},
+		{Time: promoted.Add(time.Hour), Action: ActionDelete, PlayerID: "other-tenant", Collection: "Ranked"},
+		{Time: promoted.Add(2 * time.Hour), Action: ActionErasure, PlayerID: "erased", Collection: "Ranked"},
+	}
+	for _, entry := range entries {
+		if err := log.Record(entry); err != nil {
+			t.Fatalf("Log.Record() error = %v, want nil", err)
+		}
+	}
+
+	// erasures apply to every collection, deletes only to their own
+	deleted, err := log.Deleted("Players", promoted)
+	if err != nil || !reflect.DeepEqual(deleted, []string{"bulk-1", "bulk-2", "erased"}) {
+		t.Errorf("Log.Deleted() = %v, %v, want [bulk-1 bulk-2 erased]", deleted, err)
+	}
+
+	if deleted, err := New(io.Discard).Deleted("Players", promoted); err != nil || deleted != nil {
+		t.Errorf("Log.Deleted() of an unopened log = %v, %v, want nothing", deleted, err)
+	}
+}
@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// metadata a client authenticates with, an API key or a bearer JWT
+const (
+	APIKeyMetadata        = "x-api-key"
+	AuthorizationMetadata = "authorization"
+)
+
+// Permission is what a client may do, a method requires one
+type Permission string
+
+const (
+	// Read is recommending and looking up players
+	Read Permission = "read"
+	// Index is writing players and what they asked for, like blocks
+	Index Permission = "index"
+	// Admin is operating the service, it grants every other permission
+	Admin Permission = "admin"
+)
+
+// Client is a known caller of the API
+type Client struct {
+	Name string `json:"name"`
+	// KeyHash is the hex SHA-256 of the client's API key, empty when it has none
+	KeyHash string `json:"keyHash,omitempty"`
+	// CommonName is the subject common name of the client's mTLS certificate, empty
+	// when it doesn't authenticate with one
+	CommonName  string       `json:"commonName,omitempty"`
+	Permissions []Permission `json:"permissions"`
+	// Tenants are the tenants the client may call, empty allows every tenant
+	Tenants []string `json:"tenants,omitempty"`
+}
+
+// Config are the clients allowed to call the API. JWTs signed with JWTSecret (HS256)
+// authenticate the client of their subject, carrying its permissions in a
+// permissions claim and its tenants in a tenants claim
+type Config struct {
+	Clients   []Client `json:"clients"`
+	JWTSecret string   `json:"jwtSecret,omitempty"`
+	JWTIssuer string   `json:"jwtIssuer,omitempty"`
+}
+
+// Load reads the config at path
+func Load(path string) (Config, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var config Config
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return Config{}, fmt.Errorf("auth: %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// HashKey returns the KeyHash of an API key
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticator authenticates the caller of every request and checks it has the
+// permission the method requires
+type Authenticator struct {
+	config  Config
+	methods map[string]Permission
+	now     func() time.Time
+}
+
+// New returns an authenticator of config. methods maps full method names to the
+// permission they require, methods not in it require Admin
+func New(config Config, methods map[string]Permission) (*Authenticator, error) {
+	names := make(map[string]struct{}, len(config.Clients))
+	for _, client := range config.Clients {
+		if client.Name == "" {
+			return nil, fmt.Errorf("auth: client without a name")
+		}
+
+		if _, ok := names[client.Name]; ok {
+			return nil, fmt.Errorf("auth: client %s is configured twice", client.Name)
+		}
+		names[client.Name] = struct{}{}
+
+		for _, permission := range client.Permissions {
+			if !permission.valid() {
+				return nil, fmt.Errorf("auth: client %s has unknown permission %q", client.Name, permission)
+			}
+		}
+	}
+
+	return &Authenticator{config: config, methods: methods, now: time.Now}, nil
+}
+
+func (p Permission) valid() bool {
+	return p == Read || p == Index || p == Admin
+}
+
+// principal is the client a request was authenticated as
+type principal struct {
+	name    string
+	tenants []string
+}
+
+type principalKey struct{}
+
+// ClientFromContext returns the name of the client a request was authenticated as,
+// empty when the server doesn't authenticate
+func ClientFromContext(ctx context.Context) string {
+	p, _ := ctx.Value(principalKey{}).(principal)
+	return p.name
+}
+
+// TenantAllowed reports whether the client a request was authenticated as may call
+// tenant, every request may when the server doesn't authenticate
+func TenantAllowed(ctx context.Context, tenant string) bool {
+	p, ok := ctx.Value(principalKey{}).(principal)
+	if !ok || len(p.tenants) == 0 {
+		return true
+	}
+
+	for _, allowed := range p.tenants {
+		if allowed == tenant {
+			return true
+		}
+	}
+	return false
+}
+
+// UnaryServerInterceptor rejects requests of unknown clients with 401 and of clients
+// without the method's permission with 403
+func (a *Authenticator) UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	p, permissions, err := a.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	required, ok := a.methods[info.FullMethod]
+	if !ok {
+		required = Admin
+	}
+
+	if !allowed(permissions, required) {
+		return nil, status.Error(403, fmt.Sprintf("%s = client %s lacks %s permission", info.FullMethod, p.name, required))
+	}
+
+	return handler(context.WithValue(ctx, principalKey{}, p), req)
+}
+
+func allowed(permissions []Permission, required Permission) bool {
+	for _, permission := range permissions {
+		if permission == required || permission == Admin {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticate returns the client a request is from and its permissions, by API key,
+// JWT or mTLS certificate in that order
+func (a *Authenticator) authenticate(ctx context.Context) (principal, []Permission, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if keys := md.Get(APIKeyMetadata); len(keys) > 0 {
+		hash := HashKey(keys[0])
+		for _, client := range a.config.Clients {
+			if client.KeyHash != "" && subtle.ConstantTimeCompare([]byte(client.KeyHash), []byte(hash)) == 1 {
+				return principal{name: client.Name, tenants: client.Tenants}, client.Permissions, nil
+			}
+		}
+		return principal{}, nil, status.Error(401, APIKeyMetadata+" = unknown API key")
+	}
+
+	if values := md.Get(AuthorizationMetadata); len(values) > 0 {
+		token, ok := strings.CutPrefix(values[0], "Bearer ")
+		if !ok || a.config.JWTSecret == "" {
+			return principal{}, nil, status.Error(401, AuthorizationMetadata+" = unsupported authorization")
+		}
+
+		claims, err := verifyJWT(token, []byte(a.config.JWTSecret), a.config.JWTIssuer, a.now())
+		if err != nil {
+			return principal{}, nil, status.Error(401, fmt.Sprintf("%s = %v", AuthorizationMetadata, err))
+		}
+		return principal{name: claims.Subject, tenants: claims.Tenants}, claims.Permissions, nil
+	}
+
+	if name := commonName(ctx); name != "" {
+		for _, client := range a.config.Clients {
+			if client.CommonName == name {
+				return principal{name: client.Name, tenants: client.Tenants}, client.Permissions, nil
+			}
+		}
+		return principal{}, nil, status.Error(401, fmt.Sprintf("certificate = unknown client %s", name))
+	}
+
+	return principal{}, nil, status.Error(401, "authorization = no credentials")
+}
+
+// commonName returns the common name of the request's verified client certificate
+func commonName(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return info.State.VerifiedChains[0][0].Subject.CommonName
+}
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const testSecret = "test-secret"
+
+var testMethods = map[string]Permission{
+	"/RecommendationService/Recommend": Read,
+	"/RecommendationService/Index":     Index,
+}
+
+func newTestAuthenticator(t *testing.T) *Authenticator {
+	authenticator, err := New(Config{
+		Clients: []Client{
+			{Name: "web", KeyHash: HashKey("web-key"), Permissions: []Permission{Read}},
+			{Name: "indexer", CommonName: "indexer.r6index", Permissions: []Permission{Index}},
+			{Name: "ops", KeyHash: HashKey("ops-key"), Permissions: []Permission{Admin}},
+			{Name: "league", KeyHash: HashKey("league-key"), Permissions: []Permission{Read, Index}, Tenants: []string{"league"}},
+		},
+		JWTSecret: testSecret,
+		JWTIssuer: "r6index",
+	}, testMethods)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator.now = func() time.Time { return time.Unix(1_700_000_000, 0) }
+	return authenticator
+}
+
+func signJWT(t *testing.T, algorithm string, secret string, claims map[string]interface{}) string {
+	encode := func(v interface{}) string {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(raw)
+	}
+
+	unsigned := encode(map[string]string{"alg": algorithm, "typ": "JWT"}) + "." + encode(claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// withCertificate returns a context of a request over mTLS with a verified
+// certificate of commonName
+func withCertificate(commonName string) context.Context {
+	certificate := &x509.Certificate{Subject: pkix.Name{CommonName: commonName}}
+	return peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{certificate}}}},
+	})
+}
+
+func TestAuthenticator_UnaryServerInterceptor(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+
+	valid := map[string]interface{}{"sub": "mobile", "iss": "r6index", "exp": 1_700_000_600, "permissions": []string{"read"}}
+	expired := map[string]interface{}{"sub": "mobile", "iss": "r6index", "exp": 1_699_999_000, "permissions": []string{"read"}}
+	otherIssuer := map[string]interface{}{"sub": "mobile", "iss": "other", "exp": 1_700_000_600, "permissions": []string{"read"}}
+
+	bearer := func(token string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs(AuthorizationMetadata, "Bearer "+token))
+	}
+	apiKey := func(key string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs(APIKeyMetadata, key))
+	}
+
+	tests := []struct {
+		testName string
+		ctx      context.Context
+		method   string
+		client   string
+		code     codes.Code
+	}{
+		{"read key recommends", apiKey("web-key"), "/RecommendationService/Recommend", "web", codes.OK},
+		{"read key can't index", apiKey("web-key"), "/RecommendationService/Index", "", 403},
+		{"unknown key", apiKey("guess"), "/RecommendationService/Recommend", "", 401},
+		{"admin key indexes", apiKey("ops-key"), "/RecommendationService/Index", "ops", codes.OK},
+		{"admin key re-indexes", apiKey("ops-key"), "/RecommendationService/Reindex", "ops", codes.OK},
+		{"unknown methods are admin", apiKey("web-key"), "/RecommendationService/Reindex", "", 403},
+		{"no credentials", context.Background(), "/RecommendationService/Recommend", "", 401},
+		{"jwt", bearer(signJWT(t, "HS256", testSecret, valid)), "/RecommendationService/Recommend", "mobile", codes.OK},
+		{"jwt without permission", bearer(signJWT(t, "HS256", testSecret, valid)), "/RecommendationService/Index", "", 403},
+		{"expired jwt", bearer(signJWT(t, "HS256", testSecret, expired)), "/RecommendationService/Recommend", "", 401},
+		{"jwt of another issuer", bearer(signJWT(t, "HS256", testSecret, otherIssuer)), "/RecommendationService/Recommend", "", 401},
+		{"jwt signed with another secret", bearer(signJWT(t, "HS256", "guess", valid)), "/RecommendationService/Recommend", "", 401},
+		{"jwt of another algorithm", bearer(signJWT(t, "none", testSecret, valid)), "/RecommendationService/Recommend", "", 401},
+		{"client certificate", withCertificate("indexer.r6index"), "/RecommendationService/Index", "indexer", codes.OK},
+		{"unknown client certificate", withCertificate("someone"), "/RecommendationService/Index", "", 401},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			var client string
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				client = ClientFromContext(ctx)
+				return req, nil
+			}
+
+			_, err := authenticator.UnaryServerInterceptor(tt.ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if status.Code(err) != tt.code {
+				t.Fatalf("UnaryServerInterceptor() error = %v, want code %d", err, tt.code)
+			}
+
+			if client != tt.client {
+				t.Errorf("ClientFromContext() = %q, want %q", client, tt.client)
+			}
+		})
+	}
+}
+
+func TestTenantAllowed(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+	scoped := map[string]interface{}{"sub": "mobile", "iss": "r6index", "exp": 1_700_000_600, "permissions": []string{"read"}, "tenants": []string{"ranked"}}
+
+	tests := []struct {
+		testName string
+		ctx      context.Context
+		tenant   string
+		want     bool
+	}{
+		{"unscoped client", metadata.NewIncomingContext(context.Background(), metadata.Pairs(APIKeyMetadata, "ops-key")), "ranked", true},
+		{"client of the tenant", metadata.NewIncomingContext(context.Background(), metadata.Pairs(APIKeyMetadata, "league-key")), "league", true},
+		{"client of another tenant", metadata.NewIncomingContext(context.Background(), metadata.Pairs(APIKeyMetadata, "league-key")), "ranked", false},
+		{"jwt of the tenant", metadata.NewIncomingContext(context.Background(), metadata.Pairs(AuthorizationMetadata, "Bearer "+signJWT(t, "HS256", testSecret, scoped))), "ranked", true},
+		{"jwt of another tenant", metadata.NewIncomingContext(context.Background(), metadata.Pairs(AuthorizationMetadata, "Bearer "+signJWT(t, "HS256", testSecret, scoped))), "league", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			var allowed bool
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				allowed = TenantAllowed(ctx, tt.tenant)
+				return req, nil
+			}
+
+			if _, err := authenticator.UnaryServerInterceptor(tt.ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/RecommendationService/Recommend"}, handler); err != nil {
+				t.Fatalf("UnaryServerInterceptor() error = %v, want nil", err)
+			}
+
+			if allowed != tt.want {
+				t.Errorf("TenantAllowed(%q) = %v, want %v", tt.tenant, allowed, tt.want)
+			}
+		})
+	}
+
+	// a server that doesn't authenticate lets every request call every tenant
+	if !TenantAllowed(context.Background(), "league") {
+		t.Errorf("TenantAllowed() without authentication = false, want true")
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Config{Clients: []Client{{Name: "web", Permissions: []Permission{"write"}}}}, testMethods); err == nil {
+		t.Error("New() with an unknown permission error = nil, want an error")
+	}
+
+	if _, err := New(Config{Clients: []Client{{Name: "web"}, {Name: "web"}}}, testMethods); err == nil {
+		t.Error("New() with a duplicate client error = nil, want an error")
+	}
+}
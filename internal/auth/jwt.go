@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// claims are the JWT claims the server reads
+type claims struct {
+	Subject     string       `json:"sub"`
+	Issuer      string       `json:"iss"`
+	ExpiresAt   int64        `json:"exp"`
+	NotBefore   int64        `json:"nbf"`
+	Permissions []Permission `json:"permissions"`
+	Tenants     []string     `json:"tenants"`
+}
+
+// verifyJWT checks an HS256 token's signature, issuer and validity period and returns
+// its claims. Tokens must expire
+func verifyJWT(token string, secret []byte, issuer string, now time.Time) (*claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+
+	// the algorithm is fixed, a token can't choose a weaker one like none
+	if header.Algorithm != "HS256" {
+		return nil, errors.New("token must be signed with HS256")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, errors.New("invalid token signature")
+	}
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c.Subject == "":
+		return nil, errors.New("token has no subject")
+	case issuer != "" && c.Issuer != issuer:
+		return nil, errors.New("token has the wrong issuer")
+	case c.ExpiresAt == 0 || now.Unix() >= c.ExpiresAt:
+		return nil, errors.New("token has expired")
+	case c.NotBefore != 0 && now.Unix() < c.NotBefore:
+		return nil, errors.New("token isn't valid yet")
+	}
+
+	for _, permission := range c.Permissions {
+		if !permission.valid() {
+			return nil, errors.New("token has an unknown permission")
+		}
+	}
+
+	return &c, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errors.New("malformed token")
+	}
+
+	if err := json.Unmarshal(raw, v); err != nil {
+		return errors.New("malformed token")
+	}
+	return nil
+}
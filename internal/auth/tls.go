@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// TLSConfig returns the server's TLS config from a PEM certificate and key. With a
+// client CA bundle it is mTLS, clients must present a certificate the CA signed
+func TLSConfig(certPath, keyPath, clientCAPath string) (*tls.Config, error) {
+	certificate, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if clientCAPath == "" {
+		return config, nil
+	}
+
+	raw, err := os.ReadFile(clientCAPath)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(raw) {
+		return nil, fmt.Errorf("auth: no certificates in %s", clientCAPath)
+	}
+
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	return config, nil
+}
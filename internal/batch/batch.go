@@ -1,13 +1,29 @@
 package batch
 
 import (
-	"log"
+	"errors"
+	"log/slog"
 	"sync"
 	"time"
 )
 
 type BatchPipelineCallback func([]interface{}) error
 
+// RetryError is returned by a callback that wrote some of the items, only Items are
+// kept and retried with the next flush
+type RetryError struct {
+	Items []interface{}
+	Err   error
+}
+
+func (e *RetryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
 type BatchPipeline struct {
 	// ...
 	maxSize    int
@@ -42,24 +58,31 @@ func (bp *BatchPipeline) Add(data interface{}) {
 
 	// if the data pipeline is full or over limit (error running callback), execute the callback function
 	if len(bp.data) >= bp.maxSize {
-		err := bp.executeAndFlush()
-		if err != nil {
-			log.Println(err)
-		}
+		bp.executeAndFlush()
 	}
 }
 
-func (bp *BatchPipeline) executeAndFlush() error {
+func (bp *BatchPipeline) executeAndFlush() {
 	// ...
 	err := bp.executeFnc(bp.data)
 
-	// reset the data pipeline if execution was successful
-	if err == nil {
-		// flush the data pipeline and keep allocated memory
-		bp.data = bp.data[:0]
+	// the data is kept and retried with the next flush when execution fails, only
+	// what wasn't written when it says which
+	var retry *RetryError
+	if errors.As(err, &retry) {
+		slog.Warn("batch: flush failed, retrying with the next flush", "items", len(retry.Items), "err", err)
+		kept := append([]interface{}(nil), retry.Items...)
+		bp.data = append(bp.data[:0], kept...)
+		return
+	}
+
+	if err != nil {
+		slog.Warn("batch: flush failed, retrying with the next flush", "items", len(bp.data), "err", err)
+		return
 	}
 
-	return err
+	// flush the data pipeline and keep allocated memory
+	bp.data = bp.data[:0]
 }
 
 func (bp *BatchPipeline) flushAfterDeadline() {
@@ -69,12 +92,11 @@ func (bp *BatchPipeline) flushAfterDeadline() {
 	for {
 		select {
 		case <-timer.C:
-			log.Println("flushing after deadline passed")
 			bp.mutex.Lock()
-			err := bp.executeAndFlush()
-			if err != nil {
-				log.Println(err)
+			if len(bp.data) > 0 {
+				slog.Debug("batch: flushing after deadline passed", "items", len(bp.data))
 			}
+			bp.executeAndFlush()
 			bp.mutex.Unlock()
 			// reset the timer
 			timer.Reset(bp.maxWait)
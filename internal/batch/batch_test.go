@@ -1,6 +1,8 @@
 package batch
 
 import (
+	"errors"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -54,3 +56,24 @@ func TestBatchPipelineWithErrors(t *testing.T) {
 	pipeline.flushChan <- struct{}{}
 	<-pipeline.flushChan
 }
+
+func TestBatchPipelineRetryError(t *testing.T) {
+	failed := errors.New("unavailable")
+	pipeline := NewBatchPipeline(10, time.Hour, func(data []interface{}) error {
+		// the first item is written, the rest is retried
+		return &RetryError{Items: data[1:], Err: failed}
+	})
+
+	pipeline.Add("item1")
+	pipeline.Add("item2")
+	pipeline.Add("item3")
+
+	pipeline.mutex.Lock()
+	pipeline.executeAndFlush()
+	data := append([]interface{}(nil), pipeline.data...)
+	pipeline.mutex.Unlock()
+
+	if !reflect.DeepEqual(data, []interface{}{"item2", "item3"}) {
+		t.Errorf("BatchPipeline.data = %v, want the items to retry", data)
+	}
+}
@@ -0,0 +1,216 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Cache is an LRU cache whose entries expire after a TTL. Entries belong to a group,
+// e.g. the player a result is for, which is invalidated as a whole
+type Cache[K comparable, V any] struct {
+	mutex    sync.Mutex
+	capacity int
+	ttl      time.Duration
+	coalesce bool
+	// loadTimeout bounds shared loads, which don't end with any caller's context
+	loadTimeout time.Duration
+	// entries are ordered by last use, most recent first
+	entries *list.List
+	items   map[K]*list.Element
+	groups  map[string]map[K]struct{}
+	// loading are the loads in progress, calls those shared when coalescing
+	loading map[*call[V]]struct{}
+	calls   map[K]*call[V]
+	now     func() time.Time
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	group   string
+	value   V
+	expires time.Time
+}
+
+// call is a load in progress, stale once its group was invalidated while it ran
+type call[V any] struct {
+	group string
+	done  chan struct{}
+	value V
+	err   error
+	stale bool
+}
+
+// New returns a cache of up to capacity entries, each kept for at most ttl. When
+// coalesce is set concurrent loads of the same key are made once and shared, each
+// running for at most loadTimeout
+func New[K comparable, V any](capacity int, ttl time.Duration, coalesce bool, loadTimeout time.Duration) *Cache[K, V] {
+	return &Cache[K, V]{
+		capacity:    capacity,
+		ttl:         ttl,
+		coalesce:    coalesce,
+		loadTimeout: loadTimeout,
+		entries:     list.New(),
+		items:       map[K]*list.Element{},
+		groups:      map[string]map[K]struct{}{},
+		loading:     map[*call[V]]struct{}{},
+		calls:       map[K]*call[V]{},
+		now:         time.Now,
+	}
+}
+
+// Get returns the cached value of key, or loads, caches and returns it. Errors aren't
+// cached. A shared load runs with ctx's values but not its cancellation, every caller
+// stops waiting when its own ctx is done while the load goes on for the others
+func (c *Cache[K, V]) Get(ctx context.Context, key K, group string, load func(context.Context) (V, error)) (V, error) {
+	c.mutex.Lock()
+	// expired entries are kept until they are loaded again, for Stale
+	if element, ok := c.items[key]; ok {
+		e := element.Value.(*entry[K, V])
+		if c.now().Before(e.expires) {
+			c.entries.MoveToFront(element)
+			c.mutex.Unlock()
+			return e.value, nil
+		}
+	}
+
+	if cl, ok := c.calls[key]; ok && c.coalesce {
+		c.mutex.Unlock()
+		return wait(ctx, cl)
+	}
+
+	cl := &call[V]{group: group, done: make(chan struct{})}
+	c.loading[cl] = struct{}{}
+	if c.coalesce {
+		c.calls[key] = cl
+	}
+	c.mutex.Unlock()
+
+	if !c.coalesce {
+		value, err := load(ctx)
+		c.finish(key, cl, value, err)
+		return value, err
+	}
+
+	go func() {
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.loadTimeout)
+		defer cancel()
+
+		value, err := load(loadCtx)
+		c.finish(key, cl, value, err)
+	}()
+	return wait(ctx, cl)
+}
+
+// wait returns the result of cl, or ctx's error when ctx is done first
+func wait[V any](ctx context.Context, cl *call[V]) (V, error) {
+	select {
+	case <-cl.done:
+		return cl.value, cl.err
+	case <-ctx.Done():
+		var empty V
+		return empty, ctx.Err()
+	}
+}
+
+// finish records the result of cl and caches it
+func (c *Cache[K, V]) finish(key K, cl *call[V], value V, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cl.value, cl.err = value, err
+	delete(c.loading, cl)
+	if c.coalesce {
+		delete(c.calls, key)
+	}
+	close(cl.done)
+
+	// a load racing an invalidation of its group may have read what was invalidated
+	if err == nil && !cl.stale {
+		c.add(key, cl.group, value)
+	}
+}
+
+func (c *Cache[K, V]) add(key K, group string, value V) {
+	if element, ok := c.items[key]; ok {
+		c.remove(element)
+	}
+
+	c.items[key] = c.entries.PushFront(&entry[K, V]{key: key, group: group, value: value, expires: c.now().Add(c.ttl)})
+	if c.groups[group] == nil {
+		c.groups[group] = map[K]struct{}{}
+	}
+	c.groups[group][key] = struct{}{}
+
+	for c.entries.Len() > c.capacity {
+		c.remove(c.entries.Back())
+	}
+}
+
+func (c *Cache[K, V]) remove(element *list.Element) {
+	e := c.entries.Remove(element).(*entry[K, V])
+	delete(c.items, e.key)
+
+	delete(c.groups[e.group], e.key)
+	if len(c.groups[e.group]) == 0 {
+		delete(c.groups, e.group)
+	}
+}
+
+// Stale returns the value of key even when it has expired, as long as it was cached
+// no more than maxAge ago. Invalidated values are never returned
+func (c *Cache[K, V]) Stale(key K, maxAge time.Duration) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		e := element.Value.(*entry[K, V])
+		if c.now().Sub(e.expires.Add(-c.ttl)) <= maxAge {
+			return e.value, true
+		}
+	}
+
+	var empty V
+	return empty, false
+}
+
+// Invalidate removes every entry of the groups, loads of them in progress aren't cached
+func (c *Cache[K, V]) Invalidate(groups ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, group := range groups {
+		for key := range c.groups[group] {
+			c.remove(c.items[key])
+		}
+	}
+
+	for cl := range c.loading {
+		for _, group := range groups {
+			if cl.group == group {
+				cl.stale = true
+			}
+		}
+	}
+}
+
+// Purge removes every entry, loads in progress aren't cached
+func (c *Cache[K, V]) Purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.entries.Init()
+	c.items = map[K]*list.Element{}
+	c.groups = map[string]map[K]struct{}{}
+	for cl := range c.loading {
+		cl.stale = true
+	}
+}
+
+// Len returns the number of cached entries, expired ones included until they're evicted
+func (c *Cache[K, V]) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.entries.Len()
+}
@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// loader counts its loads, each returns the load's number
+type loader struct {
+	loads atomic.Int64
+}
+
+func (l *loader) load(context.Context) (int64, error) {
+	return l.loads.Add(1), nil
+}
+
+func TestCacheGet(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+	c := New[string, int64](2, time.Minute, false, time.Minute)
+	c.now = func() time.Time { return now }
+
+	var l loader
+	get := func(key string) int64 {
+		value, err := c.Get(ctx, key, "player", l.load)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return value
+	}
+
+	if get("a") != 1 || get("a") != 1 {
+		t.Fatalf("Get() loaded %d times, want once", l.loads.Load())
+	}
+
+	// b and c evict a, the least recently used
+	get("b")
+	get("a")
+	get("c")
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", c.Len())
+	}
+
+	if got := get("b"); got != 4 {
+		t.Errorf("Get() of the evicted key = %d, want a new load", got)
+	}
+
+	now = now.Add(time.Minute)
+	if got := get("b"); got != 5 {
+		t.Errorf("Get() of an expired key = %d, want a new load", got)
+	}
+
+	failing := func(context.Context) (int64, error) { return 0, errors.New("store unavailable") }
+	if _, err := c.Get(ctx, "d", "player", failing); err == nil {
+		t.Fatal("Get() error = nil, want the load's error")
+	}
+	if got := get("d"); got != 6 {
+		t.Errorf("Get() after a failed load = %d, want a new load", got)
+	}
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	ctx := context.Background()
+	c := New[string, int64](10, time.Minute, false, time.Minute)
+
+	var l loader
+	c.Get(ctx, "a/limit-5", "a", l.load)
+	c.Get(ctx, "a/limit-10", "a", l.load)
+	c.Get(ctx, "b/limit-5", "b", l.load)
+
+	c.Invalidate("a")
+	if c.Len() != 1 {
+		t.Errorf("Len() after Invalidate() = %d, want 1", c.Len())
+	}
+
+	// a load racing the invalidation isn't cached
+	c.Get(ctx, "a/limit-5", "a", func(ctx context.Context) (int64, error) {
+		c.Invalidate("a")
+		return l.load(ctx)
+	})
+	if c.Len() != 1 {
+		t.Errorf("Len() after a load raced Invalidate() = %d, want 1", c.Len())
+	}
+
+	c.Purge()
+	if c.Len() != 0 {
+		t.Errorf("Len() after Purge() = %d, want 0", c.Len())
+	}
+}
+
+func TestCacheCoalesce(t *testing.T) {
+	ctx := context.Background()
+	c := New[string, int64](10, time.Minute, true, time.Minute)
+
+	var l loader
+	release := make(chan struct{})
+	started := make(chan struct{})
+	load := func(ctx context.Context) (int64, error) {
+		close(started)
+		<-release
+		return l.load(ctx)
+	}
+
+	var wg sync.WaitGroup
+	values := make([]int64, 5)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		values[0], _ = c.Get(ctx, "a", "a", load)
+	}()
+	<-started
+
+	for i := 1; i < len(values); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			values[i], _ = c.Get(ctx, "a", "a", load)
+		}(i)
+	}
+
+	// a waiter that gives up doesn't wait for the load
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := c.Get(canceled, "a", "a", load); !errors.Is(err, context.Canceled) {
+		t.Errorf("Get() with a canceled context error = %v, want context.Canceled", err)
+	}
+
+	close(release)
+	wg.Wait()
+
+	if l.loads.Load() != 1 {
+		t.Errorf("loads = %d, want 1", l.loads.Load())
+	}
+
+	for i, value := range values {
+		if value != 1 {
+			t.Errorf("Get() %d = %d, want the shared load's 1", i, value)
+		}
+	}
+}
+
+func TestCacheCoalesceOutlivesCaller(t *testing.T) {
+	c := New[string, int64](10, time.Minute, true, time.Minute)
+
+	var l loader
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var loadErr error
+	load := func(ctx context.Context) (int64, error) {
+		close(started)
+		<-release
+		loadErr = ctx.Err()
+		return l.load(ctx)
+	}
+
+	// the caller starting the load gives up, the one waiting on it still gets the value
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error)
+	go func() {
+		_, err := c.Get(first, "a", "a", load)
+		firstErr <- err
+	}()
+	<-started
+
+	waiter := make(chan int64)
+	go func() {
+		value, _ := c.Get(context.Background(), "a", "a", load)
+		waiter <- value
+	}()
+
+	cancel()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("Get() of the canceled caller error = %v, want context.Canceled", err)
+	}
+
+	close(release)
+	if value := <-waiter; value != 1 {
+		t.Errorf("Get() of the waiter = %d, want the shared load's 1", value)
+	}
+
+	if loadErr != nil {
+		t.Errorf("shared load context error = %v, want nil", loadErr)
+	}
+}
+
+func TestCacheCoalesceTimeout(t *testing.T) {
+	c := New[string, int64](10, time.Minute, true, time.Millisecond)
+
+	load := func(ctx context.Context) (int64, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+
+	if _, err := c.Get(context.Background(), "a", "a", load); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Get() of a load running past its timeout error = %v, want context.DeadlineExceeded", err)
+	}
+}
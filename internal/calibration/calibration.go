@@ -0,0 +1,252 @@
+package calibration
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
+)
+
+const (
+	// number of reference distances kept after a fit
+	quantiles = 1000
+	// weightings fitted since the last fit, beyond it they are fitted again from scratch
+	maxWeightings = 64
+)
+
+// Calibrator turns raw l2-squared distances into a 0-100 similarity score. It keeps a
+// reservoir sample of indexed vectors, one per player, and periodically fits the
+// distribution of distances between random pairs of them, a score of 90 then means
+// the two players are closer than 90% of random pairs in the indexed population
+type Calibrator struct {
+	mutex     *sync.RWMutex
+	size      int
+	seen      int
+	reservoir []sample
+	// slots[id] is the reservoir index of the player's vector
+	slots     map[string]int
+	reference []float32
+	// weighted are the references of weighted distances, keyed by weighting
+	weighted map[string][]float32
+	random   *rand.Rand
+}
+
+type sample struct {
+	id     string
+	vector []float32
+}
+
+func New(size int) *Calibrator {
+	return &Calibrator{
+		mutex:     &sync.RWMutex{},
+		size:      size,
+		reservoir: make([]sample, 0, size),
+		slots:     make(map[string]int, size),
+		weighted:  make(map[string][]float32),
+		random:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Observe offers an indexed player's vector to the reservoir sample, a player already
+// in it has their vector replaced
+func (c *Calibrator) Observe(id string, vector []float32) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if i, ok := c.slots[id]; ok {
+		c.reservoir[i].vector = vector
+		return
+	}
+
+	c.seen++
+	if len(c.reservoir) < c.size {
+		c.slots[id] = len(c.reservoir)
+		c.reservoir = append(c.reservoir, sample{id, vector})
+		return
+	}
+
+	if i := c.random.Intn(c.seen); i < c.size {
+		delete(c.slots, c.reservoir[i].id)
+		c.slots[id] = i
+		c.reservoir[i] = sample{id, vector}
+	}
+}
+
+// Forget drops deleted players from the reservoir sample
+func (c *Calibrator) Forget(ids ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, id := range ids {
+		i, ok := c.slots[id]
+		if !ok {
+			continue
+		}
+
+		// the last sample takes the forgotten one's place
+		last := len(c.reservoir) - 1
+		c.reservoir[i] = c.reservoir[last]
+		c.slots[c.reservoir[i].id] = i
+		c.reservoir = c.reservoir[:last]
+		delete(c.slots, id)
+	}
+}
+
+// Seed fills the reservoir with up to its size of the stored players of schemaVersion
+// and fits it, so scores mean the same right after a restart. Players are scanned in
+// id order, which is random for UUIDs
+func (c *Calibrator) Seed(ctx context.Context, players store.Store, schemaVersion int) error {
+	var cursor string
+	for seeded := 0; seeded < c.size; {
+		page, next, err := players.Scan(ctx, cursor, c.size)
+		if err != nil {
+			return err
+		}
+
+		for _, player := range page {
+			if player.SchemaVersion == schemaVersion {
+				c.Observe(player.ID, player.Vector)
+				seeded++
+			}
+		}
+
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	c.Fit()
+	return nil
+}
+
+// Fit refits the reference distribution from the current sample
+func (c *Calibrator) Fit() {
+	reference := fit(c.sample(), l2Squared)
+
+	c.mutex.Lock()
+	c.reference = reference
+	c.weighted = make(map[string][]float32)
+	c.mutex.Unlock()
+}
+
+// Run refits every interval until ctx is done
+func (c *Calibrator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.Fit()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Score maps a distance onto 0-100, higher is more similar. Before the first fit it
+// falls back to 100 / (1 + distance)
+func (c *Calibrator) Score(distance float32) float32 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return score(c.reference, distance)
+}
+
+// Scorer returns Score for distances weighted by weights, which are scored against
+// the distribution of weighted distances between the same sample. nil weights are
+// scored by Score
+func (c *Calibrator) Scorer(weights vectors.Weights) func(float32) float32 {
+	if weights == nil {
+		return c.Score
+	}
+
+	key := fmt.Sprint(weights)
+
+	c.mutex.RLock()
+	reference, ok := c.weighted[key]
+	fitted := len(c.reference) > 0
+	c.mutex.RUnlock()
+
+	// the fallback curve until the first fit, like unweighted distances
+	if !ok && fitted {
+		reference = fit(c.sample(), weights.Distance)
+
+		c.mutex.Lock()
+		if len(c.weighted) >= maxWeightings {
+			c.weighted = make(map[string][]float32)
+		}
+		c.weighted[key] = reference
+		c.mutex.Unlock()
+	}
+
+	return func(distance float32) float32 {
+		return score(reference, distance)
+	}
+}
+
+func (c *Calibrator) sample() [][]float32 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	vectors := make([][]float32, len(c.reservoir))
+	for i, s := range c.reservoir {
+		vectors[i] = s.vector
+	}
+	return vectors
+}
+
+// fit returns evenly spaced quantiles of the distances between every pair of sample
+func fit(sample [][]float32, distance func(a, b []float32) float32) []float32 {
+	if len(sample) < 2 {
+		return nil
+	}
+
+	distances := make([]float32, 0, len(sample)*(len(sample)-1)/2)
+	for i := range sample {
+		for j := i + 1; j < len(sample); j++ {
+			distances = append(distances, distance(sample[i], sample[j]))
+		}
+	}
+
+	sort.Slice(distances, func(i, j int) bool { return distances[i] < distances[j] })
+
+	// keep evenly spaced quantiles instead of every pair
+	if len(distances) <= quantiles {
+		return distances
+	}
+
+	reference := make([]float32, quantiles)
+	for i := range reference {
+		reference[i] = distances[i*(len(distances)-1)/(quantiles-1)]
+	}
+	return reference
+}
+
+func score(reference []float32, distance float32) float32 {
+	if len(reference) == 0 {
+		return 100 / (1 + distance)
+	}
+
+	// fraction of reference distances that are smaller than distance
+	below := sort.Search(len(reference), func(i int) bool { return reference[i] >= distance })
+	return 100 * float32(len(reference)-below) / float32(len(reference))
+}
+
+func l2Squared(a, b []float32) float32 {
+	var distance float32
+	for i := range a {
+		if i >= len(b) {
+			break
+		}
+		diff := a[i] - b[i]
+		distance += diff * diff
+	}
+	return distance
+}
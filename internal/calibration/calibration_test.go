@@ -0,0 +1,121 @@
+package calibration
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
+)
+
+func TestCalibratorScore(t *testing.T) {
+	calibrator := New(100)
+
+	// before fitting the fallback curve is used
+	if got := calibrator.Score(0); got != 100 {
+		t.Errorf("Calibrator.Score(0) = %v, want %v", got, 100)
+	}
+
+	if got := calibrator.Score(1); got != 50 {
+		t.Errorf("Calibrator.Score(1) = %v, want %v", got, 50)
+	}
+
+	// five points on a line, the ten pairwise distances are 1 (4x), 4 (3x), 9 (2x) and 16
+	for i := 0; i < 5; i++ {
+		calibrator.Observe(strconv.Itoa(i), []float32{float32(i)})
+	}
+	calibrator.Fit()
+
+	testCases := []struct {
+		distance float32
+		want     float32
+	}{
+		{0, 100},
+		{1, 100},
+		{2, 60},
+		{9, 30},
+		{20, 0},
+	}
+
+	for _, testCase := range testCases {
+		if got := calibrator.Score(testCase.distance); got != testCase.want {
+			t.Errorf("Calibrator.Score(%v) = %v, want %v", testCase.distance, got, testCase.want)
+		}
+	}
+}
+
+func TestCalibratorReservoir(t *testing.T) {
+	calibrator := New(10)
+
+	for i := 0; i < 1000; i++ {
+		calibrator.Observe(strconv.Itoa(i), []float32{float32(i)})
+	}
+
+	if len(calibrator.reservoir) != 10 || calibrator.seen != 1000 {
+		t.Errorf("reservoir = %v of %v seen, want %v of %v", len(calibrator.reservoir), calibrator.seen, 10, 1000)
+	}
+}
+
+func TestCalibratorObserveAndForget(t *testing.T) {
+	calibrator := New(10)
+
+	// re-indexing a player replaces their vector
+	for i := 0; i < 3; i++ {
+		calibrator.Observe("alice", []float32{float32(i)})
+	}
+	calibrator.Observe("bob", []float32{5})
+
+	if len(calibrator.reservoir) != 2 || calibrator.reservoir[calibrator.slots["alice"]].vector[0] != 2 {
+		t.Errorf("reservoir = %v, want alice once with her latest vector", calibrator.reservoir)
+	}
+
+	calibrator.Forget("alice", "carol")
+	if len(calibrator.reservoir) != 1 || calibrator.reservoir[0].id != "bob" || calibrator.slots["bob"] != 0 {
+		t.Errorf("reservoir after Forget() = %v, want only bob", calibrator.reservoir)
+	}
+}
+
+func TestCalibratorSeed(t *testing.T) {
+	ctx := context.Background()
+	memory := store.NewMemory()
+
+	var players []*store.Player
+	for i := 0; i < 5; i++ {
+		players = append(players, &store.Player{ID: strconv.Itoa(i), Vector: []float32{float32(i)}, SchemaVersion: 2})
+	}
+	players = append(players, &store.Player{ID: "old", Vector: []float32{100}, SchemaVersion: 1})
+
+	if err := memory.Upsert(ctx, players); err != nil {
+		t.Fatal(err)
+	}
+
+	calibrator := New(100)
+	if err := calibrator.Seed(ctx, memory, 2); err != nil {
+		t.Fatalf("Calibrator.Seed() error = %v, want nil", err)
+	}
+
+	// the same fit as observing the five points on a line
+	if got := calibrator.Score(2); got != 60 {
+		t.Errorf("Calibrator.Score(2) after seeding = %v, want %v", got, 60)
+	}
+}
+
+func TestCalibratorScorer(t *testing.T) {
+	calibrator := New(100)
+	for i := 0; i < 5; i++ {
+		calibrator.Observe(strconv.Itoa(i), []float32{float32(i), 0})
+	}
+	calibrator.Fit()
+
+	// doubling the weight of the only dimension that varies doubles every distance, a
+	// weighted distance scores like the unweighted distance it doubles
+	scorer := calibrator.Scorer(vectors.Weights{2, 1})
+	if got, want := scorer(4), calibrator.Score(2); got != want {
+		t.Errorf("Scorer(weights)(4) = %v, want %v", got, want)
+	}
+
+	if got, want := calibrator.Scorer(nil)(2), calibrator.Score(2); got != want {
+		t.Errorf("Scorer(nil)(2) = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,281 @@
+package exclusion
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	actionBlock     = "block"
+	actionUnblock   = "unblock"
+	actionTeammates = "teammates"
+	actionForget    = "forget"
+)
+
+// entry is a single line of the exclusion log
+type entry struct {
+	Action  string    `json:"action"`
+	Players []string  `json:"players"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// Store keeps the players that must never be recommended to a player: the ones they
+// blocked or were blocked by, and the ones they recently teamed up with. Recent
+// teammates expire after the configured ttl. Every change is appended to the writer
+// as a JSON line before it is applied, Open replays them
+type Store struct {
+	mutex       *sync.RWMutex
+	writer      io.Writer
+	teammateTTL time.Duration
+	// blocked[a][b] is set when a blocked b, blockedBy[b][a] mirrors it
+	blocked   map[string]map[string]struct{}
+	blockedBy map[string]map[string]struct{}
+	// teammates[a][b] is when b stops being a recent teammate of a
+	teammates map[string]map[string]time.Time
+	now       func() time.Time
+}
+
+// New returns a store that isn't persisted
+func New(teammateTTL time.Duration) *Store {
+	return NewWithWriter(io.Discard, teammateTTL)
+}
+
+// NewWithWriter returns a store appending its changes to writer
+func NewWithWriter(writer io.Writer, teammateTTL time.Duration) *Store {
+	return &Store{
+		mutex:       &sync.RWMutex{},
+		writer:      writer,
+		teammateTTL: teammateTTL,
+		blocked:     make(map[string]map[string]struct{}),
+		blockedBy:   make(map[string]map[string]struct{}),
+		teammates:   make(map[string]map[string]time.Time),
+		now:         time.Now,
+	}
+}
+
+// Open opens (or creates) the exclusion log at path and replays it. Expired teammates
+// are dropped by rewriting the log from what is left
+func Open(path string, teammateTTL time.Duration) (*Store, error) {
+	s := New(teammateTTL)
+
+	file, err := os.Open(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, err
+	default:
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var e entry
+			if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+				file.Close()
+				return nil, err
+			}
+
+			s.apply(e)
+		}
+
+		err := scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	temporary := path + ".tmp"
+	compacted, err := os.OpenFile(temporary, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	s.writer = compacted
+	if err := s.snapshot(); err != nil {
+		compacted.Close()
+		return nil, err
+	}
+
+	if err := compacted.Sync(); err != nil {
+		compacted.Close()
+		return nil, err
+	}
+
+	if err := os.Rename(temporary, path); err != nil {
+		compacted.Close()
+		return nil, err
+	}
+
+	// the descriptor follows the rename, later changes are appended to path
+	return s, nil
+}
+
+// Block hides other from player and player from other
+func (s *Store) Block(player, other string) error {
+	return s.record(entry{Action: actionBlock, Players: []string{player, other}})
+}
+
+func (s *Store) Unblock(player, other string) error {
+	return s.record(entry{Action: actionUnblock, Players: []string{player, other}})
+}
+
+// AddTeammates records that the players queued together, each one is hidden from the
+// others until the ttl passes
+func (s *Store) AddTeammates(players ...string) error {
+	return s.record(entry{Action: actionTeammates, Players: players, Expires: s.now().Add(s.teammateTTL).UTC()})
+}
+
+// Excluded returns every player that must not be recommended to player, including itself
+func (s *Store) Excluded(player string) map[string]struct{} {
+	now := s.now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	excluded := map[string]struct{}{player: {}}
+	for other := range s.blocked[player] {
+		excluded[other] = struct{}{}
+	}
+
+	for other := range s.blockedBy[player] {
+		excluded[other] = struct{}{}
+	}
+
+	for other, expires := range s.teammates[player] {
+		if now.After(expires) {
+			delete(s.teammates[player], other)
+			continue
+		}
+		excluded[other] = struct{}{}
+	}
+
+	return excluded
+}
+
+// Forget drops every entry mentioning the players, used when players are erased
+func (s *Store) Forget(players ...string) error {
+	return s.record(entry{Action: actionForget, Players: players})
+}
+
+// Close closes the underlying writer if it needs closing
+func (s *Store) Close() error {
+	if closer, ok := s.writer.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// record appends the entry to the log and applies it once written
+func (s *Store) record(e entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, err := s.writer.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	s.apply(e)
+	return nil
+}
+
+// snapshot writes the entries that rebuild the store, the mutex must be held or the
+// store not shared yet
+func (s *Store) snapshot() error {
+	now := s.now()
+	encoder := json.NewEncoder(s.writer)
+
+	for player, blocked := range s.blocked {
+		for other := range blocked {
+			if err := encoder.Encode(entry{Action: actionBlock, Players: []string{player, other}}); err != nil {
+				return err
+			}
+		}
+	}
+
+	for player, teammates := range s.teammates {
+		for other, expires := range teammates {
+			// teammates are recorded both ways with the same expiry, once is enough
+			if player > other || now.After(expires) {
+				continue
+			}
+
+			if err := encoder.Encode(entry{Action: actionTeammates, Players: []string{player, other}, Expires: expires}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) apply(e entry) {
+	switch e.Action {
+	case actionBlock:
+		if len(e.Players) == 2 {
+			add(s.blocked, e.Players[0], e.Players[1])
+			add(s.blockedBy, e.Players[1], e.Players[0])
+		}
+	case actionUnblock:
+		if len(e.Players) == 2 {
+			remove(s.blocked, e.Players[0], e.Players[1])
+			remove(s.blockedBy, e.Players[1], e.Players[0])
+		}
+	case actionTeammates:
+		for _, player := range e.Players {
+			for _, other := range e.Players {
+				if player == other {
+					continue
+				}
+
+				if s.teammates[player] == nil {
+					s.teammates[player] = make(map[string]time.Time)
+				}
+				s.teammates[player][other] = e.Expires
+			}
+		}
+	case actionForget:
+		for _, player := range e.Players {
+			s.forget(player)
+		}
+	}
+}
+
+func (s *Store) forget(player string) {
+	for other := range s.blocked[player] {
+		remove(s.blockedBy, other, player)
+	}
+
+	for other := range s.blockedBy[player] {
+		remove(s.blocked, other, player)
+	}
+
+	for other := range s.teammates[player] {
+		delete(s.teammates[other], player)
+	}
+
+	delete(s.blocked, player)
+	delete(s.blockedBy, player)
+	delete(s.teammates, player)
+}
+
+func add(set map[string]map[string]struct{}, key, value string) {
+	if set[key] == nil {
+		set[key] = make(map[string]struct{})
+	}
+	set[key][value] = struct{}{}
+}
+
+func remove(set map[string]map[string]struct{}, key, value string) {
+	delete(set[key], value)
+	if len(set[key]) == 0 {
+		delete(set, key)
+	}
+}
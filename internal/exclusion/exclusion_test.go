@@ -0,0 +1,112 @@
+package exclusion
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func keys(set map[string]struct{}) map[string]bool {
+	result := make(map[string]bool, len(set))
+	for key := range set {
+		result[key] = true
+	}
+	return result
+}
+
+func TestStore(t *testing.T) {
+	now := time.Date(2023, 4, 1, 12, 0, 0, 0, time.UTC)
+	exclusions := New(time.Hour)
+	exclusions.now = func() time.Time { return now }
+
+	exclusions.Block("alice", "bob")
+	exclusions.AddTeammates("alice", "carol", "dave")
+
+	if got, want := keys(exclusions.Excluded("alice")), map[string]bool{"alice": true, "bob": true, "carol": true, "dave": true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Excluded(alice) = %v, want %v", got, want)
+	}
+
+	// blocks hide both players from each other
+	if got, want := keys(exclusions.Excluded("bob")), map[string]bool{"bob": true, "alice": true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Excluded(bob) = %v, want %v", got, want)
+	}
+
+	// recent teammates expire
+	now = now.Add(2 * time.Hour)
+	if got, want := keys(exclusions.Excluded("carol")), map[string]bool{"carol": true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Excluded(carol) after ttl = %v, want %v", got, want)
+	}
+
+	exclusions.Unblock("alice", "bob")
+	if got, want := keys(exclusions.Excluded("bob")), map[string]bool{"bob": true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Excluded(bob) after unblock = %v, want %v", got, want)
+	}
+}
+
+func TestStoreForget(t *testing.T) {
+	exclusions := New(time.Hour)
+
+	exclusions.Block("alice", "bob")
+	exclusions.Block("carol", "alice")
+	exclusions.AddTeammates("alice", "dave")
+
+	exclusions.Forget("alice")
+
+	for _, player := range []string{"bob", "carol", "dave"} {
+		if got, want := keys(exclusions.Excluded(player)), map[string]bool{player: true}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Excluded(%s) after Forget = %v, want %v", player, got, want)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exclusions.log")
+
+	exclusions, err := Open(path, time.Hour)
+	if err != nil {
+		t.Fatalf("Open() error = %v, want nil", err)
+	}
+
+	for _, err := range []error{
+		exclusions.Block("alice", "bob"),
+		exclusions.Block("alice", "erin"),
+		exclusions.Unblock("alice", "erin"),
+		exclusions.AddTeammates("alice", "carol"),
+		exclusions.AddTeammates("dave", "frank"),
+		exclusions.Forget("dave"),
+	} {
+		if err != nil {
+			t.Fatalf("recording an exclusion error = %v, want nil", err)
+		}
+	}
+	exclusions.Close()
+
+	reopened, err := Open(path, time.Hour)
+	if err != nil {
+		t.Fatalf("Open() error = %v, want nil", err)
+	}
+
+	if got, want := keys(reopened.Excluded("alice")), map[string]bool{"alice": true, "bob": true, "carol": true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Excluded(alice) after reopening = %v, want %v", got, want)
+	}
+
+	if got, want := keys(reopened.Excluded("frank")), map[string]bool{"frank": true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Excluded(frank) after reopening = %v, want %v", got, want)
+	}
+
+	// expired teammates are dropped from the log when it is opened
+	expired := `{"action":"teammates","players":["alice","gina"],"expires":"2023-04-01T12:00:00Z"}` + "\n"
+	if err := os.WriteFile(path, []byte(expired), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(path, time.Hour); err != nil {
+		t.Fatalf("Open() error = %v, want nil", err)
+	}
+
+	if raw, _ := os.ReadFile(path); len(raw) != 0 {
+		t.Errorf("log after opening = %q, want it empty", raw)
+	}
+}
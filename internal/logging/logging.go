@@ -0,0 +1,126 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/tenant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDMetadata is the request metadata carrying a request's ID, the server sends
+// the ID it used back in the response header
+const RequestIDMetadata = "x-request-id"
+
+// IDs longer than this are replaced, they end up in every log line of the request
+const maxRequestIDLength = 128
+
+// New returns a logger writing records of level and above to w, as JSON or text
+// (logfmt). Records logged with a context carry its request ID
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("logging: %w", err)
+	}
+
+	options := &slog.HandlerOptions{Level: l}
+	switch format {
+	case "json":
+		return slog.New(handler{slog.NewJSONHandler(w, options)}), nil
+	case "text":
+		return slog.New(handler{slog.NewTextHandler(w, options)}), nil
+	}
+	return nil, fmt.Errorf("logging: unknown format %q, want json or text", format)
+}
+
+// handler adds the request ID of the record's context
+type handler struct {
+	slog.Handler
+}
+
+func (h handler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return handler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h handler) WithGroup(name string) slog.Handler {
+	return handler{h.Handler.WithGroup(name)}
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context of the request with ID id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the ID of the context's request, empty outside of one
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID returns a random request ID
+func NewRequestID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
+
+// UnaryServerInterceptor gives every request an ID, the client's from
+// RequestIDMetadata or a new one, and logs its outcome. It should come first so
+// requests rejected by later interceptors are logged too
+func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	id := ""
+	if values := metadata.ValueFromIncomingContext(ctx, RequestIDMetadata); len(values) > 0 && validRequestID(values[0]) {
+		id = values[0]
+	}
+
+	if id == "" {
+		id = NewRequestID()
+	}
+
+	ctx = WithRequestID(ctx, id)
+	if err := grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadata, id)); err != nil {
+		slog.WarnContext(ctx, "setting the request ID header", "err", err)
+	}
+
+	start := time.Now()
+	res, err := handler(ctx, req)
+
+	attrs := []any{"method", info.FullMethod, "code", int(status.Code(err)), "duration", time.Since(start)}
+	if name := tenant.FromContext(ctx); name != "" {
+		attrs = append(attrs, "tenant", name)
+	}
+
+	if err != nil {
+		slog.WarnContext(ctx, "request failed", append(attrs, "err", status.Convert(err).Message())...)
+	} else {
+		slog.DebugContext(ctx, "request", attrs...)
+	}
+	return res, err
+}
+
+// validRequestID reports whether a client's request ID can be logged as it is
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	return !strings.ContainsFunc(id, func(r rune) bool { return r < '!' || r > '~' })
+}
@@ -0,0 +1,120 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestNew(t *testing.T) {
+	var buffer bytes.Buffer
+	logger, err := New(&buffer, "warn", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.InfoContext(WithRequestID(context.Background(), "abc"), "skipped")
+	logger.With("generation", "Players").WarnContext(WithRequestID(context.Background(), "abc"), "logged")
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buffer.Bytes(), &record); err != nil {
+		t.Fatalf("log = %q, want a single JSON record: %v", buffer.String(), err)
+	}
+
+	if record["msg"] != "logged" || record["request_id"] != "abc" || record["generation"] != "Players" {
+		t.Errorf("record = %v, want the warning with its request ID", record)
+	}
+
+	if _, err := New(&buffer, "loud", "json"); err == nil {
+		t.Error("New() with an unknown level error = nil, want an error")
+	}
+
+	if _, err := New(&buffer, "info", "xml"); err == nil {
+		t.Error("New() with an unknown format error = nil, want an error")
+	}
+}
+
+func newTestClient(t *testing.T, interceptors ...grpc.UnaryServerInterceptor) healthpb.HealthClient {
+	listener := bufconn.Listen(1024 * 1024)
+
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "", grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return listener.Dial()
+	}), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	var seen string
+	client := newTestClient(t, UnaryServerInterceptor, func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		seen = RequestID(ctx)
+		return handler(ctx, req)
+	})
+
+	tests := []struct {
+		testName string
+		id       string
+		kept     bool
+	}{
+		{"client id", "trace-1234", true},
+		{"no id", "", false},
+		{"id with spaces", "trace 1234", false},
+		{"id too long", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.id != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, RequestIDMetadata, tt.id)
+			}
+
+			var header metadata.MD
+			if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Header(&header)); err != nil {
+				t.Fatalf("Check() error = %v, want nil", err)
+			}
+
+			if tt.kept && seen != tt.id {
+				t.Errorf("RequestID() = %q, want the client's %q", seen, tt.id)
+			}
+
+			if !tt.kept && (seen == tt.id || len(seen) != 32) {
+				t.Errorf("RequestID() = %q, want a new ID", seen)
+			}
+
+			if got := header.Get(RequestIDMetadata); len(got) != 1 || got[0] != seen {
+				t.Errorf("%s header = %v, want %q", RequestIDMetadata, got, seen)
+			}
+		})
+	}
+}
+
+func TestHandlerWithoutRequestID(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := slog.New(handler{slog.NewTextHandler(&buffer, nil)})
+
+	logger.InfoContext(context.Background(), "startup")
+	if strings.Contains(buffer.String(), "request_id") {
+		t.Errorf("log = %q, want no request ID outside of a request", buffer.String())
+	}
+}
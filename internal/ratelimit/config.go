@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Rule limits the requests of a client to a method, an empty Client or Method matches
+// any. A request is limited by its most specific rule, client and method first, then
+// client, then method, then the rule matching any
+type Rule struct {
+	Client string `json:"client,omitempty"`
+	Method string `json:"method,omitempty"`
+	// Rate is the requests per second refilled, 0 doesn't limit
+	Rate float64 `json:"rate"`
+	// Burst is the requests a client can make at once after being idle
+	Burst int `json:"burst"`
+}
+
+// Config are the rate limits and the cap on requests in flight at once, which
+// protects the store from every client together
+type Config struct {
+	Rules []Rule `json:"rules"`
+	// MaxInFlight is the requests handled at once, 0 doesn't cap them
+	MaxInFlight int `json:"maxInFlight"`
+}
+
+// DefaultConfig limits every client to 50 requests a second per method and the server
+// to 256 requests in flight
+func DefaultConfig() Config {
+	return Config{
+		Rules:       []Rule{{Rate: 50, Burst: 100}},
+		MaxInFlight: 256,
+	}
+}
+
+// Load reads the config at path, DefaultConfig when there is no file
+func Load(path string) (Config, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return DefaultConfig(), nil
+	}
+
+	if err != nil {
+		return Config{}, err
+	}
+
+	var config Config
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return Config{}, fmt.Errorf("ratelimit: %s: %w", path, err)
+	}
+
+	return config, config.Validate()
+}
+
+// Validate checks that limits aren't negative, that limited rules allow at least a
+// request at once and that no two rules match the same requests
+func (c Config) Validate() error {
+	if c.MaxInFlight < 0 {
+		return errors.New("ratelimit: negative maxInFlight")
+	}
+
+	seen := make(map[[2]string]struct{}, len(c.Rules))
+	for _, rule := range c.Rules {
+		if rule.Rate < 0 || (rule.Rate > 0 && rule.Burst < 1) {
+			return fmt.Errorf("ratelimit: rule of client %q and method %q needs a positive rate and burst", rule.Client, rule.Method)
+		}
+
+		key := [2]string{rule.Client, rule.Method}
+		if _, ok := seen[key]; ok {
+			return fmt.Errorf("ratelimit: client %q and method %q have several rules", rule.Client, rule.Method)
+		}
+		seen[key] = struct{}{}
+	}
+
+	return nil
+}
+
+// rule returns the most specific rule matching a request, ok is false when none does
+func (c Config) rule(client, method string) (Rule, bool) {
+	best, rank := Rule{}, 0
+	for _, rule := range c.Rules {
+		if (rule.Client != "" && rule.Client != client) || (rule.Method != "" && rule.Method != method) {
+			continue
+		}
+
+		// client rules are more specific than method rules, both than neither
+		r := 1
+		if rule.Method != "" {
+			r += 1
+		}
+		if rule.Client != "" {
+			r += 2
+		}
+
+		if r > rank {
+			best, rank = rule, r
+		}
+	}
+	return best, rank > 0
+}
@@ -0,0 +1,194 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// RetryAfterMetadata is the response metadata telling a limited client how many
+// seconds to wait before retrying
+const RetryAfterMetadata = "retry-after"
+
+// bucket is a token bucket of a client and method
+type bucket struct {
+	rule   Rule
+	tokens float64
+	last   time.Time
+}
+
+// Limiter limits each client's requests per method with token buckets, and the
+// requests in flight at once
+type Limiter struct {
+	mutex    sync.Mutex
+	config   Config
+	buckets  map[[2]string]*bucket
+	inFlight int
+	modified time.Time
+	now      func() time.Time
+}
+
+func New(config Config) *Limiter {
+	return &Limiter{config: config, buckets: map[[2]string]*bucket{}, now: time.Now}
+}
+
+// SetConfig replaces the limits, buckets refill under the new rules from where they
+// are. Requests in flight are kept
+func (l *Limiter) SetConfig(config Config) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.config = config
+}
+
+// UnaryServerInterceptor rejects requests over their client's limit, or over the
+// requests in flight, with ResourceExhausted and RetryAfterMetadata. It identifies
+// clients by auth.ClientFromContext, by address when the server doesn't authenticate
+func (l *Limiter) UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	client := clientOf(ctx)
+
+	if wait, ok := l.take(client, info.FullMethod); !ok {
+		return nil, exhausted(ctx, wait, "%s = rate limit of client %s exceeded", info.FullMethod, client)
+	}
+
+	if !l.acquire() {
+		return nil, exhausted(ctx, time.Second, "%s = too many requests in flight", info.FullMethod)
+	}
+	defer l.release()
+
+	return handler(ctx, req)
+}
+
+func exhausted(ctx context.Context, wait time.Duration, format string, args ...interface{}) error {
+	seconds := strconv.Itoa(int(math.Ceil(wait.Seconds())))
+	if err := grpc.SetHeader(ctx, metadata.Pairs(RetryAfterMetadata, seconds)); err != nil {
+		slog.WarnContext(ctx, "ratelimit: setting the retry-after header", "err", err)
+	}
+	return status.Errorf(codes.ResourceExhausted, format, args...)
+}
+
+func clientOf(ctx context.Context) string {
+	if client := auth.ClientFromContext(ctx); client != "" {
+		return client
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		// the port changes between connections of the same client
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return host
+		}
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// take takes a token of the client's bucket for method, or returns how long until
+// there is one
+func (l *Limiter) take(client, method string) (time.Duration, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	rule, ok := l.config.rule(client, method)
+	if !ok || rule.Rate == 0 {
+		return 0, true
+	}
+
+	now := l.now()
+	key := [2]string{client, method}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{rule: rule, tokens: float64(rule.Burst), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(rule.Burst), b.tokens+now.Sub(b.last).Seconds()*rule.Rate)
+	b.rule, b.last = rule, now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / rule.Rate * float64(time.Second)), false
+	}
+
+	b.tokens--
+	return 0, true
+}
+
+func (l *Limiter) acquire() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.config.MaxInFlight > 0 && l.inFlight >= l.config.MaxInFlight {
+		return false
+	}
+
+	l.inFlight++
+	return true
+}
+
+func (l *Limiter) release() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.inFlight--
+}
+
+// prune forgets buckets that have refilled, a new bucket starts out full as well
+func (l *Limiter) prune() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*b.rule.Rate >= float64(b.rule.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Run reloads the config at path every interval once it has changed, keeping the
+// current limits when it is invalid, and prunes idle buckets
+func (l *Limiter) Run(ctx context.Context, path string, interval time.Duration) {
+	// the config was loaded before, don't reload it on the first tick
+	if info, err := os.Stat(path); err == nil {
+		l.modified = info.ModTime()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.reload(path)
+			l.prune()
+		}
+	}
+}
+
+func (l *Limiter) reload(path string) {
+	info, err := os.Stat(path)
+	if err != nil || info.ModTime().Equal(l.modified) {
+		return
+	}
+	l.modified = info.ModTime()
+
+	config, err := Load(path)
+	if err != nil {
+		slog.Error("ratelimit: invalid config, keeping the current limits", "path", path, "err", err)
+		return
+	}
+
+	l.SetConfig(config)
+	slog.Info("ratelimit: reloaded config", "path", path)
+}
@@ -0,0 +1,183 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestConfigRule(t *testing.T) {
+	config := Config{Rules: []Rule{
+		{Rate: 50, Burst: 100},
+		{Method: "/RecommendationService/Index", Rate: 10, Burst: 10},
+		{Client: "scraper", Rate: 1, Burst: 1},
+		{Client: "indexer", Method: "/RecommendationService/Index", Rate: 500, Burst: 1000},
+	}}
+
+	tests := []struct {
+		client, method string
+		rate           float64
+	}{
+		{"web", "/RecommendationService/Recommend", 50},
+		{"web", "/RecommendationService/Index", 10},
+		{"scraper", "/RecommendationService/Index", 1},
+		{"indexer", "/RecommendationService/Index", 500},
+		{"indexer", "/RecommendationService/Recommend", 50},
+	}
+
+	for _, tt := range tests {
+		if rule, ok := config.rule(tt.client, tt.method); !ok || rule.Rate != tt.rate {
+			t.Errorf("rule(%s, %s) = %v, %v, want rate %v", tt.client, tt.method, rule, ok, tt.rate)
+		}
+	}
+
+	if _, ok := (Config{}).rule("web", "/RecommendationService/Index"); ok {
+		t.Error("rule() without rules ok = true, want false")
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	invalid := []Config{
+		{MaxInFlight: -1},
+		{Rules: []Rule{{Rate: -1}}},
+		{Rules: []Rule{{Rate: 10}}},
+		{Rules: []Rule{{Client: "web", Rate: 1, Burst: 1}, {Client: "web", Rate: 2, Burst: 2}}},
+	}
+
+	for _, config := range invalid {
+		if err := config.Validate(); err == nil {
+			t.Errorf("Validate(%+v) error = nil, want an error", config)
+		}
+	}
+
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Errorf("DefaultConfig().Validate() error = %v, want nil", err)
+	}
+}
+
+func TestLimiterTake(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	limiter := New(Config{Rules: []Rule{{Rate: 2, Burst: 3}}})
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, ok := limiter.take("web", "Index"); !ok {
+			t.Fatalf("take() %d of the burst ok = false, want true", i)
+		}
+	}
+
+	wait, ok := limiter.take("web", "Index")
+	if ok || wait != 500*time.Millisecond {
+		t.Fatalf("take() over the burst = %v, %v, want 500ms, false", wait, ok)
+	}
+
+	// buckets are per client and method
+	if _, ok := limiter.take("mobile", "Index"); !ok {
+		t.Error("take() of another client ok = false, want true")
+	}
+	if _, ok := limiter.take("web", "Recommend"); !ok {
+		t.Error("take() of another method ok = false, want true")
+	}
+
+	now = now.Add(time.Second)
+	for i := 0; i < 2; i++ {
+		if _, ok := limiter.take("web", "Index"); !ok {
+			t.Fatalf("take() %d after a second ok = false, want true", i)
+		}
+	}
+	if _, ok := limiter.take("web", "Index"); ok {
+		t.Error("take() over the refill ok = true, want false")
+	}
+
+	now = now.Add(time.Minute)
+	if limiter.prune(); len(limiter.buckets) != 0 {
+		t.Errorf("buckets after prune() = %d, want 0", len(limiter.buckets))
+	}
+}
+
+func TestLimiterReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimit.json")
+	limiter := New(DefaultConfig())
+
+	if err := os.WriteFile(path, []byte(`{"rules": [{"client": "scraper", "rate": 1, "burst": 1}], "maxInFlight": 8}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if limiter.reload(path); limiter.config.MaxInFlight != 8 || len(limiter.config.Rules) != 1 {
+		t.Fatalf("config after reload() = %+v, want the file's", limiter.config)
+	}
+
+	// an invalid file keeps the limits in place
+	if err := os.WriteFile(path, []byte(`{"maxInFlight": -1}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	limiter.modified = time.Time{}
+
+	if limiter.reload(path); limiter.config.MaxInFlight != 8 {
+		t.Errorf("config after reload() of an invalid file = %+v, want the last valid one", limiter.config)
+	}
+}
+
+func newTestClient(t *testing.T, limiter *Limiter) healthpb.HealthClient {
+	listener := bufconn.Listen(1024 * 1024)
+
+	server := grpc.NewServer(grpc.UnaryInterceptor(limiter.UnaryServerInterceptor))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "", grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return listener.Dial()
+	}), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestLimiter_UnaryServerInterceptor(t *testing.T) {
+	limiter := New(Config{Rules: []Rule{{Rate: 0.1, Burst: 1}}})
+	client := newTestClient(t, limiter)
+	ctx := context.Background()
+
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("Check() error = %v, want nil", err)
+	}
+
+	var header metadata.MD
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Header(&header))
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("Check() over the limit error = %v, want ResourceExhausted", err)
+	}
+
+	if got := header.Get(RetryAfterMetadata); len(got) != 1 || got[0] != "10" {
+		t.Errorf("%s = %v, want 10", RetryAfterMetadata, got)
+	}
+
+	// a request in flight takes the only slot
+	limiter.SetConfig(Config{MaxInFlight: 1})
+	limiter.acquire()
+
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Check() over the requests in flight error = %v, want ResourceExhausted", err)
+	}
+
+	limiter.release()
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Errorf("Check() after the request finished error = %v, want nil", err)
+	}
+}
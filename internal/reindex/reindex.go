@@ -0,0 +1,363 @@
+package reindex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/atomicfile"
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
+)
+
+// ErrNoPrevious is returned when rolling back a collection that was never switched
+var ErrNoPrevious = errors.New("reindex: no previous collection to roll back to")
+
+// Deletions returns the players deleted from a collection since a time. Rolling back
+// deletes them from the collection switched back to, which missed them
+type Deletions func(since time.Time) ([]string, error)
+
+// ClockSkew widens the writes a job catches up on, other replicas stamp their writes
+// with their own clocks
+const ClockSkew = time.Minute
+
+// catchUpPasses bounds the passes over Source catching up on writes, each one only
+// looks for the writes since the previous one started
+const catchUpPasses = 3
+
+// Checkpoint is persisted after every batch, a job started again for the same target
+// and schema resumes after Cursor instead of starting over
+type Checkpoint struct {
+	Target        string `json:"target"`
+	SchemaVersion int    `json:"schemaVersion"`
+	Cursor        string `json:"cursor"`
+	Copied        int    `json:"copied"`
+	Done          bool   `json:"done"`
+	// StartedAt is when the job first started, a resumed job catches up on the
+	// writes since
+	StartedAt time.Time `json:"startedAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// LoadCheckpoint reads the checkpoint at path, nil when there is none
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(raw, &checkpoint); err != nil {
+		return nil, fmt.Errorf("reindex: %s: %w", path, err)
+	}
+
+	return &checkpoint, nil
+}
+
+// Save writes the checkpoint to path, replacing the previous one atomically
+func (c Checkpoint) Save(path string) error {
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.WriteFile(path, raw, 0o644)
+}
+
+// RemoveCheckpoint drops the checkpoint at path, the next job starts over
+func RemoveCheckpoint(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Progress is a snapshot of a running or finished job
+type Progress struct {
+	Target        string
+	SchemaVersion int
+	Copied        int
+	// Total is the number of source records when the job started, copies of records
+	// indexed since can take Copied past it
+	Total     int
+	Done      bool
+	Err       error
+	StartedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Job re-embeds every record of Source with Schema into Target. Records are read back
+// as raw stats, so any change to the transform or normalization can be migrated
+type Job struct {
+	Source store.Store
+	Target store.Store
+	// TargetName identifies Target in the checkpoint
+	TargetName string
+	Schema     vectors.Schema
+	BatchSize  int
+	// Checkpoint is the file progress is persisted to, empty disables resuming
+	Checkpoint string
+	// Deleted returns the players deleted from Source since a time. Other replicas
+	// don't mirror their writes into Target, once every record is copied the job
+	// catches up on their deletes through it and on their upserts by UpdatedAt
+	Deleted Deletions
+
+	mutex    sync.Mutex
+	progress Progress
+	// touched holds the epoch each player was last touched in, see Touch
+	touched map[string]uint64
+	epoch   uint64
+}
+
+// Touch records that writers are about to mirror a write of ids into Target. A batch
+// copying any of them meanwhile may overwrite the newer record with the one it read
+// before, it copies them from Source again once it sees they were touched
+func (j *Job) Touch(ids ...string) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if j.touched == nil {
+		j.touched = make(map[string]uint64)
+	}
+
+	j.epoch++
+	for _, id := range ids {
+		j.touched[id] = j.epoch
+	}
+}
+
+// touchedSince returns which of ids were touched after epoch, and the current epoch
+func (j *Job) touchedSince(epoch uint64, ids []string) ([]string, uint64) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	var touched []string
+	for _, id := range ids {
+		if j.touched[id] > epoch {
+			touched = append(touched, id)
+		}
+	}
+	return touched, j.epoch
+}
+
+// Run copies the records batch by batch until Source is exhausted, ctx is cancelled
+// or a batch fails, then catches up on the writes since it started. A stopped job
+// resumes from its checkpoint when run again
+func (j *Job) Run(ctx context.Context) error {
+	checkpoint := Checkpoint{Target: j.TargetName, SchemaVersion: j.Schema.Version, StartedAt: time.Now().UTC()}
+	if j.Checkpoint != "" {
+		saved, err := LoadCheckpoint(j.Checkpoint)
+		if err != nil {
+			return j.finish(err)
+		}
+
+		if saved != nil && saved.Target == j.TargetName && saved.SchemaVersion == j.Schema.Version && !saved.Done {
+			checkpoint = *saved
+		}
+	}
+
+	total, err := j.Source.Count(ctx)
+	if err != nil {
+		return j.finish(err)
+	}
+
+	j.update(func(p *Progress) {
+		*p = Progress{Target: j.TargetName, SchemaVersion: j.Schema.Version, Copied: checkpoint.Copied, Total: total, StartedAt: time.Now().UTC()}
+	})
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return j.finish(err)
+		}
+
+		next, copied, err := j.batch(ctx, checkpoint.Cursor)
+		if err != nil {
+			return j.finish(err)
+		}
+
+		// the job is only done once caught up, one stopped before resumes from the last batch
+		if next == "" {
+			if err := j.catchUp(ctx, checkpoint.StartedAt); err != nil {
+				return j.finish(err)
+			}
+		}
+
+		checkpoint.Cursor = next
+		checkpoint.Copied += copied
+		checkpoint.Done = next == ""
+		checkpoint.UpdatedAt = time.Now().UTC()
+
+		if j.Checkpoint != "" {
+			if err := checkpoint.Save(j.Checkpoint); err != nil {
+				return j.finish(err)
+			}
+		}
+
+		j.update(func(p *Progress) { p.Copied = checkpoint.Copied })
+
+		if checkpoint.Done {
+			return j.finish(nil)
+		}
+	}
+}
+
+// catchUp copies the writes to Source since, which didn't all go through this job's
+// writers. Each pass scans Source for the records updated since the previous one
+// started, until a pass finds none or catchUpPasses is reached
+func (j *Job) catchUp(ctx context.Context, since time.Time) error {
+	for pass := 0; pass < catchUpPasses; pass++ {
+		started := time.Now().UTC()
+		caught, err := j.catchUpSince(ctx, since.Add(-ClockSkew))
+		if err != nil || caught == 0 {
+			return err
+		}
+		since = started
+	}
+	return nil
+}
+
+// catchUpSince deletes the players deleted since from Target and copies the records
+// updated since, deletes first as a player deleted and indexed again is in Source
+func (j *Job) catchUpSince(ctx context.Context, since time.Time) (int, error) {
+	_, epoch := j.touchedSince(0, nil)
+
+	var ids []string
+	if j.Deleted != nil {
+		deleted, err := j.Deleted(since)
+		if err != nil {
+			return 0, err
+		}
+
+		for start := 0; start < len(deleted); start += j.BatchSize {
+			if err := j.Target.Delete(ctx, deleted[start:min(start+j.BatchSize, len(deleted))]); err != nil {
+				return 0, err
+			}
+		}
+		ids = append(ids, deleted...)
+	}
+
+	for cursor := ""; ; {
+		players, next, err := j.Source.Scan(ctx, cursor, j.BatchSize)
+		if err != nil {
+			return 0, err
+		}
+
+		written := make([]*store.Player, 0, len(players))
+		for _, player := range players {
+			if !player.UpdatedAt.Before(since) {
+				written = append(written, player)
+				ids = append(ids, player.ID)
+			}
+		}
+
+		if len(written) > 0 {
+			j.embed(written)
+			if err := j.Target.Upsert(ctx, written); err != nil {
+				return 0, err
+			}
+		}
+
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	return len(ids), j.recopy(ctx, epoch, ids)
+}
+
+// batch copies the records after cursor and returns the cursor to continue from
+func (j *Job) batch(ctx context.Context, cursor string) (string, int, error) {
+	_, epoch := j.touchedSince(0, nil)
+
+	players, next, err := j.Source.Scan(ctx, cursor, j.BatchSize)
+	if err != nil {
+		return "", 0, err
+	}
+
+	j.embed(players)
+	if err := j.Target.Upsert(ctx, players); err != nil {
+		return "", 0, err
+	}
+
+	ids := make([]string, 0, len(players))
+	for _, player := range players {
+		ids = append(ids, player.ID)
+	}
+
+	if err := j.recopy(ctx, epoch, ids); err != nil {
+		return "", 0, err
+	}
+
+	return next, len(players), nil
+}
+
+// recopy copies the players of ids touched since epoch from Source again, until no
+// copy raced a mirrored write. A player deleted meanwhile is deleted from Target
+func (j *Job) recopy(ctx context.Context, epoch uint64, ids []string) error {
+	for {
+		ids, epoch = j.touchedSince(epoch, ids)
+		if len(ids) == 0 {
+			return nil
+		}
+
+		for _, id := range ids {
+			history, err := j.Source.History(ctx, id)
+			if errors.Is(err, store.ErrNotFound) {
+				if err := j.Target.Delete(ctx, []string{id}); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err != nil {
+				return err
+			}
+
+			j.embed(history)
+			if err := j.Target.Upsert(ctx, history); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// embed rebuilds the vectors of players with the job's schema
+func (j *Job) embed(players []*store.Player) {
+	for _, player := range players {
+		player.Vector = j.Schema.Vector(player.Stats)
+		player.SchemaVersion = j.Schema.Version
+	}
+}
+
+func (j *Job) finish(err error) error {
+	j.update(func(p *Progress) {
+		p.Done = err == nil
+		p.Err = err
+	})
+	return err
+}
+
+func (j *Job) update(apply func(*Progress)) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	apply(&j.progress)
+	j.progress.UpdatedAt = time.Now().UTC()
+}
+
+// Progress returns a snapshot of the job's progress
+func (j *Job) Progress() Progress {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	return j.progress
+}
@@ -0,0 +1,204 @@
+package reindex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
+)
+
+// failingStore fails every Upsert after the first upserts
+type failingStore struct {
+	*store.Memory
+	upserts int
+}
+
+var errUnavailable = errors.New("unavailable")
+
+func (f *failingStore) Upsert(ctx context.Context, players []*store.Player) error {
+	if f.upserts == 0 {
+		return errUnavailable
+	}
+	f.upserts--
+	return f.Memory.Upsert(ctx, players)
+}
+
+func newSource(t *testing.T, records int) *store.Memory {
+	source := store.NewMemory()
+	for i := 0; i < records; i++ {
+		stats := vectors.Player{Level: 100 + i, Kost: 0.5, Rank: 20, Season: 30}
+		player := &store.Player{ID: fmt.Sprintf("player-%02d", i), Stats: stats, Vector: vectors.SchemaV3.Vector(stats), SchemaVersion: vectors.SchemaV3.Version}
+		if err := source.Upsert(context.Background(), []*store.Player{player}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return source
+}
+
+func TestJobRun(t *testing.T) {
+	ctx := context.Background()
+	source := newSource(t, 7)
+	target := store.NewMemory()
+
+	job := &Job{Source: source, Target: target, TargetName: "Players_v4", Schema: vectors.SchemaV4, BatchSize: 3}
+	if err := job.Run(ctx); err != nil {
+		t.Fatalf("Job.Run() error = %v, want nil", err)
+	}
+
+	if progress := job.Progress(); !progress.Done || progress.Copied != 7 || progress.Total != 7 || progress.Err != nil {
+		t.Errorf("Job.Progress() = %+v, want 7 of 7 copied", progress)
+	}
+
+	player, err := target.Get(ctx, "player-03")
+	if err != nil {
+		t.Fatalf("Memory.Get() error = %v, want nil", err)
+	}
+
+	want := vectors.SchemaV4.Vector(player.Stats)
+	if player.SchemaVersion != vectors.SchemaV4.Version || len(player.Vector) != len(want) || player.Vector[0] != want[0] {
+		t.Errorf("re-indexed player = v%d %v, want v%d %v", player.SchemaVersion, player.Vector, vectors.SchemaV4.Version, want)
+	}
+
+	// the source keeps serving the old vectors
+	if original, _ := source.Get(ctx, "player-03"); original.SchemaVersion != vectors.SchemaV3.Version {
+		t.Errorf("source player = v%d, want v%d", original.SchemaVersion, vectors.SchemaV3.Version)
+	}
+}
+
+func TestJobResume(t *testing.T) {
+	ctx := context.Background()
+	source := newSource(t, 7)
+	target := &failingStore{Memory: store.NewMemory(), upserts: 1}
+	checkpoint := filepath.Join(t.TempDir(), "reindex.checkpoint")
+
+	job := &Job{Source: source, Target: target, TargetName: "Players_v4", Schema: vectors.SchemaV4, BatchSize: 3, Checkpoint: checkpoint}
+	if err := job.Run(ctx); !errors.Is(err, errUnavailable) {
+		t.Fatalf("Job.Run() error = %v, want %v", err, errUnavailable)
+	}
+
+	if progress := job.Progress(); progress.Done || progress.Copied != 3 || !errors.Is(progress.Err, errUnavailable) {
+		t.Errorf("Job.Progress() = %+v, want 3 copied before failing", progress)
+	}
+
+	saved, err := LoadCheckpoint(checkpoint)
+	if err != nil || saved == nil || saved.Copied != 3 || saved.Cursor == "" {
+		t.Fatalf("LoadCheckpoint() = %+v, %v, want 3 copied", saved, err)
+	}
+
+	target.upserts = 10
+	resumed := &Job{Source: source, Target: target, TargetName: "Players_v4", Schema: vectors.SchemaV4, BatchSize: 3, Checkpoint: checkpoint}
+	if err := resumed.Run(ctx); err != nil {
+		t.Fatalf("Job.Run() error = %v, want nil", err)
+	}
+
+	// 2 batches finish the remaining 4 records, the first batch isn't copied again
+	if progress := resumed.Progress(); !progress.Done || progress.Copied != 7 || target.upserts != 8 {
+		t.Errorf("Job.Progress() = %+v after %d upserts, want 7 copied in 2 more batches", progress, 10-target.upserts)
+	}
+
+	if target.Len() != 7 {
+		t.Errorf("target has %d records, want 7", target.Len())
+	}
+
+	// a job for another target and schema doesn't pick up the checkpoint
+	other := &Job{Source: source, Target: store.NewMemory(), TargetName: "Players_v3", Schema: vectors.SchemaV3, BatchSize: 3, Checkpoint: checkpoint}
+	if err := other.Run(ctx); err != nil || other.Progress().Copied != 7 {
+		t.Errorf("Job.Run() for another target copied %d, %v, want 7 from the start", other.Progress().Copied, err)
+	}
+}
+
+// racingStore runs write after its first Scan, like a write mirrored while a batch copies
+type racingStore struct {
+	*store.Memory
+	write func()
+}
+
+func (r *racingStore) Scan(ctx context.Context, cursor string, limit int) ([]*store.Player, string, error) {
+	players, next, err := r.Memory.Scan(ctx, cursor, limit)
+	if r.write != nil {
+		r.write()
+		r.write = nil
+	}
+	return players, next, err
+}
+
+func TestJobTouch(t *testing.T) {
+	ctx := context.Background()
+	source := &racingStore{Memory: newSource(t, 3)}
+	target := store.NewMemory()
+	job := &Job{Source: source, Target: target, TargetName: "Players_v4", Schema: vectors.SchemaV4, BatchSize: 10}
+
+	// player-01 levels up and player-02 is deleted after the batch read them, the
+	// mirrored writes reach the target before the batch's stale copies
+	source.write = func() {
+		stats := vectors.Player{Level: 500, Kost: 0.5, Rank: 20, Season: 30}
+		levelled := &store.Player{ID: "player-01", Stats: stats, Vector: vectors.SchemaV3.Vector(stats), SchemaVersion: vectors.SchemaV3.Version}
+		source.Memory.Upsert(ctx, []*store.Player{levelled})
+		source.Memory.Delete(ctx, []string{"player-02"})
+
+		job.Touch("player-01", "player-02")
+		mirrored := *levelled
+		mirrored.Vector, mirrored.SchemaVersion = vectors.SchemaV4.Vector(stats), vectors.SchemaV4.Version
+		target.Upsert(ctx, []*store.Player{&mirrored})
+		target.Delete(ctx, []string{"player-02"})
+	}
+
+	if err := job.Run(ctx); err != nil {
+		t.Fatalf("Job.Run() error = %v, want nil", err)
+	}
+
+	if player, err := target.Get(ctx, "player-01"); err != nil || player.Stats.Level != 500 || player.SchemaVersion != vectors.SchemaV4.Version {
+		t.Errorf("target player-01 = %+v, %v, want the mirrored level 500", player, err)
+	}
+
+	if _, err := target.Get(ctx, "player-02"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("target player-02 error = %v, want the mirrored delete", err)
+	}
+}
+
+func TestJobCatchUp(t *testing.T) {
+	ctx := context.Background()
+	source := &racingStore{Memory: newSource(t, 5)}
+	target := store.NewMemory()
+	job := &Job{Source: source, Target: target, TargetName: "Players_v4", Schema: vectors.SchemaV4, BatchSize: 2}
+
+	// another replica levels player-00 up after the batch copying it and deletes
+	// player-04 before it's copied, none of it is mirrored into the target
+	var deletedSince time.Time
+	job.Deleted = func(since time.Time) ([]string, error) {
+		if deletedSince.IsZero() {
+			deletedSince = since
+		}
+		return []string{"player-04"}, nil
+	}
+
+	source.write = func() {
+		stats := vectors.Player{Level: 500, Kost: 0.5, Rank: 20, Season: 30}
+		levelled := &store.Player{ID: "player-00", Stats: stats, Vector: vectors.SchemaV3.Vector(stats), SchemaVersion: vectors.SchemaV3.Version, UpdatedAt: time.Now().UTC()}
+		source.Memory.Upsert(ctx, []*store.Player{levelled})
+		target.Upsert(ctx, []*store.Player{{ID: "player-04", Stats: stats, SchemaVersion: vectors.SchemaV4.Version}})
+		source.Memory.Delete(ctx, []string{"player-04"})
+	}
+
+	started := time.Now()
+	if err := job.Run(ctx); err != nil {
+		t.Fatalf("Job.Run() error = %v, want nil", err)
+	}
+
+	if player, err := target.Get(ctx, "player-00"); err != nil || player.Stats.Level != 500 || player.SchemaVersion != vectors.SchemaV4.Version {
+		t.Errorf("target player-00 = %+v, %v, want the level 500 written by the other replica", player, err)
+	}
+
+	if _, err := target.Get(ctx, "player-04"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("target player-04 error = %v, want the delete made by the other replica", err)
+	}
+
+	if deletedSince.After(started) {
+		t.Errorf("Job.Run() caught up on deletes since %v, want since before the job started", deletedSince)
+	}
+}
@@ -0,0 +1,65 @@
+package rerank
+
+import (
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+)
+
+// MMR re-ranks candidates with Maximal Marginal Relevance, picking k of them one at a
+// time by lambda*sim(query, c) - (1-lambda)*max sim(c, picked). A lambda of 1 keeps
+// the nearest neighbour order, lower values trade similarity for variety
+func MMR(query []float32, candidates []store.Hit, lambda float64, k int) []store.Hit {
+	if k > len(candidates) {
+		k = len(candidates)
+	}
+
+	relevance := make([]float64, len(candidates))
+	for i, candidate := range candidates {
+		relevance[i] = similarity(query, candidate.Player.Vector)
+	}
+
+	// redundancy[i] is the highest similarity of candidate i to any picked candidate
+	redundancy := make([]float64, len(candidates))
+	picked := make([]bool, len(candidates))
+	selected := make([]store.Hit, 0, k)
+
+	for len(selected) < k {
+		best, bestScore := -1, 0.0
+		for i := range candidates {
+			if picked[i] {
+				continue
+			}
+
+			score := lambda*relevance[i] - (1-lambda)*redundancy[i]
+			if best == -1 || score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+
+		picked[best] = true
+		selected = append(selected, candidates[best])
+
+		for i := range candidates {
+			if !picked[i] {
+				if sim := similarity(candidates[best].Player.Vector, candidates[i].Player.Vector); sim > redundancy[i] {
+					redundancy[i] = sim
+				}
+			}
+		}
+	}
+
+	return selected
+}
+
+// similarity maps the l2-squared distance onto (0, 1], identical vectors score 1
+func similarity(a, b []float32) float64 {
+	var distance float64
+	for i := range a {
+		if i >= len(b) {
+			break
+		}
+		diff := float64(a[i] - b[i])
+		distance += diff * diff
+	}
+
+	return 1 / (1 + distance)
+}
@@ -0,0 +1,55 @@
+package rerank
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+)
+
+func hits(vectors map[string][]float32, order ...string) []store.Hit {
+	result := make([]store.Hit, 0, len(order))
+	for _, id := range order {
+		result = append(result, store.Hit{Player: &store.Player{ID: id, Vector: vectors[id]}})
+	}
+	return result
+}
+
+func ids(hits []store.Hit) []string {
+	result := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		result = append(result, hit.Player.ID)
+	}
+	return result
+}
+
+func TestMMR(t *testing.T) {
+	// a and b are near duplicates, c is a little further away in another direction
+	vectors := map[string][]float32{
+		"a": {1, 0},
+		"b": {1.05, 0},
+		"c": {0, -1.2},
+	}
+	candidates := hits(vectors, "a", "b", "c")
+	query := []float32{0, 0}
+
+	testCases := []struct {
+		lambda float64
+		want   []string
+	}{
+		{1, []string{"a", "b"}},
+		{0.5, []string{"a", "c"}},
+	}
+
+	for _, testCase := range testCases {
+		got := ids(MMR(query, candidates, testCase.lambda, 2))
+
+		if !reflect.DeepEqual(got, testCase.want) {
+			t.Errorf("MMR(lambda=%v) = %v, want %v", testCase.lambda, got, testCase.want)
+		}
+	}
+
+	if got := MMR(query, candidates, 0.5, 10); len(got) != 3 {
+		t.Errorf("MMR(k=10) = %v results, want %v", len(got), 3)
+	}
+}
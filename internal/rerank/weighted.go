@@ -0,0 +1,26 @@
+package rerank
+
+import (
+	"sort"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
+)
+
+// Weighted re-ranks candidates by their weighted distance to query. The returned hits
+// carry the weighted distance and are ordered by it, ties broken by id
+func Weighted(query []float32, candidates []store.Hit, weights vectors.Weights) []store.Hit {
+	ranked := make([]store.Hit, len(candidates))
+	for i, candidate := range candidates {
+		ranked[i] = store.Hit{Player: candidate.Player, Distance: weights.Distance(query, candidate.Player.Vector)}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Distance != ranked[j].Distance {
+			return ranked[i].Distance < ranked[j].Distance
+		}
+		return ranked[i].Player.ID < ranked[j].Player.ID
+	})
+
+	return ranked
+}
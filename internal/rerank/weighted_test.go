@@ -0,0 +1,39 @@
+package rerank
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
+)
+
+func TestWeighted(t *testing.T) {
+	// a matches the query on the first dimension, b on the second
+	points := map[string][]float32{
+		"a": {0, 1},
+		"b": {0.9, 0},
+	}
+	candidates := hits(points, "a", "b")
+	query := []float32{0, 0}
+
+	testCases := []struct {
+		weights vectors.Weights
+		want    []string
+	}{
+		{vectors.Weights{1, 1}, []string{"b", "a"}},
+		{vectors.Weights{1, 0.5}, []string{"a", "b"}},
+		{vectors.Weights{1, 0}, []string{"a", "b"}},
+	}
+
+	for _, testCase := range testCases {
+		got := Weighted(query, candidates, testCase.weights)
+		if !reflect.DeepEqual(ids(got), testCase.want) {
+			t.Errorf("Weighted(%v) = %v, want %v", testCase.weights, ids(got), testCase.want)
+		}
+	}
+
+	got := Weighted(query, candidates, vectors.Weights{2, 0.5})
+	if diff := got[1].Distance - 1.62; got[0].Distance != 0.5 || diff > 1e-6 || diff < -1e-6 {
+		t.Errorf("Weighted() distances = %v, %v, want 0.5, 1.62", got[0].Distance, got[1].Distance)
+	}
+}
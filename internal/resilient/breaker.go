@@ -0,0 +1,103 @@
+package resilient
+
+import (
+	"sync"
+	"time"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+)
+
+type breakerState int
+
+const (
+	closed breakerState = iota
+	open
+	halfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case open:
+		return "open"
+	case halfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// breaker is a circuit breaker. It opens after threshold consecutive failures and
+// fails requests fast for cooldown, then lets a single probe through which closes it
+// again when it succeeds
+type breaker struct {
+	mutex     sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     breakerState
+	failures  int
+	opened    time.Time
+	probing   bool
+	now       func() time.Time
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// allow returns store.ErrUnavailable when a request mustn't reach the store
+func (b *breaker) allow() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.state {
+	case open:
+		if b.now().Sub(b.opened) < b.cooldown {
+			return store.ErrUnavailable
+		}
+		b.state = halfOpen
+		fallthrough
+	case halfOpen:
+		if b.probing {
+			return store.ErrUnavailable
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// outcome is what a request let through tells of the store
+type outcome int
+
+const (
+	succeeded outcome = iota
+	failed
+	// the caller gave up, which tells nothing of the store
+	abandoned
+)
+
+// record counts the outcome of a request allow let through, and returns the state
+// and whether it changed
+func (b *breaker) record(o outcome) (breakerState, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	previous := b.state
+	switch {
+	case o == abandoned:
+		// a probe that was abandoned makes way for another
+		if b.state == halfOpen {
+			b.probing = false
+		}
+	case b.state == halfOpen && o == failed:
+		b.state, b.opened, b.probing = open, b.now(), false
+	case b.state == halfOpen:
+		b.state, b.failures, b.probing = closed, 0, false
+	case o == succeeded:
+		b.failures = 0
+	default:
+		// requests let through before the breaker opened don't open it again
+		if b.failures++; b.state == closed && b.failures >= b.threshold {
+			b.state, b.opened = open, b.now()
+		}
+	}
+	return b.state, b.state != previous
+}
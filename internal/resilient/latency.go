@@ -0,0 +1,54 @@
+package resilient
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// latencies kept to estimate the percentile from, the most recent ones
+	latencyWindow = 256
+	// latencies needed before there is an estimate, and between estimates
+	minLatencies   = 32
+	latencyRefresh = 16
+)
+
+// latencies estimates a percentile of the most recent latencies of an operation
+type latencies struct {
+	mutex      sync.Mutex
+	percentile float64
+	window     []time.Duration
+	next       int
+	added      int
+	estimate   time.Duration
+}
+
+func newLatencies(percentile float64) *latencies {
+	return &latencies{percentile: percentile, window: make([]time.Duration, 0, latencyWindow)}
+}
+
+func (l *latencies) add(latency time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if len(l.window) < latencyWindow {
+		l.window = append(l.window, latency)
+	} else {
+		l.window[l.next] = latency
+		l.next = (l.next + 1) % latencyWindow
+	}
+
+	if l.added++; len(l.window) >= minLatencies && l.added%latencyRefresh == 0 {
+		sorted := append([]time.Duration(nil), l.window...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		l.estimate = sorted[int(l.percentile*float64(len(sorted)-1))]
+	}
+}
+
+// get returns the estimated percentile, ok is false until there are enough latencies
+func (l *latencies) get() (time.Duration, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.estimate, l.estimate > 0
+}
@@ -0,0 +1,235 @@
+package resilient
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+)
+
+// Config are the deadlines, hedging and circuit breaking of a Store
+type Config struct {
+	// ReadTimeout and WriteTimeout bound every store call, ScanTimeout a page of a scan
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	ScanTimeout  time.Duration
+	// DeadlineShare is the share of the time left until the request's deadline a store
+	// call can take at most, the rest is left to answer the request
+	DeadlineShare float64
+	// HedgePercentile is the percentile of recent nearest neighbour latencies after
+	// which the query is sent again, 0 doesn't hedge. HedgeMinDelay is the least wait
+	HedgePercentile float64
+	HedgeMinDelay   time.Duration
+	// FailureThreshold consecutive failures open the circuit breaker for Cooldown
+	FailureThreshold int
+	Cooldown         time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		ReadTimeout:      2 * time.Second,
+		WriteTimeout:     10 * time.Second,
+		ScanTimeout:      30 * time.Second,
+		DeadlineShare:    0.8,
+		HedgePercentile:  0.95,
+		HedgeMinDelay:    10 * time.Millisecond,
+		FailureThreshold: 5,
+		Cooldown:         10 * time.Second,
+	}
+}
+
+// Store bounds every call to a store by a deadline, hedges slow nearest neighbour
+// queries and fails fast with store.ErrUnavailable while the store is degraded
+type Store struct {
+	primary   store.Store
+	hedge     store.Store
+	config    Config
+	breaker   *breaker
+	latencies *latencies
+}
+
+// New returns primary made resilient. Slow queries are hedged to hedge, a replica of
+// primary, and not at all when it is nil
+func New(primary, hedge store.Store, config Config) *Store {
+	return &Store{
+		primary:   primary,
+		hedge:     hedge,
+		config:    config,
+		breaker:   newBreaker(config.FailureThreshold, config.Cooldown),
+		latencies: newLatencies(config.HedgePercentile),
+	}
+}
+
+// deadline returns the context of a store call, its deadline is timeout away unless
+// the request's deadline leaves less. shortened reports whether it does
+func (s *Store) deadline(ctx context.Context, timeout time.Duration) (callCtx context.Context, cancel context.CancelFunc, shortened bool) {
+	if deadline, ok := ctx.Deadline(); ok {
+		if share := time.Duration(float64(time.Until(deadline)) * s.config.DeadlineShare); share < timeout {
+			timeout, shortened = share, true
+		}
+	}
+
+	callCtx, cancel = context.WithTimeout(ctx, timeout)
+	return callCtx, cancel, shortened
+}
+
+// call runs a store call under the breaker and a deadline of timeout
+func (s *Store) call(ctx context.Context, timeout time.Duration, fn func(context.Context) error) error {
+	if err := s.breaker.allow(); err != nil {
+		return err
+	}
+
+	callCtx, cancel, shortened := s.deadline(ctx, timeout)
+	defer cancel()
+
+	err := fn(callCtx)
+
+	// missing players are answers, and only a store that took its full timeout is slow
+	o := succeeded
+	switch {
+	case ctx.Err() != nil:
+		o = abandoned
+	case shortened && errors.Is(callCtx.Err(), context.DeadlineExceeded):
+		o = abandoned
+	case err != nil && !errors.Is(err, store.ErrNotFound):
+		o = failed
+	}
+
+	if state, changed := s.breaker.record(o); changed {
+		slog.WarnContext(ctx, "resilient: circuit breaker changed state", "state", state, "err", err)
+	}
+	return err
+}
+
+func (s *Store) Upsert(ctx context.Context, players []*store.Player) error {
+	return s.call(ctx, s.config.WriteTimeout, func(ctx context.Context) error {
+		return s.primary.Upsert(ctx, players)
+	})
+}
+
+func (s *Store) Delete(ctx context.Context, ids []string) error {
+	return s.call(ctx, s.config.WriteTimeout, func(ctx context.Context) error {
+		return s.primary.Delete(ctx, ids)
+	})
+}
+
+func (s *Store) Get(ctx context.Context, id string) (player *store.Player, err error) {
+	err = s.call(ctx, s.config.ReadTimeout, func(ctx context.Context) error {
+		player, err = s.primary.Get(ctx, id)
+		return err
+	})
+	return player, err
+}
+
+func (s *Store) History(ctx context.Context, id string) (players []*store.Player, err error) {
+	err = s.call(ctx, s.config.ReadTimeout, func(ctx context.Context) error {
+		players, err = s.primary.History(ctx, id)
+		return err
+	})
+	return players, err
+}
+
+func (s *Store) Scan(ctx context.Context, cursor string, limit int) (players []*store.Player, next string, err error) {
+	err = s.call(ctx, s.config.ScanTimeout, func(ctx context.Context) error {
+		players, next, err = s.primary.Scan(ctx, cursor, limit)
+		return err
+	})
+	return players, next, err
+}
+
+func (s *Store) Count(ctx context.Context) (count int, err error) {
+	err = s.call(ctx, s.config.ReadTimeout, func(ctx context.Context) error {
+		count, err = s.primary.Count(ctx)
+		return err
+	})
+	return count, err
+}
+
+// NearVector sends the query to hedge as well when primary hasn't answered within
+// the percentile of recent latencies of primary, the first answer wins
+func (s *Store) NearVector(ctx context.Context, query store.Query) (hits []store.Hit, err error) {
+	err = s.call(ctx, s.config.ReadTimeout, func(ctx context.Context) error {
+		hits, err = s.nearVector(ctx, query)
+		return err
+	})
+	return hits, err
+}
+
+// primaryNearVector queries primary and learns its latency from the answer
+func (s *Store) primaryNearVector(ctx context.Context, query store.Query) ([]store.Hit, error) {
+	start := time.Now()
+	hits, err := s.primary.NearVector(ctx, query)
+	if err == nil {
+		s.latencies.add(time.Since(start))
+	}
+	return hits, err
+}
+
+type nearVectorResult struct {
+	hits []store.Hit
+	err  error
+}
+
+func (s *Store) nearVector(ctx context.Context, query store.Query) ([]store.Hit, error) {
+	delay, ok := s.latencies.get()
+	if s.hedge == nil || s.config.HedgePercentile == 0 || !ok {
+		return s.primaryNearVector(ctx, query)
+	}
+
+	if delay < s.config.HedgeMinDelay {
+		delay = s.config.HedgeMinDelay
+	}
+
+	// a hedge that loses is canceled once there is an answer, the primary goes on until
+	// the call's deadline so its latency is learnt. Results never block their goroutine
+	primaryCtx, cancelPrimary := context.WithoutCancel(ctx), context.CancelFunc(func() {})
+	if deadline, ok := ctx.Deadline(); ok {
+		primaryCtx, cancelPrimary = context.WithDeadline(primaryCtx, deadline)
+	}
+
+	hedgeCtx, cancelHedge := context.WithCancel(ctx)
+	defer cancelHedge()
+
+	results := make(chan nearVectorResult, 2)
+	go func() {
+		defer cancelPrimary()
+		hits, err := s.primaryNearVector(primaryCtx, query)
+		results <- nearVectorResult{hits, err}
+	}()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case result := <-results:
+		return result.hits, result.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		go func() {
+			hits, err := s.hedge.NearVector(hedgeCtx, query)
+			results <- nearVectorResult{hits, err}
+		}()
+	}
+
+	receive := func() nearVectorResult {
+		select {
+		case result := <-results:
+			return result
+		case <-ctx.Done():
+			return nearVectorResult{err: ctx.Err()}
+		}
+	}
+
+	first := receive()
+	if first.err == nil {
+		return first.hits, nil
+	}
+
+	if second := receive(); second.err == nil {
+		return second.hits, nil
+	}
+	return nil, first.err
+}
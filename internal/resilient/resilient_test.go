@@ -0,0 +1,288 @@
+package resilient
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+)
+
+// stubStore answers nearest neighbour queries after delay with err, or with a hit of
+// name. Its other methods fail with err
+type stubStore struct {
+	*store.Memory
+	name    string
+	delay   atomic.Int64
+	err     error
+	queries atomic.Int64
+}
+
+func newStubStore(name string, delay time.Duration, err error) *stubStore {
+	s := &stubStore{Memory: store.NewMemory(), name: name, err: err}
+	s.delay.Store(int64(delay))
+	return s
+}
+
+func (s *stubStore) NearVector(ctx context.Context, query store.Query) ([]store.Hit, error) {
+	s.queries.Add(1)
+	select {
+	case <-time.After(time.Duration(s.delay.Load())):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if s.err != nil {
+		return nil, s.err
+	}
+	return []store.Hit{{Player: &store.Player{ID: s.name}}}, nil
+}
+
+func (s *stubStore) Get(ctx context.Context, id string) (*store.Player, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.Memory.Get(ctx, id)
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	b := newBreaker(3, 10*time.Second)
+	b.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		b.allow()
+		b.record(failed)
+	}
+	b.allow()
+	b.record(succeeded)
+
+	// failures must be consecutive
+	for i := 0; i < 3; i++ {
+		if err := b.allow(); err != nil {
+			t.Fatalf("allow() after %d failures error = %v, want nil", i, err)
+		}
+		b.record(failed)
+	}
+
+	if err := b.allow(); !errors.Is(err, store.ErrUnavailable) {
+		t.Fatalf("allow() of an open breaker error = %v, want store.ErrUnavailable", err)
+	}
+
+	// after the cooldown a single probe goes through
+	now = now.Add(10 * time.Second)
+	if err := b.allow(); err != nil {
+		t.Fatalf("allow() of the probe error = %v, want nil", err)
+	}
+	if err := b.allow(); !errors.Is(err, store.ErrUnavailable) {
+		t.Errorf("allow() during the probe error = %v, want store.ErrUnavailable", err)
+	}
+
+	// a failed probe opens the breaker again, an abandoned one makes way for another
+	if state, _ := b.record(failed); state != open {
+		t.Errorf("state after a failed probe = %v, want open", state)
+	}
+
+	now = now.Add(10 * time.Second)
+	b.allow()
+	if state, _ := b.record(abandoned); state != halfOpen {
+		t.Errorf("state after an abandoned probe = %v, want half-open", state)
+	}
+
+	b.allow()
+	if state, changed := b.record(succeeded); state != closed || !changed {
+		t.Errorf("state after a successful probe = %v, %v, want closed", state, changed)
+	}
+}
+
+func TestStoreBreaker(t *testing.T) {
+	ctx := context.Background()
+	config := DefaultConfig()
+	config.FailureThreshold = 2
+
+	primary := newStubStore("primary", 0, errors.New("weaviate: 503 service unavailable"))
+	s := New(primary, nil, config)
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.Get(ctx, "6844b415-aa94-43c9-8823-9389e4816902"); errors.Is(err, store.ErrUnavailable) {
+			t.Fatalf("Get() %d error = %v, want the store's error", i, err)
+		}
+	}
+
+	if _, err := s.NearVector(ctx, store.Query{}); !errors.Is(err, store.ErrUnavailable) {
+		t.Errorf("NearVector() of a degraded store error = %v, want store.ErrUnavailable", err)
+	}
+
+	if primary.queries.Load() != 0 {
+		t.Errorf("queries = %d, want the open breaker to fail fast", primary.queries.Load())
+	}
+
+	// missing players don't open the breaker
+	s = New(newStubStore("primary", 0, nil), nil, config)
+	for i := 0; i < 3; i++ {
+		if _, err := s.Get(ctx, "unknown"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Get() of a missing player error = %v, want store.ErrNotFound", err)
+		}
+	}
+}
+
+func TestStoreDeadline(t *testing.T) {
+	config := DefaultConfig()
+	config.ReadTimeout = time.Second
+	s := New(newStubStore("primary", time.Minute, nil), nil, config)
+
+	// a call gets the smaller of its timeout and a share of the request's time left
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	callCtx, callCancel, shortened := s.deadline(ctx, config.ReadTimeout)
+	defer callCancel()
+
+	if !shortened {
+		t.Errorf("deadline() shortened = false, want the request's deadline to shorten it")
+	}
+
+	deadline, _ := callCtx.Deadline()
+	if left := time.Until(deadline); left > 80*time.Millisecond {
+		t.Errorf("time left = %v, want at most 80ms", left)
+	}
+
+	start := time.Now()
+	if _, err := s.NearVector(ctx, store.Query{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("NearVector() of a slow store error = %v, want context.DeadlineExceeded", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("NearVector() took %v, want less than the request's deadline", elapsed)
+	}
+}
+
+func TestStoreHedge(t *testing.T) {
+	ctx := context.Background()
+	config := DefaultConfig()
+	config.HedgeMinDelay = time.Millisecond
+
+	primary := newStubStore("primary", 0, nil)
+	replica := newStubStore("replica", 0, nil)
+	s := New(primary, replica, config)
+
+	// learn the usual latency, queries answered in time aren't hedged
+	for i := 0; i < minLatencies; i++ {
+		if _, err := s.NearVector(ctx, store.Query{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if replica.queries.Load() != 0 {
+		t.Fatalf("replica queries = %d, want 0", replica.queries.Load())
+	}
+
+	primary.delay.Store(int64(time.Second))
+	start := time.Now()
+
+	hits, err := s.NearVector(ctx, store.Query{})
+	if err != nil {
+		t.Fatalf("NearVector() error = %v, want nil", err)
+	}
+
+	if len(hits) != 1 || hits[0].Player.ID != "replica" {
+		t.Errorf("NearVector() = %v, want the replica's answer", hits)
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("NearVector() took %v, want the hedge to answer long before the primary", elapsed)
+	}
+}
+
+func TestStoreShortenedDeadline(t *testing.T) {
+	config := DefaultConfig()
+	config.ReadTimeout = time.Second
+	config.FailureThreshold = 1
+	primary := newStubStore("primary", time.Minute, nil)
+	s := New(primary, nil, config)
+
+	// running out of the request's time says nothing about the store
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := s.NearVector(ctx, store.Query{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("NearVector() of a slow store error = %v, want context.DeadlineExceeded", err)
+	}
+
+	primary.delay.Store(0)
+	if _, err := s.NearVector(context.Background(), store.Query{}); err != nil {
+		t.Errorf("NearVector() after a shortened deadline ran out error = %v, want the breaker closed", err)
+	}
+
+	// the full timeout running out does
+	config.ReadTimeout = 20 * time.Millisecond
+	primary.delay.Store(int64(time.Minute))
+	s = New(primary, nil, config)
+	s.NearVector(context.Background(), store.Query{})
+	if _, err := s.NearVector(context.Background(), store.Query{}); !errors.Is(err, store.ErrUnavailable) {
+		t.Errorf("NearVector() after the read timeout ran out error = %v, want store.ErrUnavailable", err)
+	}
+}
+
+func TestStoreHedgeWithoutReplica(t *testing.T) {
+	ctx := context.Background()
+	config := DefaultConfig()
+	config.HedgeMinDelay = time.Millisecond
+
+	primary := newStubStore("primary", 0, nil)
+	s := New(primary, nil, config)
+	for i := 0; i < minLatencies; i++ {
+		if _, err := s.NearVector(ctx, store.Query{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	primary.delay.Store(int64(50 * time.Millisecond))
+	if _, err := s.NearVector(ctx, store.Query{}); err != nil {
+		t.Fatalf("NearVector() error = %v, want nil", err)
+	}
+
+	if got := primary.queries.Load(); got != minLatencies+1 {
+		t.Errorf("primary queries = %d, want %d, a slow query isn't sent again", got, minLatencies+1)
+	}
+}
+
+func TestStoreHedgePrimaryLatency(t *testing.T) {
+	ctx := context.Background()
+	config := DefaultConfig()
+	config.HedgeMinDelay = time.Millisecond
+
+	primary := newStubStore("primary", 0, nil)
+	replica := newStubStore("replica", 0, nil)
+	s := New(primary, replica, config)
+	for i := 0; i < minLatencies; i++ {
+		if _, err := s.NearVector(ctx, store.Query{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	primary.delay.Store(int64(100 * time.Millisecond))
+	if hits, err := s.NearVector(ctx, store.Query{}); err != nil || hits[0].Player.ID != "replica" {
+		t.Fatalf("NearVector() = %v, %v, want the replica's answer", hits, err)
+	}
+
+	// the primary answers after the hedge won, its latency is the one learnt
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		s.latencies.mutex.Lock()
+		latest := s.latencies.window[len(s.latencies.window)-1]
+		added := s.latencies.added
+		s.latencies.mutex.Unlock()
+
+		if added == minLatencies+1 {
+			if latest < 100*time.Millisecond {
+				t.Errorf("latency learnt = %v, want the primary's 100ms or more", latest)
+			}
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("latencies learnt = %d, want the primary's latency learnt", added)
+		}
+	}
+}
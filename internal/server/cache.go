@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/cache"
+	pb "github.com/eliassebastian/r6index-recommendation/pkg/proto/server"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// how old cached results can be to still be served while the store is degraded
+const maxStaleFallback = 15 * time.Minute
+
+// how long a coalesced recommendation query can run, it outlives the requests waiting on it
+const recommendationLoadTimeout = 10 * time.Second
+
+// recommendationCache holds recommendation responses by generation and request,
+// grouped by the query player
+type recommendationCache = cache.Cache[string, *pb.RecommendResponse]
+
+// WithRecommendationCache caches up to capacity recommendation responses. A player's
+// responses are dropped once they are written or their exclusions change, changes to
+// the players recommended are picked up within ttl. With coalesce concurrent
+// identical requests query the store once. While the store is degraded expired
+// responses are served for up to maxStaleFallback
+func WithRecommendationCache(capacity int, ttl time.Duration, coalesce bool) Option {
+	return func(s *RecommendationServer) {
+		s.cache = cache.New[string, *pb.RecommendResponse](capacity, ttl, coalesce, recommendationLoadTimeout)
+	}
+}
+
+// cached returns the response of the request from the cache or from recommend, which
+// queries with the context it is given. player is the query player, empty when the
+// request isn't for a stored player
+func (s *RecommendationServer) cached(ctx context.Context, player string, in proto.Message, recommend func(context.Context, *generation) (*pb.RecommendResponse, error)) (*pb.RecommendResponse, error) {
+	g := s.generation()
+	if s.cache == nil {
+		return recommend(ctx, g)
+	}
+
+	raw, err := proto.MarshalOptions{Deterministic: true}.Marshal(in)
+	if err != nil {
+		return recommend(ctx, g)
+	}
+
+	// a re-index or rollback switches generations, their results never mix
+	key := fmt.Sprintf("%s/%d/%s/%s", g.name, g.schema.Version, in.ProtoReflect().Descriptor().FullName(), raw)
+	response, err := s.cache.Get(ctx, key, player, func(ctx context.Context) (*pb.RecommendResponse, error) {
+		return recommend(ctx, g)
+	})
+
+	// while the store is degraded, results a little out of date beat none at all
+	if code := status.Code(err); code == 503 || code == 504 {
+		if stale, ok := s.cache.Stale(key, maxStaleFallback); ok {
+			slog.WarnContext(ctx, "serving cached recommendations, the store is degraded", "code", int(code))
+			response, err = stale, nil
+		}
+	}
+
+	if err != nil {
+		return &pb.RecommendResponse{}, err
+	}
+
+	// responses are shared between requests, callers get their own copy
+	return proto.Clone(response).(*pb.RecommendResponse), nil
+}
+
+// invalidate drops the cached responses of the players
+func (s *RecommendationServer) invalidate(players ...string) {
+	if s.cache != nil {
+		s.cache.Invalidate(players...)
+	}
+}
+
+// purge drops every cached response
+func (s *RecommendationServer) purge() {
+	if s.cache != nil {
+		s.cache.Purge()
+	}
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/audit"
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+	pb "github.com/eliassebastian/r6index-recommendation/pkg/proto/server"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// countingStore counts the nearest neighbour queries that reach the store
+type countingStore struct {
+	*store.Memory
+	queries atomic.Int64
+}
+
+func (s *countingStore) NearVector(ctx context.Context, query store.Query) ([]store.Hit, error) {
+	s.queries.Add(1)
+	return s.Memory.NearVector(ctx, query)
+}
+
+func TestRecommendationServiceServer_RecommendationCache(t *testing.T) {
+	ctx := context.Background()
+	counting := &countingStore{Memory: store.NewMemory()}
+	recommendationServer := NewRecommendationServer(counting, audit.New(io.Discard), 1, time.Minute, WithRecommendationCache(100, time.Minute, true))
+	client := newTestClient(t, recommendationServer)
+
+	for _, player := range testPlayers {
+		if _, err := client.Index(ctx, player); err != nil {
+			t.Fatalf("Index() error = %v, want nil", err)
+		}
+	}
+
+	id := "6844b415-aa94-43c9-8823-9389e4816918"
+	recommend := func(limit int32) []string {
+		t.Helper()
+		response, err := client.Recommend(ctx, &pb.RecommendRequest{Id: id, Limit: limit})
+		if err != nil {
+			t.Fatalf("Recommend() error = %v, want nil", err)
+		}
+		return recommendationIDs(response)
+	}
+
+	first := recommend(3)
+	if got := recommend(3); len(got) != len(first) || counting.queries.Load() != 1 {
+		t.Fatalf("Recommend() again = %v after %d queries, want %v from the cache", got, counting.queries.Load(), first)
+	}
+
+	// another limit is another query
+	if recommend(2); counting.queries.Load() != 2 {
+		t.Errorf("queries after another limit = %d, want 2", counting.queries.Load())
+	}
+
+	// blocking the top result drops the cached results and the player
+	if _, err := client.Block(ctx, &pb.BlockRequest{Id: id, BlockedId: first[0]}); err != nil {
+		t.Fatalf("Block() error = %v, want nil", err)
+	}
+
+	after := recommend(3)
+	if counting.queries.Load() != 3 || after[0] == first[0] {
+		t.Errorf("Recommend() after Block() = %v after %d queries, want a new query without %s", after, counting.queries.Load(), first[0])
+	}
+
+	// re-indexing the query player drops its cached results
+	if _, err := client.Index(ctx, &pb.Request{Id: id, Level: 300, Kost: 0.55, Rank: 18, RankPoints: 1250, Season: 30}); err != nil {
+		t.Fatalf("Index() error = %v, want nil", err)
+	}
+
+	if recommend(3); counting.queries.Load() != 4 {
+		t.Errorf("queries after re-indexing the player = %d, want 4", counting.queries.Load())
+	}
+
+	// an erasure purges every player's results, the erased player may be among them
+	if _, err := client.Delete(ctx, &pb.DeleteRequest{Id: after[0], Erasure: true}); err != nil {
+		t.Fatalf("Delete() error = %v, want nil", err)
+	}
+
+	for _, got := range recommend(3) {
+		if got == after[0] {
+			t.Errorf("Recommend() after erasure = %v, want no %s", got, after[0])
+		}
+	}
+}
+
+// degradableStore fails nearest neighbour queries like an open circuit breaker once degraded
+type degradableStore struct {
+	*store.Memory
+	degraded atomic.Bool
+}
+
+func (s *degradableStore) NearVector(ctx context.Context, query store.Query) ([]store.Hit, error) {
+	if s.degraded.Load() {
+		return nil, store.ErrUnavailable
+	}
+	return s.Memory.NearVector(ctx, query)
+}
+
+func TestRecommendationServiceServer_RecommendationCacheFallback(t *testing.T) {
+	ctx := context.Background()
+	degradable := &degradableStore{Memory: store.NewMemory()}
+
+	// entries expire right away, they are only served while the store is degraded
+	recommendationServer := NewRecommendationServer(degradable, audit.New(io.Discard), 1, time.Minute, WithRecommendationCache(100, time.Nanosecond, false))
+	client := newTestClient(t, recommendationServer)
+
+	for _, player := range testPlayers {
+		if _, err := client.Index(ctx, player); err != nil {
+			t.Fatalf("Index() error = %v, want nil", err)
+		}
+	}
+
+	request := &pb.RecommendRequest{Id: "6844b415-aa94-43c9-8823-9389e4816918", Limit: 3}
+	response, err := client.Recommend(ctx, request)
+	if err != nil {
+		t.Fatalf("Recommend() error = %v, want nil", err)
+	}
+	want := recommendationIDs(response)
+
+	degradable.degraded.Store(true)
+
+	response, err = client.Recommend(ctx, request)
+	if err != nil {
+		t.Fatalf("Recommend() of a degraded store error = %v, want the cached results", err)
+	}
+
+	if got := recommendationIDs(response); len(got) != len(want) || got[0] != want[0] {
+		t.Errorf("Recommend() of a degraded store = %v, want %v", got, want)
+	}
+
+	// nothing cached to fall back to
+	_, err = client.Recommend(ctx, &pb.RecommendRequest{Id: request.GetId(), Limit: 4})
+	if status.Code(err) != codes.Code(503) {
+		t.Errorf("Recommend() of a degraded store without cached results error = %v, want code 503", err)
+	}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	pb "github.com/eliassebastian/r6index-recommendation/pkg/proto/server"
+	"google.golang.org/grpc/status"
+)
+
+func (s *RecommendationServer) Block(ctx context.Context, in *pb.BlockRequest) (*pb.Response, error) {
+
+	if in.GetId() == "" || in.GetBlockedId() == "" {
+		return &pb.Response{}, status.Error(400, "id = empty player id")
+	}
+
+	if err := s.exclusions.Block(in.GetId(), in.GetBlockedId()); err != nil {
+		slog.ErrorContext(ctx, "recording exclusion", "action", "block", "err", err)
+		return &pb.Response{}, status.Error(500, "exclusions = could not record block")
+	}
+
+	s.invalidate(in.GetId(), in.GetBlockedId())
+
+	return &pb.Response{
+		Code:    200,
+		Message: "OK",
+	}, nil
+}
+
+func (s *RecommendationServer) Unblock(ctx context.Context, in *pb.BlockRequest) (*pb.Response, error) {
+
+	if in.GetId() == "" || in.GetBlockedId() == "" {
+		return &pb.Response{}, status.Error(400, "id = empty player id")
+	}
+
+	if err := s.exclusions.Unblock(in.GetId(), in.GetBlockedId()); err != nil {
+		slog.ErrorContext(ctx, "recording exclusion", "action", "unblock", "err", err)
+		return &pb.Response{}, status.Error(500, "exclusions = could not record unblock")
+	}
+
+	s.invalidate(in.GetId(), in.GetBlockedId())
+
+	return &pb.Response{
+		Code:    200,
+		Message: "OK",
+	}, nil
+}
+
+func (s *RecommendationServer) RecordTeammates(ctx context.Context, in *pb.TeammatesRequest) (*pb.Response, error) {
+
+	if len(in.GetIds()) < 2 {
+		return &pb.Response{}, status.Error(400, "ids = need at least two players")
+	}
+
+	for _, id := range in.GetIds() {
+		if id == "" {
+			return &pb.Response{}, status.Error(400, "ids = empty player id")
+		}
+	}
+
+	if err := s.exclusions.AddTeammates(in.GetIds()...); err != nil {
+		slog.ErrorContext(ctx, "recording exclusion", "action", "teammates", "err", err)
+		return &pb.Response{}, status.Error(500, "exclusions = could not record teammates")
+	}
+
+	s.invalidate(in.GetIds()...)
+
+	return &pb.Response{
+		Code:    200,
+		Message: "OK",
+	}, nil
+}
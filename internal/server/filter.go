@@ -0,0 +1,74 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+	pb "github.com/eliassebastian/r6index-recommendation/pkg/proto/server"
+	"google.golang.org/grpc/status"
+)
+
+// filterProperties maps proto field names onto store property names
+var filterProperties = map[string]string{
+	"platform":    "platform",
+	"region":      "region",
+	"language":    "language",
+	"last_seen":   "lastSeen",
+	"level":       "level",
+	"kost":        "kost",
+	"rank":        "rank",
+	"rank_points": "rankPoints",
+	"season":      "season",
+}
+
+var filterOperators = map[pb.FilterOperator]store.Operator{
+	pb.FilterOperator_EQUAL:              store.Equal,
+	pb.FilterOperator_NOT_EQUAL:          store.NotEqual,
+	pb.FilterOperator_GREATER_THAN:       store.GreaterThan,
+	pb.FilterOperator_GREATER_THAN_EQUAL: store.GreaterThanEqual,
+	pb.FilterOperator_LESS_THAN:          store.LessThan,
+	pb.FilterOperator_LESS_THAN_EQUAL:    store.LessThanEqual,
+	pb.FilterOperator_IN:                 store.In,
+}
+
+// filterFromRequest converts request filters into a validated store filter
+func filterFromRequest(in []*pb.Filter) (store.Filter, error) {
+	filter := make(store.Filter, 0, len(in))
+
+	for _, f := range in {
+		property, ok := filterProperties[f.GetProperty()]
+		if !ok {
+			return nil, status.Errorf(400, "filters = unknown property %q", f.GetProperty())
+		}
+
+		operator, ok := filterOperators[f.GetOperator()]
+		if !ok {
+			return nil, status.Errorf(400, "filters = unknown operator %v", f.GetOperator())
+		}
+
+		condition := store.Condition{Property: property, Operator: operator}
+
+		switch store.FilterableProperties[property] {
+		case store.TextProperty:
+			condition.Value = f.GetText()
+			for _, text := range f.GetTexts() {
+				condition.Values = append(condition.Values, text)
+			}
+		case store.NumberProperty:
+			condition.Value = f.GetNumber()
+			for _, number := range f.GetNumbers() {
+				condition.Values = append(condition.Values, number)
+			}
+		case store.DateProperty:
+			condition.Value = f.GetTime().AsTime()
+		}
+
+		filter = append(filter, condition)
+	}
+
+	if err := filter.Validate(); err != nil {
+		return nil, status.Error(400, fmt.Sprintf("filters = %v", err))
+	}
+
+	return filter, nil
+}
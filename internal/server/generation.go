@@ -0,0 +1,75 @@
+package server
+
+import (
+	"log/slog"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/reindex"
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
+)
+
+// generation is a store together with the schema its vectors are built with. A re-index
+// swaps both at once, every request works on the generation it started with
+type generation struct {
+	// name of the collection store reads, empty when the server can't re-index
+	name   string
+	store  store.Store
+	schema vectors.Schema
+	// backfill is the job copying into a shadow generation, writes mirrored into the
+	// shadow touch it
+	backfill *reindex.Job
+}
+
+// generation returns the generation reads and writes currently go to. It follows the
+// collections when they switch without this server, e.g. another replica promoted
+func (s *RecommendationServer) generation() *generation {
+	g := s.active.Load()
+	if s.reindex == nil {
+		return g
+	}
+
+	name, version := s.reindex.collections.Active()
+	if name == g.name {
+		return g
+	}
+
+	schema, ok := vectors.Schemas[version]
+	if !ok {
+		schema = g.schema
+	}
+
+	switched := &generation{name: name, store: g.store, schema: schema}
+	if !s.active.CompareAndSwap(g, switched) {
+		return s.active.Load()
+	}
+
+	slog.Info("reindex: following the collections to another generation", "from", g.name, "to", name, "schema_version", version, "known_schema", ok)
+	return switched
+}
+
+// vectorize is the single path from raw stats to the vector that is stored and queried
+func (g *generation) vectorize(stats vectors.Player) []float32 {
+	return g.schema.Vector(stats)
+}
+
+// storedVector returns the player's stored vector, players indexed under another
+// schema are vectorized again from their raw stats so they can still be queried
+func (g *generation) storedVector(player *store.Player) []float32 {
+	if player.SchemaVersion != g.schema.Version {
+		return g.vectorize(player.Stats)
+	}
+	return player.Vector
+}
+
+// prepare returns the player as stored in this generation, players vectorized with
+// another schema are copied with a vector of this one
+func (g *generation) prepare(player *store.Player) *store.Player {
+	if player.SchemaVersion == g.schema.Version {
+		return player
+	}
+
+	prepared := *player
+	prepared.Vector = g.vectorize(player.Stats)
+	prepared.SchemaVersion = g.schema.Version
+	return &prepared
+}
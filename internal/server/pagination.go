@@ -0,0 +1,107 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+	pb "github.com/eliassebastian/r6index-recommendation/pkg/proto/server"
+	"google.golang.org/protobuf/proto"
+)
+
+// errInvalidPageToken is returned for tokens that weren't issued by the server
+var errInvalidPageToken = errors.New("invalid page token")
+
+// pageToken is handed out base64 encoded and signed so callers treat it as opaque and
+// can't forge one. It keeps the query vector the first page was built from, later
+// pages use that snapshot even if the query player has been re-indexed since
+type pageToken struct {
+	Vector []float32 `json:"v"`
+	// the last result served, the next page starts strictly after it
+	AfterDistance float32 `json:"d"`
+	AfterID       string  `json:"i"`
+	Served        int     `json:"n"`
+	// fingerprint of the filters the token was issued for
+	Filters string `json:"f"`
+	// schema the vector snapshot was built with
+	SchemaVersion int `json:"s"`
+	// RPC and query player the token was issued for, so it can't be replayed on another
+	Scope string `json:"p"`
+}
+
+// pageScope is the scope of a page token of method for player, empty when the query
+// isn't of a stored player
+func pageScope(method, player string) string {
+	return method + "/" + player
+}
+
+// encodePageToken returns the token and its HMAC-SHA256 signature by key
+func encodePageToken(key []byte, token pageToken) string {
+	raw, _ := json.Marshal(token)
+	payload := base64.RawURLEncoding.EncodeToString(raw)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(sign(key, payload))
+}
+
+// decodePageToken returns the token if it was signed by key, it isn't validated further
+func decodePageToken(key []byte, encoded string) (*pageToken, error) {
+	payload, signature, ok := strings.Cut(encoded, ".")
+	if !ok {
+		return nil, errInvalidPageToken
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, sign(key, payload)) {
+		return nil, errInvalidPageToken
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var token pageToken
+	if err := json.Unmarshal(raw, &token); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func sign(key []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// filtersFingerprint identifies a set of request filters, so a page token can't be
+// replayed with different ones
+func filtersFingerprint(filters []*pb.Filter) string {
+	hash := sha256.New()
+	for _, filter := range filters {
+		raw, _ := proto.MarshalOptions{Deterministic: true}.Marshal(filter)
+		hash.Write(raw)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)[:8])
+}
+
+// sortHits orders hits by distance and then id, so equal distances page deterministically
+func sortHits(hits []store.Hit) {
+	sort.SliceStable(hits, func(i, j int) bool {
+		return ordered(hits[i].Distance, hits[i].Player.ID, hits[j].Distance, hits[j].Player.ID)
+	})
+}
+
+// ordered reports whether result a comes before result b
+func ordered(aDistance float32, aID string, bDistance float32, bID string) bool {
+	if aDistance != bDistance {
+		return aDistance < bDistance
+	}
+	return aID < bID
+}
@@ -0,0 +1,19 @@
+package server
+
+import "github.com/eliassebastian/r6index-recommendation/internal/auth"
+
+// Permissions are what each method requires of a client, admin methods are left out
+// as the authenticator requires auth.Admin of every method it doesn't know
+var Permissions = map[string]auth.Permission{
+	"/RecommendationService/GetPlayer":        auth.Read,
+	"/RecommendationService/Recommend":        auth.Read,
+	"/RecommendationService/RecommendByStats": auth.Read,
+	"/RecommendationService/BuildSquad":       auth.Read,
+
+	"/RecommendationService/Index":           auth.Index,
+	"/RecommendationService/Delete":          auth.Index,
+	"/RecommendationService/BulkDelete":      auth.Index,
+	"/RecommendationService/Block":           auth.Index,
+	"/RecommendationService/Unblock":         auth.Index,
+	"/RecommendationService/RecordTeammates": auth.Index,
+}
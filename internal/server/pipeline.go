@@ -0,0 +1,218 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/batch"
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+)
+
+type operationKind int
+
+const (
+	upsertOperation operationKind = iota
+	deleteOperation
+)
+
+func (k operationKind) String() string {
+	if k == deleteOperation {
+		return "delete"
+	}
+	return "upsert"
+}
+
+// operation is a single write queued in the batch pipeline, requestID is the ID of
+// the request that queued it. A delete is of all its ids so a bulk request is flushed
+// as a unit
+type operation struct {
+	kind      operationKind
+	player    *store.Player
+	ids       []string
+	erasure   bool
+	requestID string
+}
+
+// flush writes a batch of operations to the store, consecutive operations of the same
+// kind are sent together so an Index followed by a Delete is applied in that order
+//
+// Writes go to the active generation and, while a re-index runs, to the generation it
+// builds too. The write lock keeps them ordered against re-index batches and the swap
+//
+// A failed write doesn't stop the batch. Its operations are retried with the next
+// flush, together with the later ones of the same players so those stay in order.
+// Operations the store rejects as invalid are logged and dropped, they would fail the
+// same way every time
+func (s *RecommendationServer) flush(items []interface{}) error {
+	ctx := context.Background()
+
+	s.writes.Lock()
+	defer s.writes.Unlock()
+
+	generations := []*generation{s.generation()}
+	if shadow := s.shadow.Load(); shadow != nil {
+		generations = append(generations, shadow)
+	}
+
+	// held are the players of operations being retried
+	held := map[string]struct{}{}
+	var retry []interface{}
+	var failure error
+	hold := func(ops []operation, err error) {
+		for _, op := range ops {
+			for _, id := range op.written() {
+				held[id] = struct{}{}
+			}
+			retry = append(retry, op)
+		}
+		if failure == nil {
+			failure = err
+		}
+	}
+
+	for start := 0; start < len(items); {
+		kind := items[start].(operation).kind
+
+		end := start
+		var ops []operation
+		for ; end < len(items) && items[end].(operation).kind == kind; end++ {
+			op := items[end].(operation)
+			if op.touches(held) {
+				hold([]operation{op}, nil)
+				continue
+			}
+			ops = append(ops, op)
+		}
+		start = end
+
+		if len(ops) == 0 {
+			continue
+		}
+
+		err := s.write(ctx, generations, kind, ops)
+		if err == nil {
+			s.applied(kind, ops)
+			continue
+		}
+
+		if !errors.Is(err, store.ErrRejected) {
+			hold(ops, err)
+			continue
+		}
+
+		// the store refused some of them, written one by one the others go through
+		for _, op := range ops {
+			switch err := s.write(ctx, generations, kind, []operation{op}); {
+			case err == nil:
+				s.applied(kind, []operation{op})
+			case errors.Is(err, store.ErrRejected):
+				slog.Error("dropping rejected write", "operation", kind, "players", op.written(), "request_id", op.requestID, "err", err)
+				if s.flushed != nil {
+					s.flushed([]operation{op}, false)
+				}
+			default:
+				hold([]operation{op}, err)
+			}
+		}
+	}
+
+	if len(retry) > 0 {
+		return &batch.RetryError{Items: retry, Err: failure}
+	}
+	return nil
+}
+
+// write writes operations of kind to every generation
+func (s *RecommendationServer) write(ctx context.Context, generations []*generation, kind operationKind, ops []operation) error {
+	var players []*store.Player
+	var ids, requestIDs []string
+	for _, op := range ops {
+		if op.player != nil {
+			players = append(players, op.player)
+		}
+		ids = append(ids, op.ids...)
+		requestIDs = append(requestIDs, op.requestID)
+	}
+
+	for _, g := range generations {
+		if g.backfill != nil {
+			g.backfill.Touch(writtenIDs(kind, players, ids)...)
+		}
+
+		var err error
+		switch kind {
+		case upsertOperation:
+			prepared := make([]*store.Player, 0, len(players))
+			for _, player := range players {
+				prepared = append(prepared, g.prepare(player))
+			}
+			err = g.store.Upsert(ctx, prepared)
+		case deleteOperation:
+			err = g.store.Delete(ctx, ids)
+		}
+
+		// the requests that queued a failed batch are logged to trace it back to them
+		if err != nil {
+			slog.Error("writing batch", "generation", g.name, "operation", kind, "items", len(ops), "request_ids", requestIDs, "err", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// applied updates what depends on the store once operations of kind were written
+func (s *RecommendationServer) applied(kind operationKind, ops []operation) {
+	if s.flushed != nil {
+		s.flushed(ops, true)
+	}
+
+	for _, op := range ops {
+		// cached recommendations of the written players are out of date, an erased
+		// player must not be served from any other player's either
+		switch {
+		case kind == upsertOperation:
+			s.invalidate(op.player.ID)
+		case op.erasure:
+			s.purge()
+		default:
+			s.invalidate(op.ids...)
+		}
+
+		// deleted players no longer belong to the population scores are calibrated on
+		if kind == deleteOperation {
+			s.calibrator.Forget(op.ids...)
+		}
+	}
+}
+
+// written returns the players op writes
+func (op operation) written() []string {
+	if op.kind == deleteOperation {
+		return op.ids
+	}
+	return []string{op.player.ID}
+}
+
+// touches reports whether op writes any of players
+func (op operation) touches(players map[string]struct{}) bool {
+	for _, id := range op.written() {
+		if _, ok := players[id]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// writtenIDs returns the players a batch of kind writes
+func writtenIDs(kind operationKind, players []*store.Player, ids []string) []string {
+	if kind == deleteOperation {
+		return ids
+	}
+
+	written := make([]string, 0, len(players))
+	for _, player := range players {
+		written = append(written, player.ID)
+	}
+	return written
+}
@@ -0,0 +1,405 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/rerank"
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
+	pb "github.com/eliassebastian/r6index-recommendation/pkg/proto/server"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultRecommendLimit = 5
+	maxRecommendLimit     = 100
+	// default number of candidates fetched per result when diversifying, and the
+	// default lambda
+	diversificationOverfetch = 4
+	diversificationLambda    = 0.7
+	// default number of candidates fetched per result when weighting, see
+	// WithWeightedOverfetch
+	defaultWeightedOverfetch = 4
+	maxCandidates            = 1000
+)
+
+// recommendParams are the options shared by every Recommend request
+type recommendParams interface {
+	GetLimit() int32
+	GetFilters() []*pb.Filter
+	GetDiversification() *pb.Diversification
+	GetExplain() bool
+	GetMinScore() float32
+	GetMaxDistance() float32
+	GetOffset() int32
+	GetPageToken() string
+	GetFeatureWeights() map[string]float32
+}
+
+// recommendQuery is a parsed and validated recommendParams
+type recommendQuery struct {
+	limit           int
+	filter          store.Filter
+	diversification *pb.Diversification
+	candidates      int
+	explain         bool
+	minScore        float32
+	maxDistance     float32
+	// pagination, a page starts after offset results or after the page token's cursor.
+	// Tokens are signed by key and only valid in scope
+	offset  int
+	token   *pageToken
+	filters string
+	key     []byte
+	scope   string
+	// weights re-rank the candidates by a weighted distance, nil when not weighted
+	weights vectors.Weights
+}
+
+func (s *RecommendationServer) Recommend(ctx context.Context, in *pb.RecommendRequest) (*pb.RecommendResponse, error) {
+
+	if in.GetId() == "" {
+		return &pb.RecommendResponse{}, status.Error(400, "id = empty player id")
+	}
+
+	// cached results are dropped when the player is written or their exclusions change
+	return s.cached(ctx, in.GetId(), in, func(ctx context.Context, g *generation) (*pb.RecommendResponse, error) {
+		query, err := s.parseRecommendParams(in, g.schema, pageScope("Recommend", in.GetId()))
+		if err != nil {
+			return &pb.RecommendResponse{}, err
+		}
+
+		player, vector, err := g.seasonVector(ctx, in.GetId(), int(in.GetQuerySeason()), in.GetSeasonBlend())
+		if err != nil {
+			return &pb.RecommendResponse{}, err
+		}
+
+		// the query player, blocked players and recent teammates are dropped and back-filled
+		return s.recommend(ctx, g, query.vector(vector), player.Stats, query, s.exclusions.Excluded(player.ID))
+	})
+}
+
+func (s *RecommendationServer) RecommendByStats(ctx context.Context, in *pb.RecommendByStatsRequest) (*pb.RecommendResponse, error) {
+
+	if in.GetProfile() == nil {
+		return &pb.RecommendResponse{}, status.Error(400, "profile = empty player profile")
+	}
+
+	return s.cached(ctx, "", in, func(ctx context.Context, g *generation) (*pb.RecommendResponse, error) {
+		query, err := s.parseRecommendParams(in, g.schema, pageScope("RecommendByStats", ""))
+		if err != nil {
+			return &pb.RecommendResponse{}, err
+		}
+
+		stats := statsFromRequest(in.GetProfile())
+		return s.recommend(ctx, g, query.vector(g.vectorize(stats)), stats, query, nil)
+	})
+}
+
+// recommend runs the nearest neighbour query and the optional re-ranking for the
+// player with the given vector and raw stats, excluded players are dropped from the results
+func (s *RecommendationServer) recommend(ctx context.Context, g *generation, vector []float32, stats vectors.Player, query recommendQuery, excluded map[string]struct{}) (*pb.RecommendResponse, error) {
+	candidates, err := g.candidates(ctx, vector, query, excluded)
+	if err != nil {
+		slog.ErrorContext(ctx, "querying nearest players", "generation", g.name, "err", err)
+		return &pb.RecommendResponse{}, storeError(err, "store = could not query nearest players")
+	}
+
+	if query.weights != nil {
+		candidates = rerank.Weighted(vector, candidates, query.weights)
+	} else {
+		sortHits(candidates)
+	}
+
+	// weighted distances are scored against the distribution of weighted distances
+	score := s.calibrator.Scorer(query.weights)
+
+	// thresholds only remove results, they're never back-filled
+	if query.minScore > 0 || query.maxDistance > 0 {
+		kept := candidates[:0]
+		for _, hit := range candidates {
+			if query.maxDistance > 0 && hit.Distance > query.maxDistance {
+				continue
+			}
+
+			if query.minScore > 0 && score(hit.Distance) < query.minScore {
+				continue
+			}
+
+			kept = append(kept, hit)
+		}
+		candidates = kept
+	}
+
+	// skip what earlier pages served, the page token's cursor wins over the count
+	served := query.offset
+	switch {
+	case query.token != nil:
+		served = query.token.Served
+		skip := 0
+		for skip < len(candidates) && !ordered(query.token.AfterDistance, query.token.AfterID, candidates[skip].Distance, candidates[skip].Player.ID) {
+			skip++
+		}
+		candidates = candidates[skip:]
+	case query.offset >= len(candidates):
+		candidates = nil
+	default:
+		candidates = candidates[query.offset:]
+	}
+
+	if query.diversification != nil {
+		candidates = rerank.MMR(vector, candidates, query.lambda(), query.limit)
+	}
+
+	if len(candidates) > query.limit {
+		candidates = candidates[:query.limit]
+	}
+
+	// a full page may have a next one, diversified and weighted results can't be paged
+	var nextPageToken string
+	if len(candidates) == query.limit && query.diversification == nil && query.weights == nil {
+		last := candidates[len(candidates)-1]
+		nextPageToken = encodePageToken(query.key, pageToken{
+			Vector:        vector,
+			AfterDistance: last.Distance,
+			AfterID:       last.Player.ID,
+			Served:        served + len(candidates),
+			Filters:       query.filters,
+			SchemaVersion: g.schema.Version,
+			Scope:         query.scope,
+		})
+	}
+
+	recommendations := make([]*pb.Recommendation, 0, len(candidates))
+	for _, hit := range candidates {
+		recommendation := &pb.Recommendation{
+			Id:       hit.Player.ID,
+			Distance: hit.Distance,
+			Score:    score(hit.Distance),
+			Season:   int32(hit.Player.Stats.Season),
+		}
+		if query.explain {
+			recommendation.Explanation = g.explain(vector, stats, hit.Player, query.weights)
+		}
+
+		recommendations = append(recommendations, recommendation)
+	}
+
+	return &pb.RecommendResponse{
+		Code:            200,
+		Message:         "OK",
+		Recommendations: recommendations,
+		NextPageToken:   nextPageToken,
+	}, nil
+}
+
+// explain breaks the distance to player down per feature, the distances come from the
+// weighted vectors while the differences are reported in raw stat units
+func (g *generation) explain(vector []float32, stats vectors.Player, player *store.Player, weights vectors.Weights) []*pb.FeatureContribution {
+	queryValues := g.schema.Values(stats)
+	values := g.schema.Values(player.Stats)
+
+	contributions := g.schema.Explain(weights.Apply(vector), weights.Apply(player.Vector))
+	explanation := make([]*pb.FeatureContribution, 0, len(contributions))
+	for i, contribution := range contributions {
+		feature := &pb.FeatureContribution{
+			Feature:  contribution.Feature,
+			Distance: contribution.Distance,
+			Share:    contribution.Share,
+		}
+
+		if i < len(values) && i < len(queryValues) {
+			feature.QueryValue = float64(queryValues[i])
+			feature.Value = float64(values[i])
+			feature.Difference = feature.Value - feature.QueryValue
+		}
+
+		explanation = append(explanation, feature)
+	}
+
+	return explanation
+}
+
+// candidates fetches query.candidates nearest players that aren't excluded, each player
+// once for their nearest season. Excluded players are over-fetched for up front, if
+// that isn't enough the search is widened until the store runs out of players
+func (g *generation) candidates(ctx context.Context, vector []float32, query recommendQuery, excluded map[string]struct{}) ([]store.Hit, error) {
+	limit := query.candidates + len(excluded)
+
+	for {
+		hits, err := g.store.NearVector(ctx, store.Query{Vector: vector, SchemaVersion: g.schema.Version, Filter: query.filter, Limit: limit})
+		if err != nil {
+			return nil, err
+		}
+
+		candidates := hits[:0]
+		seen := make(map[string]struct{}, len(hits))
+		for _, hit := range hits {
+			if _, ok := excluded[hit.Player.ID]; ok {
+				continue
+			}
+
+			if _, ok := seen[hit.Player.ID]; ok {
+				continue
+			}
+
+			seen[hit.Player.ID] = struct{}{}
+			candidates = append(candidates, hit)
+		}
+
+		if len(candidates) >= query.candidates || len(hits) < limit || limit >= maxCandidates {
+			return candidates, nil
+		}
+
+		limit *= 2
+		if limit > maxCandidates {
+			limit = maxCandidates
+		}
+	}
+}
+
+// parseRecommendParams validates in, page tokens must be signed by the server and issued
+// in scope
+func (s *RecommendationServer) parseRecommendParams(in recommendParams, schema vectors.Schema, scope string) (recommendQuery, error) {
+	limit, err := recommendLimit(in.GetLimit())
+	if err != nil {
+		return recommendQuery{}, err
+	}
+
+	filter, err := filterFromRequest(in.GetFilters())
+	if err != nil {
+		return recommendQuery{}, err
+	}
+
+	query := recommendQuery{
+		limit:           limit,
+		filter:          filter,
+		diversification: in.GetDiversification(),
+		candidates:      limit,
+		explain:         in.GetExplain(),
+		minScore:        in.GetMinScore(),
+		maxDistance:     in.GetMaxDistance(),
+		offset:          int(in.GetOffset()),
+		filters:         filtersFingerprint(in.GetFilters()),
+		key:             s.pageTokenKey,
+		scope:           scope,
+	}
+
+	if query.offset < 0 || query.offset+limit > maxCandidates {
+		return recommendQuery{}, status.Errorf(400, "offset = must be between 0 and %d", maxCandidates-limit)
+	}
+
+	if in.GetPageToken() != "" {
+		token, err := decodePageToken(s.pageTokenKey, in.GetPageToken())
+		if err != nil || token.Served < 0 || token.Served+limit > maxCandidates {
+			return recommendQuery{}, status.Error(400, "page_token = invalid page token")
+		}
+
+		if token.SchemaVersion != schema.Version {
+			return recommendQuery{}, status.Error(400, "page_token = issued for another schema version")
+		}
+
+		if len(token.Vector) != schema.Dimension() {
+			return recommendQuery{}, status.Error(400, "page_token = invalid page token")
+		}
+
+		if token.Scope != scope {
+			return recommendQuery{}, status.Error(400, "page_token = issued for another request")
+		}
+
+		if token.Filters != query.filters {
+			return recommendQuery{}, status.Error(400, "page_token = filters changed since the first page")
+		}
+
+		if query.offset > 0 {
+			return recommendQuery{}, status.Error(400, "page_token = can't be combined with offset")
+		}
+
+		query.token = token
+	}
+
+	// every page is a fresh top-n search, fetch enough to cover the earlier pages too.
+	// Players indexed since the last page can land before the cursor, the extra page
+	// of slack keeps that from cutting the next page short
+	if query.token != nil {
+		query.candidates += query.token.Served + limit
+	} else {
+		query.candidates += query.offset
+	}
+
+	if query.minScore < 0 || query.minScore > 100 {
+		return recommendQuery{}, status.Error(400, "min_score = must be between 0 and 100")
+	}
+
+	if query.maxDistance < 0 {
+		return recommendQuery{}, status.Error(400, "max_distance = must not be negative")
+	}
+
+	if diversification := query.diversification; diversification != nil {
+		if query.offset > 0 || query.token != nil {
+			return recommendQuery{}, status.Error(400, "diversification = diversified results can't be paged")
+		}
+
+		if diversification.GetLambda() < 0 || diversification.GetLambda() > 1 {
+			return recommendQuery{}, status.Error(400, "diversification = lambda must be between 0 and 1")
+		}
+
+		switch candidates := int(diversification.GetCandidates()); {
+		case candidates == 0:
+			query.candidates = limit * diversificationOverfetch
+		case candidates < limit || candidates > maxCandidates:
+			return recommendQuery{}, status.Errorf(400, "diversification = candidates must be between limit and %d", maxCandidates)
+		default:
+			query.candidates = candidates
+		}
+	}
+
+	if len(in.GetFeatureWeights()) > 0 {
+		weights, err := schema.Weights(in.GetFeatureWeights())
+		if err != nil {
+			return recommendQuery{}, status.Errorf(400, "feature_weights = %v", err)
+		}
+
+		if query.offset > 0 || query.token != nil {
+			return recommendQuery{}, status.Error(400, "feature_weights = weighted results can't be paged")
+		}
+
+		// the store ranks by the unweighted distance, re-rank a wider net. Players that
+		// are only near by the weighted distance are missed when they're outside it
+		query.weights = weights
+		if candidates := min(limit*s.weightedOverfetch, maxCandidates); query.candidates < candidates {
+			query.candidates = candidates
+		}
+	}
+
+	return query, nil
+}
+
+// lambda returns the diversification's lambda, an unset one is the default rather than
+// proto3's zero which would only maximise variety
+func (q recommendQuery) lambda() float64 {
+	if q.diversification.Lambda == nil {
+		return diversificationLambda
+	}
+	return float64(q.diversification.GetLambda())
+}
+
+// vector returns the snapshot of the page token when paging, fallback otherwise
+func (q recommendQuery) vector(fallback []float32) []float32 {
+	if q.token != nil {
+		return q.token.Vector
+	}
+	return fallback
+}
+
+func recommendLimit(limit int32) (int, error) {
+	switch {
+	case limit == 0:
+		return defaultRecommendLimit, nil
+	case limit < 0 || limit > maxRecommendLimit:
+		return 0, status.Errorf(400, "limit = must be between 1 and %d", maxRecommendLimit)
+	}
+
+	return int(limit), nil
+}
@@ -0,0 +1,638 @@
+package server
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/audit"
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
+	pb "github.com/eliassebastian/r6index-recommendation/pkg/proto/server"
+	"google.golang.org/protobuf/proto"
+)
+
+// testPlayers mirrors the Euclidean batch import in weaviate_test.go
+var testPlayers = []*pb.Request{
+	{Id: "6844b415-aa94-43c9-8823-9389e4816910", Level: 211, Kost: 0.76, Rank: 35, RankPoints: 3424, Season: 30},
+	{Id: "6844b415-aa94-43c9-8823-9389e4816914", Level: 250, Kost: 0.80, Rank: 35, RankPoints: 5000, Season: 30},
+	{Id: "6844b415-aa94-43c9-8823-9389e4816923", Level: 110, Kost: 0.43, Rank: 15, RankPoints: 1000, Season: 30},
+	{Id: "6844b415-aa94-43c9-8823-9389e4816905", Level: 300, Kost: 0.54, Rank: 17, RankPoints: 1233, Season: 30},
+	{Id: "6844b415-aa94-43c9-8823-9389e4816918", Level: 300, Kost: 0.55, Rank: 18, RankPoints: 1250, Season: 30},
+	{Id: "6844b415-aa94-43c9-8823-9389e4816300", Level: 245, Kost: 0.55, Rank: 19, RankPoints: 1400, Season: 30},
+	{Id: "6844b415-aa94-43c9-8823-9389e4816454", Level: 300, Kost: 0.58, Rank: 18, RankPoints: 1245, Season: 30},
+	{Id: "6844b415-aa94-43c9-8823-9389e4816861", Level: 299, Kost: 0.51, Rank: 18, RankPoints: 1255, Season: 30},
+}
+
+func newIndexedTestClient(t *testing.T) (pb.RecommendationServiceClient, *RecommendationServer) {
+	recommendationServer, _ := newTestServer()
+	client := newTestClient(t, recommendationServer)
+
+	for _, player := range testPlayers {
+		if _, err := client.Index(context.Background(), player); err != nil {
+			t.Fatalf("Index() error = %v, want nil", err)
+		}
+	}
+
+	return client, recommendationServer
+}
+
+func recommendationIDs(response *pb.RecommendResponse) []string {
+	var ids []string
+	for _, recommendation := range response.GetRecommendations() {
+		ids = append(ids, recommendation.GetId())
+	}
+	return ids
+}
+
+func TestRecommendationServiceServer_Recommend(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newIndexedTestClient(t)
+
+	response, err := client.Recommend(ctx, &pb.RecommendRequest{Id: "6844b415-aa94-43c9-8823-9389e4816918", Limit: 3})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v, want nil", err)
+	}
+
+	// after normalization one rank apart is closer than 0.03 KOST apart
+	want := []string{
+		"6844b415-aa94-43c9-8823-9389e4816905",
+		"6844b415-aa94-43c9-8823-9389e4816454",
+		"6844b415-aa94-43c9-8823-9389e4816861",
+	}
+
+	if got := recommendationIDs(response); !reflect.DeepEqual(got, want) {
+		t.Errorf("Recommend() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		testName string
+		req      *pb.RecommendRequest
+		want     string
+	}{
+		{"empty player id", &pb.RecommendRequest{}, "rpc error: code = Code(400) desc = id = empty player id"},
+		{"unknown player", &pb.RecommendRequest{Id: "460a3311-fe2f-489c-ba95-73370cbaddfa"}, "rpc error: code = Code(404) desc = id = player not found"},
+		{"limit too large", &pb.RecommendRequest{Id: "6844b415-aa94-43c9-8823-9389e4816918", Limit: 1000}, "rpc error: code = Code(400) desc = limit = must be between 1 and 100"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			_, err := client.Recommend(ctx, tt.req)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("err -> \nWant: %q\nGot: %v\n", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestRecommendationServiceServer_RecommendByStats(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newIndexedTestClient(t)
+
+	// the profile of an indexed player finds that player first at distance zero
+	response, err := client.RecommendByStats(ctx, &pb.RecommendByStatsRequest{Profile: &pb.Request{Level: 300, Kost: 0.55, Rank: 18, RankPoints: 1250}})
+	if err != nil {
+		t.Fatalf("RecommendByStats() error = %v, want nil", err)
+	}
+
+	recommendations := response.GetRecommendations()
+	if len(recommendations) != defaultRecommendLimit {
+		t.Fatalf("RecommendByStats() = %v results, want %v", len(recommendations), defaultRecommendLimit)
+	}
+
+	if recommendations[0].GetId() != "6844b415-aa94-43c9-8823-9389e4816918" || recommendations[0].GetDistance() != 0 {
+		t.Errorf("RecommendByStats() top = %v, want exact match", recommendations[0])
+	}
+
+	_, err = client.RecommendByStats(ctx, &pb.RecommendByStatsRequest{})
+	want := "rpc error: code = Code(400) desc = profile = empty player profile"
+	if err == nil || err.Error() != want {
+		t.Errorf("RecommendByStats() err = %v, want %q", err, want)
+	}
+}
+
+func TestRecommendationServiceServer_RecommendWithFilters(t *testing.T) {
+	ctx := context.Background()
+	recommendationServer, _ := newTestServer()
+	client := newTestClient(t, recommendationServer)
+
+	players := []*pb.Request{
+		{Id: "6844b415-aa94-43c9-8823-9389e4816918", Level: 300, Kost: 0.55, Rank: 18, RankPoints: 1250, Platform: "pc", Region: "emea", Season: 30},
+		{Id: "6844b415-aa94-43c9-8823-9389e4816454", Level: 300, Kost: 0.58, Rank: 18, RankPoints: 1245, Platform: "xbox", Region: "emea", Season: 30},
+		{Id: "6844b415-aa94-43c9-8823-9389e4816861", Level: 299, Kost: 0.51, Rank: 18, RankPoints: 1255, Platform: "pc", Region: "apac", Season: 30},
+		{Id: "6844b415-aa94-43c9-8823-9389e4816300", Level: 245, Kost: 0.55, Rank: 19, RankPoints: 1400, Platform: "pc", Region: "ncsa", Season: 30},
+	}
+
+	for _, player := range players {
+		if _, err := client.Index(ctx, player); err != nil {
+			t.Fatalf("Index() error = %v, want nil", err)
+		}
+	}
+
+	tests := []struct {
+		testName string
+		filters  []*pb.Filter
+		want     []string
+	}{
+		{
+			"platform equality",
+			[]*pb.Filter{{Property: "platform", Operator: pb.FilterOperator_EQUAL, Text: "pc"}},
+			[]string{"6844b415-aa94-43c9-8823-9389e4816861", "6844b415-aa94-43c9-8823-9389e4816300"},
+		},
+		{
+			"region in set",
+			[]*pb.Filter{{Property: "region", Operator: pb.FilterOperator_IN, Texts: []string{"emea", "ncsa"}}},
+			[]string{"6844b415-aa94-43c9-8823-9389e4816454", "6844b415-aa94-43c9-8823-9389e4816300"},
+		},
+		{
+			"rank points band",
+			[]*pb.Filter{
+				{Property: "rank_points", Operator: pb.FilterOperator_GREATER_THAN_EQUAL, Number: 1250},
+				{Property: "rank_points", Operator: pb.FilterOperator_LESS_THAN, Number: 1300},
+			},
+			[]string{"6844b415-aa94-43c9-8823-9389e4816861"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			response, err := client.Recommend(ctx, &pb.RecommendRequest{Id: players[0].Id, Filters: tt.filters})
+			if err != nil {
+				t.Fatalf("Recommend() error = %v, want nil", err)
+			}
+
+			if got := recommendationIDs(response); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Recommend() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	_, err := client.Recommend(ctx, &pb.RecommendRequest{Id: players[0].Id, Filters: []*pb.Filter{{Property: "region", Operator: pb.FilterOperator_LESS_THAN, Text: "emea"}}})
+	want := `rpc error: code = Code(400) desc = filters = property "region" only supports equality and in`
+	if err == nil || err.Error() != want {
+		t.Errorf("Recommend() err = %v, want %q", err, want)
+	}
+}
+
+func TestRecommendationServiceServer_RecommendWithDiversification(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newIndexedTestClient(t)
+
+	id := "6844b415-aa94-43c9-8823-9389e4816918"
+
+	plain, err := client.Recommend(ctx, &pb.RecommendRequest{Id: id, Limit: 3})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v, want nil", err)
+	}
+
+	// a lambda of 1 only looks at similarity to the query player
+	relevant, err := client.Recommend(ctx, &pb.RecommendRequest{Id: id, Limit: 3, Diversification: &pb.Diversification{Lambda: proto.Float32(1)}})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v, want nil", err)
+	}
+
+	if !reflect.DeepEqual(recommendationIDs(relevant), recommendationIDs(plain)) {
+		t.Errorf("Recommend(lambda=1) = %v, want %v", recommendationIDs(relevant), recommendationIDs(plain))
+	}
+
+	diverse, err := client.Recommend(ctx, &pb.RecommendRequest{Id: id, Limit: 3, Diversification: &pb.Diversification{Lambda: proto.Float32(0.3)}})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v, want nil", err)
+	}
+
+	// the nearest player is always kept, the near duplicates of it are then passed
+	// over for the players furthest from the cluster
+	want := []string{
+		"6844b415-aa94-43c9-8823-9389e4816905",
+		"6844b415-aa94-43c9-8823-9389e4816914",
+		"6844b415-aa94-43c9-8823-9389e4816923",
+	}
+
+	if got := recommendationIDs(diverse); !reflect.DeepEqual(got, want) {
+		t.Errorf("Recommend(lambda=0.3) = %v, want %v", got, want)
+	}
+
+	// an unset lambda is the default, not proto3's zero
+	unset, err := client.Recommend(ctx, &pb.RecommendRequest{Id: id, Limit: 3, Diversification: &pb.Diversification{}})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v, want nil", err)
+	}
+
+	defaulted, err := client.Recommend(ctx, &pb.RecommendRequest{Id: id, Limit: 3, Diversification: &pb.Diversification{Lambda: proto.Float32(diversificationLambda)}})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v, want nil", err)
+	}
+
+	if !reflect.DeepEqual(recommendationIDs(unset), recommendationIDs(defaulted)) {
+		t.Errorf("Recommend(lambda unset) = %v, want %v", recommendationIDs(unset), recommendationIDs(defaulted))
+	}
+
+	_, err = client.Recommend(ctx, &pb.RecommendRequest{Id: id, Diversification: &pb.Diversification{Lambda: proto.Float32(2)}})
+	wantErr := "rpc error: code = Code(400) desc = diversification = lambda must be between 0 and 1"
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("Recommend() err = %v, want %q", err, wantErr)
+	}
+}
+
+func TestRecommendationServiceServer_RecommendWithExclusions(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newIndexedTestClient(t)
+
+	id := "6844b415-aa94-43c9-8823-9389e4816918"
+
+	if _, err := client.Block(ctx, &pb.BlockRequest{Id: id, BlockedId: "6844b415-aa94-43c9-8823-9389e4816905"}); err != nil {
+		t.Fatalf("Block() error = %v, want nil", err)
+	}
+
+	if _, err := client.RecordTeammates(ctx, &pb.TeammatesRequest{Ids: []string{id, "6844b415-aa94-43c9-8823-9389e4816454"}}); err != nil {
+		t.Fatalf("RecordTeammates() error = %v, want nil", err)
+	}
+
+	response, err := client.Recommend(ctx, &pb.RecommendRequest{Id: id, Limit: 3})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v, want nil", err)
+	}
+
+	// the two nearest players are excluded and the results are back-filled
+	want := []string{
+		"6844b415-aa94-43c9-8823-9389e4816861",
+		"6844b415-aa94-43c9-8823-9389e4816300",
+		"6844b415-aa94-43c9-8823-9389e4816923",
+	}
+
+	if got := recommendationIDs(response); !reflect.DeepEqual(got, want) {
+		t.Errorf("Recommend() = %v, want %v", got, want)
+	}
+
+	// blocks work in both directions
+	response, err = client.Recommend(ctx, &pb.RecommendRequest{Id: "6844b415-aa94-43c9-8823-9389e4816905"})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v, want nil", err)
+	}
+
+	for _, got := range recommendationIDs(response) {
+		if got == id {
+			t.Errorf("Recommend() returned blocking player %v", id)
+		}
+	}
+
+	if _, err := client.Unblock(ctx, &pb.BlockRequest{Id: id, BlockedId: "6844b415-aa94-43c9-8823-9389e4816905"}); err != nil {
+		t.Fatalf("Unblock() error = %v, want nil", err)
+	}
+
+	response, err = client.Recommend(ctx, &pb.RecommendRequest{Id: id, Limit: 1})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v, want nil", err)
+	}
+
+	if got := recommendationIDs(response); !reflect.DeepEqual(got, []string{"6844b415-aa94-43c9-8823-9389e4816905"}) {
+		t.Errorf("Recommend() after Unblock() = %v, want unblocked player", got)
+	}
+
+	_, err = client.RecordTeammates(ctx, &pb.TeammatesRequest{Ids: []string{id}})
+	wantErr := "rpc error: code = Code(400) desc = ids = need at least two players"
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("RecordTeammates() err = %v, want %q", err, wantErr)
+	}
+}
+
+func TestRecommendationServiceServer_RecommendWithExplanation(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newIndexedTestClient(t)
+
+	response, err := client.Recommend(ctx, &pb.RecommendRequest{Id: "6844b415-aa94-43c9-8823-9389e4816918", Limit: 1, Explain: true})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v, want nil", err)
+	}
+
+	recommendation := response.GetRecommendations()[0]
+	explanation := recommendation.GetExplanation()
+	if want := vectors.Current.Dimension(); len(explanation) != want {
+		t.Fatalf("Recommend() explanation = %v features, want %v", len(explanation), want)
+	}
+
+	var distance, share float32
+	for _, feature := range explanation {
+		distance += feature.GetDistance()
+		share += feature.GetShare()
+	}
+
+	if diff := distance - recommendation.GetDistance(); diff > 1e-5 || diff < -1e-5 {
+		t.Errorf("explanation distances add up to %v, want %v", distance, recommendation.GetDistance())
+	}
+
+	if share < 0.999 || share > 1.001 {
+		t.Errorf("explanation shares add up to %v, want 1", share)
+	}
+
+	// 6844b415-aa94-43c9-8823-9389e4816905 is one rank lower with 17 fewer rank points
+	rank := explanation[2]
+	if rank.GetFeature() != "rank" || rank.GetQueryValue() != 18 || rank.GetValue() != 17 || rank.GetDifference() != -1 {
+		t.Errorf("rank explanation = %v, want 18 -> 17", rank)
+	}
+
+	if explanation[3].GetDifference() != -17 {
+		t.Errorf("rank_points difference = %v, want %v", explanation[3].GetDifference(), -17)
+	}
+
+	response, err = client.Recommend(ctx, &pb.RecommendRequest{Id: "6844b415-aa94-43c9-8823-9389e4816918", Limit: 1})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v, want nil", err)
+	}
+
+	if len(response.GetRecommendations()[0].GetExplanation()) != 0 {
+		t.Errorf("Recommend() without explain returned an explanation")
+	}
+}
+
+func TestRecommendationServiceServer_RecommendWithThresholds(t *testing.T) {
+	ctx := context.Background()
+	client, recommendationServer := newIndexedTestClient(t)
+	recommendationServer.calibrator.Fit()
+
+	id := "6844b415-aa94-43c9-8823-9389e4816918"
+
+	plain, err := client.Recommend(ctx, &pb.RecommendRequest{Id: id, Limit: 5})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v, want nil", err)
+	}
+
+	recommendations := plain.GetRecommendations()
+	for i := 1; i < len(recommendations); i++ {
+		if recommendations[i].GetScore() > recommendations[i-1].GetScore() {
+			t.Errorf("Recommend() scores = %v before %v, want descending", recommendations[i-1].GetScore(), recommendations[i].GetScore())
+		}
+	}
+
+	response, err := client.Recommend(ctx, &pb.RecommendRequest{Id: id, Limit: 5, MaxDistance: recommendations[1].GetDistance()})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v, want nil", err)
+	}
+
+	if got := len(response.GetRecommendations()); got != 2 {
+		t.Errorf("Recommend(max_distance) = %v results, want %v", got, 2)
+	}
+
+	response, err = client.Recommend(ctx, &pb.RecommendRequest{Id: id, Limit: 5, MinScore: recommendations[2].GetScore()})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v, want nil", err)
+	}
+
+	for _, recommendation := range response.GetRecommendations() {
+		if recommendation.GetScore() < recommendations[2].GetScore() {
+			t.Errorf("Recommend(min_score) returned score %v, want at least %v", recommendation.GetScore(), recommendations[2].GetScore())
+		}
+	}
+
+	if got := len(response.GetRecommendations()); got < 3 || got == len(recommendations) && recommendations[4].GetScore() < recommendations[2].GetScore() {
+		t.Errorf("Recommend(min_score) = %v results, want the ones scoring at least %v", got, recommendations[2].GetScore())
+	}
+
+	_, err = client.Recommend(ctx, &pb.RecommendRequest{Id: id, MinScore: 101})
+	want := "rpc error: code = Code(400) desc = min_score = must be between 0 and 100"
+	if err == nil || err.Error() != want {
+		t.Errorf("Recommend() err = %v, want %q", err, want)
+	}
+}
+
+func TestRecommendationServiceServer_RecommendPagination(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newIndexedTestClient(t)
+
+	id := "6844b415-aa94-43c9-8823-9389e4816918"
+
+	all, err := client.Recommend(ctx, &pb.RecommendRequest{Id: id, Limit: 7})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v, want nil", err)
+	}
+	want := recommendationIDs(all)
+
+	offset, err := client.Recommend(ctx, &pb.RecommendRequest{Id: id, Limit: 2, Offset: 2})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v, want nil", err)
+	}
+
+	if got := recommendationIDs(offset); !reflect.DeepEqual(got, want[2:4]) {
+		t.Errorf("Recommend(offset=2) = %v, want %v", got, want[2:4])
+	}
+
+	// page through with tokens, re-indexing the query player half way through must not
+	// change the later pages
+	var got []string
+	request := &pb.RecommendRequest{Id: id, Limit: 3}
+	for page := 0; ; page++ {
+		response, err := client.Recommend(ctx, request)
+		if err != nil {
+			t.Fatalf("Recommend() page %d error = %v, want nil", page, err)
+		}
+
+		got = append(got, recommendationIDs(response)...)
+		if response.GetNextPageToken() == "" {
+			break
+		}
+
+		if page == 0 {
+			if _, err := client.Index(ctx, &pb.Request{Id: id, Level: 20, Kost: 1.2, Rank: 5, RankPoints: 400, Season: 30}); err != nil {
+				t.Fatalf("Index() error = %v, want nil", err)
+			}
+		}
+
+		request.PageToken = response.GetNextPageToken()
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Recommend() pages = %v, want %v", got, want)
+	}
+
+	// the token is bound to the filters of the first page
+	first, err := client.Recommend(ctx, &pb.RecommendRequest{Id: id, Limit: 2})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v, want nil", err)
+	}
+
+	_, err = client.Recommend(ctx, &pb.RecommendRequest{
+		Id:        id,
+		Limit:     2,
+		PageToken: first.GetNextPageToken(),
+		Filters:   []*pb.Filter{{Property: "platform", Operator: pb.FilterOperator_EQUAL, Text: "pc"}},
+	})
+	wantErr := "rpc error: code = Code(400) desc = page_token = filters changed since the first page"
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("Recommend() err = %v, want %q", err, wantErr)
+	}
+
+	_, err = client.Recommend(ctx, &pb.RecommendRequest{Id: id, PageToken: "not a token"})
+	wantErr = "rpc error: code = Code(400) desc = page_token = invalid page token"
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("Recommend() err = %v, want %q", err, wantErr)
+	}
+
+	// nor can it be replayed for another player
+	_, err = client.Recommend(ctx, &pb.RecommendRequest{Id: "6844b415-aa94-43c9-8823-9389e4816905", Limit: 2, PageToken: first.GetNextPageToken()})
+	wantErr = "rpc error: code = Code(400) desc = page_token = issued for another request"
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("Recommend() err = %v, want %q", err, wantErr)
+	}
+}
+
+func TestRecommendationServiceServer_RecommendForgedPageToken(t *testing.T) {
+	ctx := context.Background()
+	client, recommendationServer := newIndexedTestClient(t)
+
+	id := "6844b415-aa94-43c9-8823-9389e4816918"
+	scope := pageScope("Recommend", id)
+	vector := make([]float32, vectors.Current.Dimension())
+
+	tests := []struct {
+		testName string
+		token    string
+	}{
+		{"unsigned", strings.Split(encodePageToken(recommendationServer.pageTokenKey, pageToken{Vector: vector, SchemaVersion: vectors.Current.Version, Scope: scope}), ".")[0]},
+		{"signed by another key", encodePageToken([]byte("another key"), pageToken{Vector: vector, SchemaVersion: vectors.Current.Version, Scope: scope})},
+		{"negative served", encodePageToken(recommendationServer.pageTokenKey, pageToken{Vector: vector, Served: -50, SchemaVersion: vectors.Current.Version, Scope: scope})},
+		{"wrong dimension", encodePageToken(recommendationServer.pageTokenKey, pageToken{Vector: vector[:2], SchemaVersion: vectors.Current.Version, Scope: scope})},
+	}
+
+	want := "rpc error: code = Code(400) desc = page_token = invalid page token"
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			_, err := client.Recommend(ctx, &pb.RecommendRequest{Id: id, Limit: 10, PageToken: tt.token})
+			if err == nil || err.Error() != want {
+				t.Errorf("Recommend() err = %v, want %q", err, want)
+			}
+		})
+	}
+}
+
+func TestRecommendationServiceServer_RecommendAcrossSchemaVersions(t *testing.T) {
+	ctx := context.Background()
+	_, memory := newTestServer()
+
+	legacy := newTestClient(t, NewRecommendationServer(memory, audit.New(io.Discard), 1, time.Minute, WithSchema(vectors.SchemaV1)))
+	current := newTestClient(t, NewRecommendationServer(memory, audit.New(io.Discard), 1, time.Minute))
+
+	for _, player := range testPlayers[:4] {
+		if _, err := legacy.Index(ctx, player); err != nil {
+			t.Fatalf("Index() error = %v, want nil", err)
+		}
+	}
+
+	for _, player := range testPlayers[4:] {
+		if _, err := current.Index(ctx, player); err != nil {
+			t.Fatalf("Index() error = %v, want nil", err)
+		}
+	}
+
+	// a player indexed under v1 is re-vectorized for the query, but only v2 vectors are compared
+	response, err := current.Recommend(ctx, &pb.RecommendRequest{Id: testPlayers[0].Id, Limit: 10})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v, want nil", err)
+	}
+
+	got := recommendationIDs(response)
+	if len(got) != len(testPlayers[4:]) {
+		t.Fatalf("Recommend() = %v, want only the %d v2 players", got, len(testPlayers[4:]))
+	}
+
+	for _, id := range got {
+		player, err := memory.Get(ctx, id)
+		if err != nil || player.SchemaVersion != vectors.Current.Version {
+			t.Errorf("Recommend() returned %s with schema version %d, want %d", id, player.SchemaVersion, vectors.Current.Version)
+		}
+	}
+
+	response, err = legacy.Recommend(ctx, &pb.RecommendRequest{Id: testPlayers[0].Id, Limit: 10})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v, want nil", err)
+	}
+
+	// the v1 query player itself is excluded
+	if got := recommendationIDs(response); len(got) != 3 {
+		t.Errorf("Recommend() on v1 = %v, want the 3 other v1 players", got)
+	}
+}
+
+func TestRecommendationServiceServer_RecommendWithFeatureWeights(t *testing.T) {
+	ctx := context.Background()
+	recommendationServer, _ := newTestServer()
+	client := newTestClient(t, recommendationServer)
+	roamer, breacher := "6844b415-aa94-43c9-8823-938900000011", "6844b415-aa94-43c9-8823-938900000012"
+
+	// the roamer has the query's stats, the breacher its playstyle
+	players := []*pb.Request{
+		{Id: roamer, Level: 200, Kost: 0.6, Rank: 20, RankPoints: 2500, OperatorPickRates: map[string]float32{"vigil": 0.7, "caveira": 0.3}, Season: 30},
+		{Id: breacher, Level: 220, Kost: 0.6, Rank: 20, RankPoints: 2500, OperatorPickRates: map[string]float32{"thermite": 1}, Season: 30},
+	}
+	for _, player := range players {
+		if _, err := client.Index(ctx, player); err != nil {
+			t.Fatalf("Index() error = %v, want nil", err)
+		}
+	}
+
+	profile := &pb.Request{Level: 200, Kost: 0.6, Rank: 20, RankPoints: 2500, OperatorPickRates: map[string]float32{"hibana": 1}}
+
+	testCases := []struct {
+		weights map[string]float32
+		want    []string
+	}{
+		{nil, []string{breacher, roamer}},
+		{map[string]float32{"playstyle": 0}, []string{roamer, breacher}},
+		{map[string]float32{"playstyle": 0.01}, []string{roamer, breacher}},
+		{map[string]float32{"playstyle": 0.01, "level": 0}, []string{breacher, roamer}},
+	}
+
+	for _, testCase := range testCases {
+		response, err := client.RecommendByStats(ctx, &pb.RecommendByStatsRequest{Profile: profile, FeatureWeights: testCase.weights, Explain: true})
+		if err != nil {
+			t.Fatalf("RecommendByStats(%v) error = %v, want nil", testCase.weights, err)
+		}
+
+		if got := recommendationIDs(response); !reflect.DeepEqual(got, testCase.want) {
+			t.Errorf("RecommendByStats(%v) = %v, want %v", testCase.weights, got, testCase.want)
+		}
+
+		// explanations break down the weighted distance
+		for _, recommendation := range response.GetRecommendations() {
+			var distance float32
+			for _, feature := range recommendation.GetExplanation() {
+				distance += feature.GetDistance()
+			}
+
+			if diff := distance - recommendation.GetDistance(); diff > 1e-5 || diff < -1e-5 {
+				t.Errorf("RecommendByStats(%v) explanation adds up to %v, want %v", testCase.weights, distance, recommendation.GetDistance())
+			}
+		}
+	}
+
+	errorCases := []struct {
+		request *pb.RecommendByStatsRequest
+		want    string
+	}{
+		{&pb.RecommendByStatsRequest{Profile: profile, FeatureWeights: map[string]float32{"elo": 2}}, `feature_weights = unknown feature "elo"`},
+		{&pb.RecommendByStatsRequest{Profile: profile, FeatureWeights: map[string]float32{"kd": -1}}, `feature_weights = feature "kd": weight must not be negative`},
+		{&pb.RecommendByStatsRequest{Profile: profile, FeatureWeights: map[string]float32{"kd": 2}, Offset: 1}, "feature_weights = weighted results can't be paged"},
+	}
+
+	for _, errorCase := range errorCases {
+		_, err := client.RecommendByStats(ctx, errorCase.request)
+		if want := "rpc error: code = Code(400) desc = " + errorCase.want; err == nil || err.Error() != want {
+			t.Errorf("RecommendByStats() err = %v, want %q", err, want)
+		}
+	}
+}
+
+func TestRecommendationServiceServer_WeightedOverfetch(t *testing.T) {
+	recommendationServer := NewRecommendationServer(store.NewMemory(), audit.New(io.Discard), 1, time.Minute, WithWeightedOverfetch(10))
+
+	query, err := recommendationServer.parseRecommendParams(&pb.RecommendRequest{Limit: 5, FeatureWeights: map[string]float32{"kd": 2}}, vectors.Current, pageScope("Recommend", ""))
+	if err != nil {
+		t.Fatalf("parseRecommendParams() error = %v, want nil", err)
+	}
+
+	if query.candidates != 50 {
+		t.Errorf("candidates = %d, want 50", query.candidates)
+	}
+}
@@ -0,0 +1,271 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/reindex"
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
+	pb "github.com/eliassebastian/r6index-recommendation/pkg/proto/server"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// records copied per re-index batch
+const reindexBatchSize = 100
+
+// Collections are the versioned collections a re-index builds and switches the
+// server's store between. The store keeps reading and writing through the alias
+type Collections interface {
+	// Name is the logical name of the collections, deletes are audited under it
+	Name() string
+	// Active returns the collection and schema version the store currently serves
+	Active() (string, int)
+	// Backfill creates target and returns a job re-embedding every record the store
+	// serves into it with schema
+	Backfill(ctx context.Context, target string, schema vectors.Schema) (*reindex.Job, error)
+	// Promote switches the store over to target
+	Promote(ctx context.Context, target string, schemaVersion int) error
+	// Rollback switches the store back to the collection served before the last
+	// Promote, or returns reindex.ErrNoPrevious. The players deleted since the
+	// Promote are deleted from it first
+	Rollback(ctx context.Context, deleted reindex.Deletions) (string, int, error)
+}
+
+// reindexer runs re-index jobs, one at a time
+type reindexer struct {
+	// ctx bounds the jobs, it lives as long as the server
+	ctx         context.Context
+	collections Collections
+	checkpoint  string
+
+	mutex sync.Mutex
+	job   *reindex.Job
+}
+
+// WithReindex enables the Reindex and Rollback RPCs. The server's store must read and
+// write through the alias of collections, whose active schema replaces WithSchema's.
+// checkpoint is the file a running re-index persists its progress to, a re-index
+// cancelled with ctx resumes from it when the server starts again
+func WithReindex(ctx context.Context, collections Collections, checkpoint string) Option {
+	return func(s *RecommendationServer) {
+		name, version := collections.Active()
+		g := *s.generation()
+		g.name = name
+		if schema, ok := vectors.Schemas[version]; ok {
+			g.schema = schema
+		}
+		s.active.Store(&g)
+		s.reindex = &reindexer{ctx: ctx, collections: collections, checkpoint: checkpoint}
+	}
+}
+
+// resumeReindex starts the re-index the checkpoint was left by again, before any
+// write is accepted so none is missing from the target
+func (s *RecommendationServer) resumeReindex() {
+	checkpoint, err := reindex.LoadCheckpoint(s.reindex.checkpoint)
+	if err != nil {
+		slog.Error("reindex: loading checkpoint", "path", s.reindex.checkpoint, "err", err)
+		return
+	}
+
+	if checkpoint == nil || checkpoint.Done || checkpoint.Target == s.generation().name {
+		return
+	}
+
+	schema, ok := vectors.Schemas[checkpoint.SchemaVersion]
+	if !ok {
+		slog.Warn("reindex: checkpoint of an unknown schema version, not resuming", "target", checkpoint.Target, "schema_version", checkpoint.SchemaVersion)
+		return
+	}
+
+	s.reindex.mutex.Lock()
+	defer s.reindex.mutex.Unlock()
+
+	if err := s.startReindex(s.reindex.ctx, checkpoint.Target, schema); err != nil {
+		slog.Error("reindex: resuming", "target", checkpoint.Target, "schema_version", schema.Version, "err", err)
+		return
+	}
+
+	slog.Info("reindex: resuming", "target", checkpoint.Target, "schema_version", schema.Version, "copied", checkpoint.Copied)
+}
+
+func (s *RecommendationServer) Reindex(ctx context.Context, in *pb.ReindexRequest) (*pb.ReindexResponse, error) {
+	if s.reindex == nil {
+		return &pb.ReindexResponse{}, status.Error(501, "reindex = not enabled")
+	}
+
+	if in.GetTarget() == "" {
+		return &pb.ReindexResponse{}, status.Error(400, "target = empty target")
+	}
+
+	schema := vectors.Current
+	if in.GetSchemaVersion() != 0 {
+		var ok bool
+		if schema, ok = vectors.Schemas[int(in.GetSchemaVersion())]; !ok {
+			return &pb.ReindexResponse{}, status.Errorf(400, "schema_version = unknown schema version %d", in.GetSchemaVersion())
+		}
+	}
+
+	s.reindex.mutex.Lock()
+	defer s.reindex.mutex.Unlock()
+
+	if s.shadow.Load() != nil {
+		return &pb.ReindexResponse{}, status.Error(409, "reindex = a re-index is already running")
+	}
+
+	active := s.generation()
+	if in.GetTarget() == active.name {
+		return &pb.ReindexResponse{}, status.Error(400, "target = reads already go to target")
+	}
+
+	if err := s.startReindex(ctx, in.GetTarget(), schema); err != nil {
+		slog.ErrorContext(ctx, "reindex: creating target", "target", in.GetTarget(), "err", err)
+		return &pb.ReindexResponse{}, status.Error(500, "store = could not create target")
+	}
+
+	return s.reindexResponse(), nil
+}
+
+// startReindex creates target and starts copying into it, the caller holds the
+// reindexer's mutex
+func (s *RecommendationServer) startReindex(ctx context.Context, target string, schema vectors.Schema) error {
+	job, err := s.reindex.collections.Backfill(ctx, target, schema)
+	if err != nil {
+		return err
+	}
+
+	job.BatchSize = reindexBatchSize
+	job.Checkpoint = s.reindex.checkpoint
+	// other replicas keep writing to the active collection only, the job catches up
+	// on their writes before the target is promoted
+	collection := s.reindex.collections.Name()
+	job.Deleted = func(since time.Time) ([]string, error) {
+		return s.audit.Deleted(collection, since)
+	}
+	shadow := &generation{name: target, store: job.Target, schema: schema, backfill: job}
+
+	// writes from here on are mirrored into the target, the job copies everything before
+	s.writes.Lock()
+	s.shadow.Store(shadow)
+	s.writes.Unlock()
+
+	s.reindex.job = job
+	go s.runReindex(job, shadow)
+	return nil
+}
+
+func (s *RecommendationServer) ReindexStatus(ctx context.Context, in *pb.ReindexStatusRequest) (*pb.ReindexResponse, error) {
+	if s.reindex == nil {
+		return &pb.ReindexResponse{}, status.Error(501, "reindex = not enabled")
+	}
+
+	s.reindex.mutex.Lock()
+	defer s.reindex.mutex.Unlock()
+
+	return s.reindexResponse(), nil
+}
+
+// runReindex promotes shadow once job has copied every record. A job cancelled with
+// the server keeps mirroring writes until it exits and resumes on the next start. A
+// failed job stops mirroring, so its checkpoint is dropped and requesting the same
+// re-index again starts over instead of missing the writes since
+func (s *RecommendationServer) runReindex(job *reindex.Job, shadow *generation) {
+	ctx := s.reindex.ctx
+	err := job.Run(ctx)
+	if err != nil && ctx.Err() != nil {
+		slog.Info("reindex: stopped with the server, resuming on the next start", "target", shadow.name, "schema_version", shadow.schema.Version)
+		return
+	}
+
+	s.writes.Lock()
+	defer s.writes.Unlock()
+
+	s.shadow.Store(nil)
+	if err == nil {
+		err = s.reindex.collections.Promote(ctx, shadow.name, shadow.schema.Version)
+	}
+
+	if err != nil {
+		slog.Error("reindex: stopped", "target", shadow.name, "schema_version", shadow.schema.Version, "err", err)
+		if err := reindex.RemoveCheckpoint(s.reindex.checkpoint); err != nil {
+			slog.Error("reindex: removing checkpoint", "path", s.reindex.checkpoint, "err", err)
+		}
+		return
+	}
+
+	// reads keep going through the alias, which now points to the re-indexed collection
+	s.active.Store(&generation{name: shadow.name, store: s.generation().store, schema: shadow.schema})
+}
+
+func (s *RecommendationServer) Rollback(ctx context.Context, in *pb.RollbackRequest) (*pb.ReindexResponse, error) {
+	if s.reindex == nil {
+		return &pb.ReindexResponse{}, status.Error(501, "reindex = not enabled")
+	}
+
+	s.reindex.mutex.Lock()
+	defer s.reindex.mutex.Unlock()
+
+	if s.shadow.Load() != nil {
+		return &pb.ReindexResponse{}, status.Error(409, "reindex = a re-index is running")
+	}
+
+	s.writes.Lock()
+	defer s.writes.Unlock()
+
+	collection := s.reindex.collections.Name()
+	name, version, err := s.reindex.collections.Rollback(ctx, func(since time.Time) ([]string, error) {
+		return s.audit.Deleted(collection, since)
+	})
+	if errors.Is(err, reindex.ErrNoPrevious) {
+		return &pb.ReindexResponse{}, status.Error(412, "reindex = no previous collection to roll back to")
+	}
+
+	if err != nil {
+		slog.ErrorContext(ctx, "reindex: rolling back", "err", err)
+		return &pb.ReindexResponse{}, status.Error(500, "store = could not roll back")
+	}
+
+	schema, ok := vectors.Schemas[version]
+	if !ok {
+		slog.WarnContext(ctx, "reindex: rolled back to an unknown schema version", "target", name, "schema_version", version)
+		schema = s.generation().schema
+	}
+
+	s.active.Store(&generation{name: name, store: s.generation().store, schema: schema})
+	return s.reindexResponse(), nil
+}
+
+func (s *RecommendationServer) reindexResponse() *pb.ReindexResponse {
+	active := s.generation()
+	response := &pb.ReindexResponse{
+		Code:                200,
+		Message:             "OK",
+		ActiveTarget:        active.name,
+		ActiveSchemaVersion: int32(active.schema.Version),
+	}
+
+	if s.reindex.job == nil {
+		return response
+	}
+
+	progress := s.reindex.job.Progress()
+	response.Progress = &pb.ReindexProgress{
+		Target:        progress.Target,
+		SchemaVersion: int32(progress.SchemaVersion),
+		Copied:        int64(progress.Copied),
+		Total:         int64(progress.Total),
+		Done:          progress.Done,
+		StartedAt:     timestamppb.New(progress.StartedAt),
+		UpdatedAt:     timestamppb.New(progress.UpdatedAt),
+	}
+
+	if progress.Err != nil {
+		response.Progress.Error = progress.Err.Error()
+	}
+
+	return response
+}
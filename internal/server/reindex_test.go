@@ -0,0 +1,458 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/audit"
+	"github.com/eliassebastian/r6index-recommendation/internal/reindex"
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
+	pb "github.com/eliassebastian/r6index-recommendation/pkg/proto/server"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// memoryCollections keeps in-memory stores behind an alias, like the weaviate aliases
+type memoryCollections struct {
+	mutex      sync.Mutex
+	switchedAt time.Time
+	stores     map[string]*store.Memory
+	versions   map[string]int
+	active     string
+	previous   string
+}
+
+func newMemoryCollections(active string, schemaVersion int) *memoryCollections {
+	return &memoryCollections{
+		stores:   map[string]*store.Memory{active: store.NewMemory()},
+		versions: map[string]int{active: schemaVersion},
+		active:   active,
+	}
+}
+
+func (c *memoryCollections) Name() string {
+	return "Players"
+}
+
+func (c *memoryCollections) Active() (string, int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.active, c.versions[c.active]
+}
+
+func (c *memoryCollections) collection(name string) *store.Memory {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.stores[name]
+}
+
+func (c *memoryCollections) Backfill(ctx context.Context, target string, schema vectors.Schema) (*reindex.Job, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.stores[target] == nil {
+		c.stores[target] = store.NewMemory()
+	}
+	return &reindex.Job{Source: aliasStore{c}, Target: c.stores[target], TargetName: target, Schema: schema}, nil
+}
+
+func (c *memoryCollections) Promote(ctx context.Context, target string, schemaVersion int) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.previous, c.active = c.active, target
+	c.versions[target] = schemaVersion
+	c.switchedAt = time.Now()
+	return nil
+}
+
+func (c *memoryCollections) Rollback(ctx context.Context, deleted reindex.Deletions) (string, int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.previous == "" {
+		return "", 0, reindex.ErrNoPrevious
+	}
+
+	ids, err := deleted(c.switchedAt)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if err := c.stores[c.previous].Delete(ctx, ids); err != nil {
+		return "", 0, err
+	}
+
+	c.previous, c.active = c.active, c.previous
+	c.switchedAt = time.Now()
+	return c.active, c.versions[c.active], nil
+}
+
+// aliasStore reads and writes whichever store the alias points to
+type aliasStore struct {
+	collections *memoryCollections
+}
+
+func (a aliasStore) current() *store.Memory {
+	name, _ := a.collections.Active()
+	return a.collections.collection(name)
+}
+
+func (a aliasStore) Upsert(ctx context.Context, players []*store.Player) error {
+	return a.current().Upsert(ctx, players)
+}
+func (a aliasStore) Delete(ctx context.Context, ids []string) error {
+	return a.current().Delete(ctx, ids)
+}
+func (a aliasStore) Get(ctx context.Context, id string) (*store.Player, error) {
+	return a.current().Get(ctx, id)
+}
+func (a aliasStore) History(ctx context.Context, id string) ([]*store.Player, error) {
+	return a.current().History(ctx, id)
+}
+func (a aliasStore) Scan(ctx context.Context, cursor string, limit int) ([]*store.Player, string, error) {
+	return a.current().Scan(ctx, cursor, limit)
+}
+func (a aliasStore) Count(ctx context.Context) (int, error) {
+	return a.current().Count(ctx)
+}
+func (a aliasStore) NearVector(ctx context.Context, query store.Query) ([]store.Hit, error) {
+	return a.current().NearVector(ctx, query)
+}
+
+// waitForTarget polls ReindexStatus until reads go to target
+func waitForTarget(t *testing.T, client pb.RecommendationServiceClient, target string) *pb.ReindexResponse {
+	var response *pb.ReindexResponse
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		var err error
+		if response, err = client.ReindexStatus(context.Background(), &pb.ReindexStatusRequest{}); err != nil {
+			t.Fatalf("ReindexStatus() error = %v, want nil", err)
+		}
+
+		if response.GetActiveTarget() == target {
+			return response
+		}
+	}
+
+	t.Fatalf("ReindexStatus() = %v, want reads switched to %s", response, target)
+	return nil
+}
+
+func TestRecommendationServiceServer_Reindex(t *testing.T) {
+	ctx := context.Background()
+	collections := newMemoryCollections("Players_v3", vectors.SchemaV3.Version)
+	checkpoint := filepath.Join(t.TempDir(), "reindex.checkpoint")
+
+	recommendationServer := NewRecommendationServer(aliasStore{collections}, audit.New(io.Discard), 1, time.Minute, WithReindex(ctx, collections, checkpoint))
+	client := newTestClient(t, recommendationServer)
+
+	for _, player := range testPlayers {
+		if _, err := client.Index(ctx, player); err != nil {
+			t.Fatalf("Index() error = %v, want nil", err)
+		}
+	}
+
+	for _, in := range []*pb.ReindexRequest{{}, {Target: "Players_v3"}, {Target: "Players_v4", SchemaVersion: 99}} {
+		if _, err := client.Reindex(ctx, in); status.Code(err) != codes.Code(400) {
+			t.Errorf("Reindex(%v) error = %v, want 400", in, err)
+		}
+	}
+
+	if _, err := client.Rollback(ctx, &pb.RollbackRequest{}); status.Code(err) != codes.Code(412) {
+		t.Errorf("Rollback() error = %v, want 412 before any re-index", err)
+	}
+
+	if _, err := client.Reindex(ctx, &pb.ReindexRequest{Target: "Players_v4", SchemaVersion: int32(vectors.SchemaV4.Version)}); err != nil {
+		t.Fatalf("Reindex() error = %v, want nil", err)
+	}
+
+	response := waitForTarget(t, client, "Players_v4")
+	if response.GetActiveSchemaVersion() != int32(vectors.SchemaV4.Version) {
+		t.Errorf("ReindexStatus() schema = v%d, want v%d", response.GetActiveSchemaVersion(), vectors.SchemaV4.Version)
+	}
+
+	if progress := response.GetProgress(); !progress.GetDone() || progress.GetCopied() != int64(len(testPlayers)) || progress.GetTotal() != int64(len(testPlayers)) {
+		t.Errorf("ReindexStatus() progress = %v, want %d of %d copied", progress, len(testPlayers), len(testPlayers))
+	}
+
+	// reads and writes go through the alias, which now points to the re-indexed collection
+	late := &pb.Request{Id: "6844b415-aa94-43c9-8823-9389e4816999", Level: 120, Kost: 0.6, Rank: 20, RankPoints: 2000, Season: 30}
+	if _, err := client.Index(ctx, late); err != nil {
+		t.Fatalf("Index() error = %v, want nil", err)
+	}
+
+	if blue, green := collections.collection("Players_v3").Len(), collections.collection("Players_v4").Len(); blue != len(testPlayers) || green != len(testPlayers)+1 {
+		t.Errorf("Players_v3 has %d records and Players_v4 %d, want %d and %d", blue, green, len(testPlayers), len(testPlayers)+1)
+	}
+
+	player, err := client.GetPlayer(ctx, &pb.GetPlayerRequest{Id: testPlayers[0].Id})
+	if err != nil || player.GetPlayer().GetSchemaVersion() != int32(vectors.SchemaV4.Version) {
+		t.Errorf("GetPlayer() = %v, %v, want a v%d player", player.GetPlayer(), err, vectors.SchemaV4.Version)
+	}
+
+	response, err = client.Rollback(ctx, &pb.RollbackRequest{})
+	if err != nil || response.GetActiveTarget() != "Players_v3" || response.GetActiveSchemaVersion() != int32(vectors.SchemaV3.Version) {
+		t.Fatalf("Rollback() = %v, %v, want reads back on Players_v3 with v%d", response, err, vectors.SchemaV3.Version)
+	}
+
+	recommendations, err := client.Recommend(ctx, &pb.RecommendRequest{Id: testPlayers[0].Id, Limit: 10})
+	if err != nil || len(recommendations.GetRecommendations()) != len(testPlayers)-1 {
+		t.Errorf("Recommend() after rollback = %v, %v, want the other %d v3 players", recommendationIDs(recommendations), err, len(testPlayers)-1)
+	}
+}
+
+func TestRecommendationServiceServer_ReindexRefitNormalization(t *testing.T) {
+	ctx := context.Background()
+	collections := newMemoryCollections("Players_v4", vectors.SchemaV4.Version)
+
+	// a schema config replacing the normalization, e.g. with the refit of Statistics
+	refit := vectors.Normalization{Mean: make([]float32, vectors.Current.Dimension()), StdDev: make([]float32, vectors.Current.Dimension())}
+	for i := range refit.StdDev {
+		refit.Mean[i], refit.StdDev[i] = 1, 4
+	}
+
+	schema, err := (vectors.Config{Version: 95, Normalization: &refit}).Schema()
+	if err != nil {
+		t.Fatalf("Config.Schema() error = %v, want nil", err)
+	}
+	if err := vectors.Register(schema); err != nil {
+		t.Fatalf("Register() error = %v, want nil", err)
+	}
+	t.Cleanup(func() { delete(vectors.Schemas, schema.Version) })
+
+	recommendationServer := NewRecommendationServer(aliasStore{collections}, audit.New(io.Discard), 1, time.Minute, WithReindex(ctx, collections, ""))
+	client := newTestClient(t, recommendationServer)
+
+	for _, player := range testPlayers {
+		if _, err := client.Index(ctx, player); err != nil {
+			t.Fatalf("Index() error = %v, want nil", err)
+		}
+	}
+
+	if _, err := client.Reindex(ctx, &pb.ReindexRequest{Target: "Players_v95", SchemaVersion: int32(schema.Version)}); err != nil {
+		t.Fatalf("Reindex() error = %v, want nil", err)
+	}
+
+	response := waitForTarget(t, client, "Players_v95")
+	if response.GetActiveSchemaVersion() != int32(schema.Version) {
+		t.Errorf("ReindexStatus() schema = v%d, want v%d", response.GetActiveSchemaVersion(), schema.Version)
+	}
+
+	player, err := collections.collection("Players_v95").Get(ctx, testPlayers[0].GetId())
+	if err != nil {
+		t.Fatalf("Memory.Get() error = %v, want nil", err)
+	}
+
+	if want := refit.Apply(vectors.Current.Raw(player.Stats)); player.SchemaVersion != schema.Version || !reflect.DeepEqual(player.Vector, want) {
+		t.Errorf("re-indexed player = v%d %v, want v%d %v with the refit", player.SchemaVersion, player.Vector, schema.Version, want)
+	}
+}
+
+func TestRecommendationServiceServer_ReindexNotEnabled(t *testing.T) {
+	recommendationServer, _ := newTestServer()
+	client := newTestClient(t, recommendationServer)
+
+	if _, err := client.Reindex(context.Background(), &pb.ReindexRequest{Target: "Players_v4"}); status.Code(err) != codes.Code(501) {
+		t.Errorf("Reindex() error = %v, want 501", err)
+	}
+}
+
+// failingCollections backfills into targets whose first upserts fail
+type failingCollections struct {
+	*memoryCollections
+	failures int
+}
+
+func (c *failingCollections) Backfill(ctx context.Context, target string, schema vectors.Schema) (*reindex.Job, error) {
+	job, err := c.memoryCollections.Backfill(ctx, target, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	job.Target = &failingUpserts{Store: job.Target, collections: c}
+	return job, nil
+}
+
+type failingUpserts struct {
+	store.Store
+	collections *failingCollections
+}
+
+func (f *failingUpserts) Upsert(ctx context.Context, players []*store.Player) error {
+	f.collections.mutex.Lock()
+	failing := f.collections.failures > 0
+	if failing {
+		f.collections.failures--
+	}
+	f.collections.mutex.Unlock()
+
+	if failing {
+		return store.ErrUnavailable
+	}
+	return f.Store.Upsert(ctx, players)
+}
+
+func TestRecommendationServiceServer_ReindexAfterFailure(t *testing.T) {
+	ctx := context.Background()
+	collections := &failingCollections{memoryCollections: newMemoryCollections("Players_v3", vectors.SchemaV3.Version), failures: 1}
+	checkpoint := filepath.Join(t.TempDir(), "reindex.checkpoint")
+
+	recommendationServer := NewRecommendationServer(aliasStore{collections.memoryCollections}, audit.New(io.Discard), 1, time.Minute, WithReindex(ctx, collections, checkpoint))
+	client := newTestClient(t, recommendationServer)
+
+	for _, player := range testPlayers {
+		if _, err := client.Index(ctx, player); err != nil {
+			t.Fatalf("Index() error = %v, want nil", err)
+		}
+	}
+
+	// a job that copied the first players before failing
+	players, cursor, _ := collections.collection("Players_v3").Scan(ctx, "", 2)
+	saved := reindex.Checkpoint{Target: "Players_v4", SchemaVersion: vectors.SchemaV4.Version, Cursor: cursor, Copied: len(players), StartedAt: time.Now().UTC()}
+	if err := saved.Save(checkpoint); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Reindex(ctx, &pb.ReindexRequest{Target: "Players_v4", SchemaVersion: int32(vectors.SchemaV4.Version)}); err != nil {
+		t.Fatalf("Reindex() error = %v, want nil", err)
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		response, err := client.ReindexStatus(ctx, &pb.ReindexStatusRequest{})
+		if err != nil {
+			t.Fatalf("ReindexStatus() error = %v, want nil", err)
+		}
+
+		if response.GetProgress().GetError() != "" {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("ReindexStatus() = %v, want the re-index failed", response)
+		}
+	}
+
+	// writes weren't mirrored since the failure, so the next job starts over
+	if saved, err := reindex.LoadCheckpoint(checkpoint); err != nil || saved != nil {
+		t.Errorf("LoadCheckpoint() = %+v, %v, want the checkpoint dropped", saved, err)
+	}
+
+	if _, err := client.Reindex(ctx, &pb.ReindexRequest{Target: "Players_v4", SchemaVersion: int32(vectors.SchemaV4.Version)}); err != nil {
+		t.Fatalf("Reindex() error = %v, want nil", err)
+	}
+
+	response := waitForTarget(t, client, "Players_v4")
+	if copied := response.GetProgress().GetCopied(); copied != int64(len(testPlayers)) {
+		t.Errorf("ReindexStatus() copied = %d, want all %d players", copied, len(testPlayers))
+	}
+}
+
+func TestRecommendationServiceServer_ReindexResumesOnStart(t *testing.T) {
+	ctx := context.Background()
+	collections := newMemoryCollections("Players_v3", vectors.SchemaV3.Version)
+	checkpoint := filepath.Join(t.TempDir(), "reindex.checkpoint")
+
+	for _, in := range testPlayers {
+		stats := statsFromRequest(in)
+		player := &store.Player{ID: in.GetId(), Stats: stats, Vector: vectors.SchemaV3.Vector(stats), SchemaVersion: vectors.SchemaV3.Version}
+		if err := collections.collection("Players_v3").Upsert(ctx, []*store.Player{player}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the server stopped after the first two players were copied
+	players, cursor, _ := collections.collection("Players_v3").Scan(ctx, "", 2)
+	saved := reindex.Checkpoint{Target: "Players_v4", SchemaVersion: vectors.SchemaV4.Version, Cursor: cursor, Copied: len(players), StartedAt: time.Now().UTC()}
+	if err := saved.Save(checkpoint); err != nil {
+		t.Fatal(err)
+	}
+
+	recommendationServer := NewRecommendationServer(aliasStore{collections}, audit.New(io.Discard), 1, time.Minute, WithReindex(ctx, collections, checkpoint))
+	client := newTestClient(t, recommendationServer)
+
+	response := waitForTarget(t, client, "Players_v4")
+	if progress := response.GetProgress(); progress.GetCopied() != int64(len(testPlayers)) {
+		t.Errorf("ReindexStatus() copied = %d, want %d counting the checkpoint's", progress.GetCopied(), len(testPlayers))
+	}
+
+	// the players before the cursor aren't copied again
+	if copied := collections.collection("Players_v4").Len(); copied != len(testPlayers)-len(players) {
+		t.Errorf("Players_v4 has %d records, want the %d after the checkpoint", copied, len(testPlayers)-len(players))
+	}
+}
+
+func TestRecommendationServiceServer_RollbackReplaysDeletes(t *testing.T) {
+	ctx := context.Background()
+	collections := newMemoryCollections("Players_v3", vectors.SchemaV3.Version)
+
+	auditLog, err := audit.Open(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer auditLog.Close()
+
+	recommendationServer := NewRecommendationServer(aliasStore{collections}, auditLog, 1, time.Minute, WithReindex(ctx, collections, filepath.Join(t.TempDir(), "reindex.checkpoint")))
+	client := newTestClient(t, recommendationServer)
+
+	for _, player := range testPlayers {
+		if _, err := client.Index(ctx, player); err != nil {
+			t.Fatalf("Index() error = %v, want nil", err)
+		}
+	}
+
+	if _, err := client.Reindex(ctx, &pb.ReindexRequest{Target: "Players_v4", SchemaVersion: int32(vectors.SchemaV4.Version)}); err != nil {
+		t.Fatalf("Reindex() error = %v, want nil", err)
+	}
+	waitForTarget(t, client, "Players_v4")
+
+	// erased after the promote, Players_v3 never saw it
+	if _, err := client.Delete(ctx, &pb.DeleteRequest{Id: testPlayers[1].Id, Erasure: true}); err != nil {
+		t.Fatalf("Delete() error = %v, want nil", err)
+	}
+
+	if _, err := client.Rollback(ctx, &pb.RollbackRequest{}); err != nil {
+		t.Fatalf("Rollback() error = %v, want nil", err)
+	}
+
+	if _, err := collections.collection("Players_v3").Get(ctx, testPlayers[1].Id); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Players_v3 erased player error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	if got := collections.collection("Players_v3").Len(); got != len(testPlayers)-1 {
+		t.Errorf("Players_v3 has %d records, want %d", got, len(testPlayers)-1)
+	}
+}
+
+func TestRecommendationServiceServer_FollowsCollections(t *testing.T) {
+	ctx := context.Background()
+	collections := newMemoryCollections("Players_v3", vectors.SchemaV3.Version)
+	recommendationServer := NewRecommendationServer(aliasStore{collections}, audit.New(io.Discard), 1, time.Minute, WithReindex(ctx, collections, filepath.Join(t.TempDir(), "reindex.checkpoint")))
+	client := newTestClient(t, recommendationServer)
+
+	// another replica re-indexed and promoted
+	collections.stores["Players_v4"] = store.NewMemory()
+	if err := collections.Promote(ctx, "Players_v4", vectors.SchemaV4.Version); err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := client.ReindexStatus(ctx, &pb.ReindexStatusRequest{})
+	if err != nil || response.GetActiveTarget() != "Players_v4" || response.GetActiveSchemaVersion() != int32(vectors.SchemaV4.Version) {
+		t.Errorf("ReindexStatus() = %v, %v, want reads on Players_v4 with v%d", response, err, vectors.SchemaV4.Version)
+	}
+
+	// players indexed now are built with the promoted schema
+	if _, err := client.Index(ctx, testPlayers[0]); err != nil {
+		t.Fatalf("Index() error = %v, want nil", err)
+	}
+
+	if player, err := collections.collection("Players_v4").Get(ctx, testPlayers[0].Id); err != nil || player.SchemaVersion != vectors.SchemaV4.Version {
+		t.Errorf("Players_v4 player = %+v, %v, want a v%d record", player, err, vectors.SchemaV4.Version)
+	}
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+	pb "github.com/eliassebastian/r6index-recommendation/pkg/proto/server"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultBlendSeasons = 3
+	maxBlendSeasons     = 20
+	defaultBlendDecay   = 0.5
+)
+
+// seasonRecord returns the record of the player's season, 0 is the latest season
+// stored and negative seasons count back from it
+func (g *generation) seasonRecord(ctx context.Context, id string, season int) (*store.Player, []*store.Player, error) {
+	history, err := g.store.History(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil, status.Error(404, "id = player not found")
+	}
+
+	if err != nil {
+		slog.ErrorContext(ctx, "getting player history", "generation", g.name, "player", id, "err", err)
+		return nil, nil, storeError(err, "store = could not get player")
+	}
+
+	// a record without a season was indexed before Index required one, stores replace
+	// it on the next write but may still hold one
+	if len(history) > 1 && history[0].Stats.Season == 0 {
+		history = history[1:]
+	}
+
+	latest := history[len(history)-1]
+	if season <= 0 {
+		season += latest.Stats.Season
+	}
+
+	for i, player := range history {
+		if player.Stats.Season == season {
+			return player, history[:i+1], nil
+		}
+	}
+
+	return nil, nil, status.Errorf(404, "season = no stats indexed for season %d", season)
+}
+
+// seasonVector is the query vector of a player's season, optionally blended with the
+// seasons before it. The weight of a season decays with every season it lies back
+func (g *generation) seasonVector(ctx context.Context, id string, season int, blend *pb.SeasonBlend) (*store.Player, []float32, error) {
+	player, history, err := g.seasonRecord(ctx, id, season)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if blend == nil {
+		return player, g.storedVector(player), nil
+	}
+
+	seasons, decay := int(blend.GetSeasons()), defaultBlendDecay
+	if seasons == 0 {
+		seasons = defaultBlendSeasons
+	}
+
+	// a decay of 0 only weighs the query season
+	if blend.Decay != nil {
+		decay = float64(blend.GetDecay())
+	}
+
+	if seasons < 1 || seasons > maxBlendSeasons {
+		return nil, nil, status.Errorf(400, "season_blend = seasons must be between 1 and %d", maxBlendSeasons)
+	}
+
+	if decay < 0 || decay > 1 {
+		return nil, nil, status.Error(400, "season_blend = decay must be between 0 and 1")
+	}
+
+	var total float64
+	vector := make([]float32, g.schema.Dimension())
+	for _, record := range history {
+		back := player.Stats.Season - record.Stats.Season
+		if back >= seasons {
+			continue
+		}
+
+		weight := math.Pow(decay, float64(back))
+		for i, val := range g.storedVector(record) {
+			if i < len(vector) {
+				vector[i] += float32(weight) * val
+			}
+		}
+		total += weight
+	}
+
+	for i := range vector {
+		vector[i] /= float32(total)
+	}
+
+	return player, vector, nil
+}
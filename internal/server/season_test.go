@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
+	pb "github.com/eliassebastian/r6index-recommendation/pkg/proto/server"
+	"google.golang.org/protobuf/proto"
+)
+
+// players of the season tests, me moved from playing like then to playing like now
+const (
+	meID   = "6844b415-aa94-43c9-8823-938900000001"
+	nowID  = "6844b415-aa94-43c9-8823-938900000002"
+	thenID = "6844b415-aa94-43c9-8823-938900000003"
+)
+
+func TestRecommendationServiceServer_RecommendBySeason(t *testing.T) {
+	ctx := context.Background()
+	recommendationServer, memory := newTestServer()
+	client := newTestClient(t, recommendationServer)
+
+	// me levelled up a lot between seasons 29 and 30, now plays like me this season and
+	// then like me last season
+	players := []*pb.Request{
+		{Id: meID, Level: 100, Kost: 0.6, Rank: 20, RankPoints: 2500, Season: 29},
+		{Id: meID, Level: 300, Kost: 0.6, Rank: 20, RankPoints: 2500, Season: 30},
+		{Id: nowID, Level: 290, Kost: 0.6, Rank: 20, RankPoints: 2500, Season: 30},
+		{Id: thenID, Level: 110, Kost: 0.6, Rank: 20, RankPoints: 2500, Season: 29},
+	}
+	for _, player := range players {
+		if _, err := client.Index(ctx, player); err != nil {
+			t.Fatalf("Index() error = %v, want nil", err)
+		}
+	}
+
+	if memory.Len() != 4 {
+		t.Errorf("stored records = %v, want %v", memory.Len(), 4)
+	}
+
+	seasonFilter := []*pb.Filter{{Property: "season", Operator: pb.FilterOperator_EQUAL, Number: 30}}
+
+	testCases := []struct {
+		name    string
+		request *pb.RecommendRequest
+		want    []string
+	}{
+		{"latest season", &pb.RecommendRequest{Id: meID}, []string{nowID, thenID}},
+		{"last season", &pb.RecommendRequest{Id: meID, QuerySeason: -1}, []string{thenID, nowID}},
+		{"absolute season", &pb.RecommendRequest{Id: meID, QuerySeason: 29}, []string{thenID, nowID}},
+		{"last season against this season", &pb.RecommendRequest{Id: meID, QuerySeason: -1, Filters: seasonFilter}, []string{nowID}},
+	}
+
+	for _, testCase := range testCases {
+		response, err := client.Recommend(ctx, testCase.request)
+		if err != nil {
+			t.Fatalf("%s: Recommend() error = %v, want nil", testCase.name, err)
+		}
+
+		if got := recommendationIDs(response); !reflect.DeepEqual(got, testCase.want) {
+			t.Errorf("%s: Recommend() = %v, want %v", testCase.name, got, testCase.want)
+		}
+	}
+
+	// half the weight on last season pulls the query from level 300 to 233.3
+	response, err := client.Recommend(ctx, &pb.RecommendRequest{Id: meID, SeasonBlend: &pb.SeasonBlend{Seasons: 2, Decay: proto.Float32(0.5)}})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v, want nil", err)
+	}
+
+	nearest := response.GetRecommendations()[0]
+	if diff := nearest.GetDistance() - 0.3211; nearest.GetId() != nowID || diff > 1e-3 || diff < -1e-3 {
+		t.Errorf("Recommend(blend) = %s at %v, want now at 0.3211", nearest.GetId(), nearest.GetDistance())
+	}
+
+	// a decay of 0 only weighs the query season
+	response, err = client.Recommend(ctx, &pb.RecommendRequest{Id: meID, SeasonBlend: &pb.SeasonBlend{Seasons: 2, Decay: proto.Float32(0)}})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v, want nil", err)
+	}
+
+	unblended, err := client.Recommend(ctx, &pb.RecommendRequest{Id: meID})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v, want nil", err)
+	}
+
+	if got, want := response.GetRecommendations()[0].GetDistance(), unblended.GetRecommendations()[0].GetDistance(); got != want {
+		t.Errorf("Recommend(decay 0) distance = %v, want the unblended %v", got, want)
+	}
+
+	// a player is only recommended once, for the season nearest to the query
+	if _, err := client.Index(ctx, &pb.Request{Id: nowID, Level: 105, Kost: 0.6, Rank: 20, RankPoints: 2500, Season: 29}); err != nil {
+		t.Fatalf("Index() error = %v, want nil", err)
+	}
+
+	response, err = client.Recommend(ctx, &pb.RecommendRequest{Id: meID, QuerySeason: -1})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v, want nil", err)
+	}
+
+	if got := recommendationIDs(response); !reflect.DeepEqual(got, []string{nowID, thenID}) || response.GetRecommendations()[0].GetSeason() != 29 {
+		t.Errorf("Recommend() = %v, want now once for season 29", response.GetRecommendations())
+	}
+
+	player, err := client.GetPlayer(ctx, &pb.GetPlayerRequest{Id: meID, Season: 29})
+	if err != nil || player.GetPlayer().GetLevel() != 100 {
+		t.Errorf("GetPlayer(season 29) = %v, %v, want level 100", player.GetPlayer(), err)
+	}
+
+	errorCases := []struct {
+		err  error
+		want string
+	}{
+		{recommendErr(client.Recommend(ctx, &pb.RecommendRequest{Id: meID, QuerySeason: -2})), "rpc error: code = Code(404) desc = season = no stats indexed for season 28"},
+		{recommendErr(client.Recommend(ctx, &pb.RecommendRequest{Id: meID, SeasonBlend: &pb.SeasonBlend{Decay: proto.Float32(2)}})), "rpc error: code = Code(400) desc = season_blend = decay must be between 0 and 1"},
+		{getPlayerErr(client.GetPlayer(ctx, &pb.GetPlayerRequest{Id: meID, Season: 31})), "rpc error: code = Code(404) desc = season = no stats indexed for season 31"},
+	}
+
+	for _, errorCase := range errorCases {
+		if errorCase.err == nil || errorCase.err.Error() != errorCase.want {
+			t.Errorf("err = %v, want %q", errorCase.err, errorCase.want)
+		}
+	}
+}
+
+func recommendErr(_ *pb.RecommendResponse, err error) error { return err }
+
+func getPlayerErr(_ *pb.GetPlayerResponse, err error) error { return err }
+
+func TestGenerationSeasonRecordSkipsUnseasoned(t *testing.T) {
+	ctx := context.Background()
+	recommendationServer, memory := newTestServer()
+
+	// a store that kept the record indexed before seasons were
+	stale := &store.Player{ID: meID, Stats: vectors.Player{Level: 100}, Vector: []float32{1}, SchemaVersion: vectors.Current.Version}
+	memory.Upsert(ctx, []*store.Player{stale})
+	memory.Upsert(ctx, []*store.Player{{ID: meID, Stats: vectors.Player{Level: 300, Season: 30}, Vector: []float32{3}, SchemaVersion: vectors.Current.Version}})
+	memory.Upsert(ctx, []*store.Player{stale})
+
+	_, history, err := recommendationServer.active.Load().seasonRecord(ctx, meID, 0)
+	if err != nil || len(history) != 1 || history[0].Stats.Season != 30 {
+		t.Errorf("seasonRecord() history = %v, %v, want only season 30", history, err)
+	}
+
+	if _, _, err := recommendationServer.active.Load().seasonRecord(ctx, meID, -1); err == nil {
+		t.Errorf("seasonRecord(-1) error = nil, want no season 29")
+	}
+}
@@ -2,13 +2,129 @@ package server
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/eliassebastian/r6index-recommendation/internal/audit"
+	"github.com/eliassebastian/r6index-recommendation/internal/batch"
+	"github.com/eliassebastian/r6index-recommendation/internal/calibration"
+	"github.com/eliassebastian/r6index-recommendation/internal/exclusion"
+	"github.com/eliassebastian/r6index-recommendation/internal/logging"
+	"github.com/eliassebastian/r6index-recommendation/internal/statistics"
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
 	pb "github.com/eliassebastian/r6index-recommendation/pkg/proto/server"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type RecommendationServer struct {
 	pb.UnimplementedRecommendationServiceServer
+	audit      *audit.Log
+	pipeline   *batch.BatchPipeline
+	exclusions *exclusion.Store
+	calibrator *calibration.Calibrator
+	statistics *statistics.Collector
+	// cache holds recent recommendation responses, nil when they aren't cached
+	cache *recommendationCache
+	// pageTokenKey signs page tokens
+	pageTokenKey []byte
+	// weightedOverfetch is the number of candidates re-ranked per weighted result
+	weightedOverfetch int
+	// active is the generation reads and writes go to, shadow the one a running
+	// re-index builds. writes orders store writes against generation switches
+	active  atomic.Pointer[generation]
+	shadow  atomic.Pointer[generation]
+	writes  sync.Mutex
+	reindex *reindexer
+	// flushed is told about the operations leaving the pipeline, written or dropped,
+	// nil when nothing follows them
+	flushed func(ops []operation, written bool)
+}
+
+// Option configures optional RecommendationServer dependencies
+type Option func(*RecommendationServer)
+
+// WithExclusions replaces the default exclusion store, which forgets recent teammates
+// after a day and isn't persisted
+func WithExclusions(exclusions *exclusion.Store) Option {
+	return func(s *RecommendationServer) {
+		s.exclusions = exclusions
+	}
+}
+
+// WithSchema replaces the schema new vectors are built and queried with, vectors.Current by default
+func WithSchema(schema vectors.Schema) Option {
+	return func(s *RecommendationServer) {
+		active := *s.generation()
+		active.schema = schema
+		s.active.Store(&active)
+	}
+}
+
+// WithCalibrator replaces the default calibrator, which is never refitted
+func WithCalibrator(calibrator *calibration.Calibrator) Option {
+	return func(s *RecommendationServer) {
+		s.calibrator = calibrator
+	}
+}
+
+// WithStatistics replaces the default in-memory feature statistics, which start empty
+// and aren't persisted. The collector must be for the server's schema
+func WithStatistics(collector *statistics.Collector) Option {
+	return func(s *RecommendationServer) {
+		s.statistics = collector
+	}
+}
+
+// WithPageTokenKey replaces the random key page tokens are signed with, replicas must
+// share a key to accept each other's tokens
+func WithPageTokenKey(key []byte) Option {
+	return func(s *RecommendationServer) {
+		s.pageTokenKey = key
+	}
+}
+
+// WithWeightedOverfetch replaces the number of nearest players re-ranked per result of
+// a weighted query, 4 by default. The store ranks by the unweighted distance, a wider
+// net finds more of the players that are only near by the weighted one
+func WithWeightedOverfetch(factor int) Option {
+	return func(s *RecommendationServer) {
+		s.weightedOverfetch = factor
+	}
+}
+
+func NewRecommendationServer(store store.Store, audit *audit.Log, maxBatchSize int, maxBatchWait time.Duration, opts ...Option) *RecommendationServer {
+	s := &RecommendationServer{
+		audit:             audit,
+		exclusions:        exclusion.New(24 * time.Hour),
+		calibrator:        calibration.New(500),
+		pageTokenKey:      make([]byte, 32),
+		weightedOverfetch: defaultWeightedOverfetch,
+	}
+	rand.Read(s.pageTokenKey)
+	s.active.Store(&generation{store: store, schema: vectors.Current})
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.statistics == nil {
+		s.statistics = statistics.New(s.generation().schema)
+	}
+
+	s.pipeline = batch.NewBatchPipeline(maxBatchSize, maxBatchWait, s.flush)
+	if s.reindex != nil {
+		s.resumeReindex()
+	}
+	return s
 }
 
 func (s *RecommendationServer) Index(ctx context.Context, in *pb.Request) (*pb.Response, error) {
@@ -17,8 +133,247 @@ func (s *RecommendationServer) Index(ctx context.Context, in *pb.Request) (*pb.R
 		return &pb.Response{}, status.Error(400, "id = empty player id")
 	}
 
+	if !store.ValidID(in.GetId()) {
+		return &pb.Response{}, status.Error(codes.InvalidArgument, "id = not a UUID")
+	}
+
+	// a record per season, stats without one would be taken for a season of their own
+	if in.GetSeason() < 1 {
+		return &pb.Response{}, status.Error(codes.InvalidArgument, "season = required, seasons are numbered from 1")
+	}
+
+	if s.audit.Erased(in.GetId()) {
+		return &pb.Response{}, status.Error(410, "id = player has been erased")
+	}
+
+	g := s.generation()
+	stats := statsFromRequest(in)
+	vector := g.vectorize(stats)
+	now := time.Now().UTC()
+
+	// a player being indexed has just been seen unless told otherwise
+	lastSeen := now
+	if in.GetLastSeen() != nil {
+		lastSeen = in.GetLastSeen().AsTime()
+	}
+
+	s.pipeline.Add(operation{
+		kind:      upsertOperation,
+		requestID: logging.RequestID(ctx),
+		player: &store.Player{
+			ID:            in.GetId(),
+			Stats:         stats,
+			Vector:        vector,
+			SchemaVersion: g.schema.Version,
+			UpdatedAt:     now,
+			Platform:      in.GetPlatform(),
+			Region:        in.GetRegion(),
+			Language:      in.GetLanguage(),
+			LastSeen:      lastSeen,
+		},
+	})
+
+	s.calibrator.Observe(in.GetId(), vector)
+	// estimates are of one schema's raw values, a re-index to another pauses them until restart
+	if s.statistics.SchemaVersion() == g.schema.Version {
+		s.statistics.Observe(in.GetId(), g.schema.Raw(stats))
+	}
+
+	return &pb.Response{
+		Code:    200,
+		Message: "OK",
+	}, nil
+}
+
+func (s *RecommendationServer) Delete(ctx context.Context, in *pb.DeleteRequest) (*pb.Response, error) {
+
+	if in.GetId() == "" {
+		return &pb.Response{}, status.Error(400, "id = empty player id")
+	}
+
+	if !store.ValidID(in.GetId()) {
+		return &pb.Response{}, status.Error(codes.InvalidArgument, "id = not a UUID")
+	}
+
+	if err := s.delete(ctx, []string{in.GetId()}, in.GetErasure(), in.GetReason()); err != nil {
+		return &pb.Response{}, err
+	}
+
 	return &pb.Response{
 		Code:    200,
 		Message: "OK",
 	}, nil
 }
+
+func (s *RecommendationServer) BulkDelete(ctx context.Context, in *pb.BulkDeleteRequest) (*pb.Response, error) {
+
+	if len(in.GetIds()) == 0 {
+		return &pb.Response{}, status.Error(400, "ids = no player ids")
+	}
+
+	for _, id := range in.GetIds() {
+		if id == "" {
+			return &pb.Response{}, status.Error(400, "ids = empty player id")
+		}
+
+		if !store.ValidID(id) {
+			return &pb.Response{}, status.Error(codes.InvalidArgument, fmt.Sprintf("ids = %q is not a UUID", id))
+		}
+	}
+
+	// every id is valid, the request is audited and deleted as a whole
+	if err := s.delete(ctx, in.GetIds(), in.GetErasure(), in.GetReason()); err != nil {
+		return &pb.Response{}, err
+	}
+
+	return &pb.Response{
+		Code:    200,
+		Message: "OK",
+	}, nil
+}
+
+func (s *RecommendationServer) GetPlayer(ctx context.Context, in *pb.GetPlayerRequest) (*pb.GetPlayerResponse, error) {
+
+	if in.GetId() == "" {
+		return &pb.GetPlayerResponse{}, status.Error(400, "id = empty player id")
+	}
+
+	if in.GetSeason() < 0 {
+		return &pb.GetPlayerResponse{}, status.Error(400, "season = must not be negative")
+	}
+
+	player, _, err := s.generation().seasonRecord(ctx, in.GetId(), int(in.GetSeason()))
+	if err != nil {
+		return &pb.GetPlayerResponse{}, err
+	}
+
+	return &pb.GetPlayerResponse{
+		Code:    200,
+		Message: "OK",
+		Player: &pb.Player{
+			Id:                player.ID,
+			Level:             int32(player.Stats.Level),
+			Kost:              float32(player.Stats.Kost),
+			Rank:              int32(player.Stats.Rank),
+			RankPoints:        int32(player.Stats.RankPoints),
+			Vector:            player.Vector,
+			SchemaVersion:     int32(player.SchemaVersion),
+			UpdatedAt:         timestamppb.New(player.UpdatedAt),
+			Platform:          player.Platform,
+			Region:            player.Region,
+			Language:          player.Language,
+			LastSeen:          timestamppb.New(player.LastSeen),
+			WinRate:           float32(player.Stats.WinRate),
+			HeadshotRate:      float32(player.Stats.HeadshotRate),
+			Kd:                float32(player.Stats.KD),
+			MatchesPlayed:     int32(player.Stats.MatchesPlayed),
+			TimePlayed:        durationpb.New(player.Stats.TimePlayed),
+			PreferredRole:     player.Stats.PreferredRole,
+			OperatorPickRates: float32Map(player.Stats.OperatorPickRates),
+			Season:            int32(player.Stats.Season),
+		},
+	}, nil
+}
+
+// delete records the request in the audit log as a single entry before queueing it as
+// a single operation, so an erasure tombstone is in place before any later Index call
+// can be accepted and a failed request deletes none of the players
+func (s *RecommendationServer) delete(ctx context.Context, ids []string, erasure bool, reason string) error {
+	entry := audit.Entry{Action: audit.ActionDelete, Reason: reason}
+	if s.reindex != nil {
+		entry.Collection = s.reindex.collections.Name()
+	}
+	if erasure {
+		entry.Action = audit.ActionErasure
+	}
+
+	if len(ids) == 1 {
+		entry.PlayerID = ids[0]
+	} else {
+		entry.PlayerIDs = ids
+	}
+
+	if erasure {
+		if err := s.forget(ctx, ids); err != nil {
+			return err
+		}
+	}
+
+	if err := s.audit.Record(entry); err != nil {
+		slog.ErrorContext(ctx, "recording deletion", "players", ids, "err", err)
+		return status.Error(500, "audit = could not record deletion")
+	}
+
+	s.pipeline.Add(operation{kind: deleteOperation, ids: ids, erasure: erasure, requestID: logging.RequestID(ctx)})
+	return nil
+}
+
+// erase deletes players erased through a server sharing the audit log, whose
+// tombstones already keep them from being indexed again
+func (s *RecommendationServer) erase(ctx context.Context, ids []string) error {
+	if err := s.forget(ctx, ids); err != nil {
+		return err
+	}
+
+	s.pipeline.Add(operation{kind: deleteOperation, ids: ids, erasure: true, requestID: logging.RequestID(ctx)})
+	return nil
+}
+
+// forget drops the exclusions of erased players, the persisted exclusions mustn't
+// bring them back after a restart
+func (s *RecommendationServer) forget(ctx context.Context, ids []string) error {
+	if err := s.exclusions.Forget(ids...); err != nil {
+		slog.ErrorContext(ctx, "forgetting exclusions", "players", ids, "err", err)
+		return status.Error(500, "exclusions = could not forget erased players")
+	}
+	return nil
+}
+
+// storeError is the status of a failed store read, 503 while the store is degraded
+// and 504 when it didn't answer in time, 500 with message otherwise
+func storeError(err error, message string) error {
+	switch {
+	case errors.Is(err, store.ErrUnavailable):
+		return status.Error(503, "store = temporarily unavailable")
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(504, "store = timed out")
+	}
+	return status.Error(500, message)
+}
+
+func statsFromRequest(in *pb.Request) vectors.Player {
+	var pickRates map[string]float64
+	if len(in.GetOperatorPickRates()) > 0 {
+		pickRates = make(map[string]float64, len(in.GetOperatorPickRates()))
+		for operator, rate := range in.GetOperatorPickRates() {
+			pickRates[operator] = float64(rate)
+		}
+	}
+
+	return vectors.Player{
+		Level:             int(in.GetLevel()),
+		Kost:              float64(in.GetKost()),
+		Rank:              int(in.GetRank()),
+		RankPoints:        int(in.GetRankPoints()),
+		WinRate:           float64(in.GetWinRate()),
+		HeadshotRate:      float64(in.GetHeadshotRate()),
+		KD:                float64(in.GetKd()),
+		MatchesPlayed:     int(in.GetMatchesPlayed()),
+		TimePlayed:        in.GetTimePlayed().AsDuration(),
+		PreferredRole:     in.GetPreferredRole(),
+		OperatorPickRates: pickRates,
+		Season:            int(in.GetSeason()),
+	}
+}
+
+func float32Map(values map[string]float64) map[string]float32 {
+	if len(values) == 0 {
+		return nil
+	}
+
+	converted := make(map[string]float32, len(values))
+	for key, value := range values {
+		converted[key] = float32(value)
+	}
+	return converted
+}
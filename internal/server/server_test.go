@@ -1,24 +1,35 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"net"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/eliassebastian/r6index-recommendation/internal/audit"
+	"github.com/eliassebastian/r6index-recommendation/internal/batch"
+	"github.com/eliassebastian/r6index-recommendation/internal/logging"
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
 	pb "github.com/eliassebastian/r6index-recommendation/pkg/proto/server"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/test/bufconn"
 )
 
-func dialer() func(context.Context, string) (net.Conn, error) {
+func dialer(recommendationServer pb.RecommendationServiceServer) func(context.Context, string) (net.Conn, error) {
 	listener := bufconn.Listen(1024 * 1024)
 
 	server := grpc.NewServer()
 
-	pb.RegisterRecommendationServiceServer(server, &RecommendationServer{})
+	pb.RegisterRecommendationServiceServer(server, recommendationServer)
 
 	go func() {
 		if err := server.Serve(listener); err != nil {
@@ -31,6 +42,22 @@ func dialer() func(context.Context, string) (net.Conn, error) {
 	}
 }
 
+// newTestServer returns a server backed by an in-memory store, every write is flushed immediately
+func newTestServer() (*RecommendationServer, *store.Memory) {
+	memory := store.NewMemory()
+	return NewRecommendationServer(memory, audit.New(io.Discard), 1, time.Minute), memory
+}
+
+func newTestClient(t *testing.T, recommendationServer pb.RecommendationServiceServer) pb.RecommendationServiceClient {
+	conn, err := grpc.DialContext(context.Background(), "", grpc.WithContextDialer(dialer(recommendationServer)), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewRecommendationServiceClient(conn)
+}
+
 func TestRecommendationServiceServer_Index(t *testing.T) {
 	// test cases
 	// TODO: add more test cases
@@ -47,7 +74,7 @@ func TestRecommendationServiceServer_Index(t *testing.T) {
 	}{
 		{
 			"typical player",
-			&pb.Request{Id: "6844b415-aa94-43c9-8823-9389e4816902", Level: 211, Kost: 0.76, Rank: 35, RankPoints: 3424},
+			&pb.Request{Id: "6844b415-aa94-43c9-8823-9389e4816902", Level: 211, Kost: 0.76, Rank: 35, RankPoints: 3424, Season: 30},
 			expectation{
 				&pb.Response{Code: 200, Message: "OK"},
 				nil,
@@ -55,7 +82,7 @@ func TestRecommendationServiceServer_Index(t *testing.T) {
 		},
 		{
 			"another typical player",
-			&pb.Request{Id: "460a3311-fe2f-489c-ba95-73370cbaddfa", Level: 448, Kost: 0.66, Rank: 35, RankPoints: 2344},
+			&pb.Request{Id: "460a3311-fe2f-489c-ba95-73370cbaddfa", Level: 448, Kost: 0.66, Rank: 35, RankPoints: 2344, Season: 30},
 			expectation{
 				&pb.Response{Code: 200, Message: "OK"},
 				nil,
@@ -69,11 +96,29 @@ func TestRecommendationServiceServer_Index(t *testing.T) {
 				errors.New("rpc error: code = Code(400) desc = id = empty player id"),
 			},
 		},
+		{
+			"player without a season",
+			&pb.Request{Id: "460a3311-fe2f-489c-ba95-73370cbaddfa", Level: 448, Kost: 0.66, Rank: 35, RankPoints: 2344},
+			expectation{
+				&pb.Response{},
+				errors.New("rpc error: code = InvalidArgument desc = season = required, seasons are numbered from 1"),
+			},
+		},
+		{
+			"player id that isn't a UUID",
+			&pb.Request{Id: "roamer", Level: 448, Kost: 0.66, Rank: 35, RankPoints: 2344},
+			expectation{
+				&pb.Response{},
+				errors.New("rpc error: code = InvalidArgument desc = id = not a UUID"),
+			},
+		},
 	}
 
 	ctx := context.Background()
 
-	conn, err := grpc.DialContext(ctx, "", grpc.WithContextDialer(dialer()), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	recommendationServer, _ := newTestServer()
+
+	conn, err := grpc.DialContext(ctx, "", grpc.WithContextDialer(dialer(recommendationServer)), grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -99,3 +144,256 @@ func TestRecommendationServiceServer_Index(t *testing.T) {
 		})
 	}
 }
+
+func TestRecommendationServiceServer_Delete(t *testing.T) {
+	ctx := context.Background()
+	recommendationServer, memory := newTestServer()
+	client := newTestClient(t, recommendationServer)
+
+	players := []*pb.Request{
+		{Id: "6844b415-aa94-43c9-8823-9389e4816902", Level: 211, Kost: 0.76, Rank: 35, RankPoints: 3424, Season: 30},
+		{Id: "460a3311-fe2f-489c-ba95-73370cbaddfa", Level: 448, Kost: 0.66, Rank: 35, RankPoints: 2344, Season: 30},
+		{Id: "6844b415-aa94-43c9-8823-9389e4816918", Level: 300, Kost: 0.55, Rank: 18, RankPoints: 1250, Season: 30},
+	}
+
+	for _, player := range players {
+		if _, err := client.Index(ctx, player); err != nil {
+			t.Fatalf("Index() error = %v, want nil", err)
+		}
+	}
+
+	if _, err := client.Delete(ctx, &pb.DeleteRequest{Id: players[0].Id}); err != nil {
+		t.Fatalf("Delete() error = %v, want nil", err)
+	}
+
+	if memory.Len() != 2 {
+		t.Errorf("stored players = %v, want %v", memory.Len(), 2)
+	}
+
+	// a plain delete can be undone by indexing the player again
+	if _, err := client.Index(ctx, players[0]); err != nil {
+		t.Errorf("Index() after Delete() error = %v, want nil", err)
+	}
+
+	_, err := client.BulkDelete(ctx, &pb.BulkDeleteRequest{Ids: []string{players[1].Id, players[2].Id}, Erasure: true, Reason: "gdpr"})
+	if err != nil {
+		t.Fatalf("BulkDelete() error = %v, want nil", err)
+	}
+
+	if memory.Len() != 1 {
+		t.Errorf("stored players = %v, want %v", memory.Len(), 1)
+	}
+
+	// an erased player must not be re-created by a stale Index call
+	_, err = client.Index(ctx, players[1])
+	want := "rpc error: code = Code(410) desc = id = player has been erased"
+	if err == nil || err.Error() != want {
+		t.Errorf("Index() after erasure err = %v, want %q", err, want)
+	}
+
+	if memory.Len() != 1 {
+		t.Errorf("stored players = %v, want %v", memory.Len(), 1)
+	}
+
+	tests := []struct {
+		testName string
+		call     func() error
+		want     string
+	}{
+		{
+			"empty player id",
+			func() error { _, err := client.Delete(ctx, &pb.DeleteRequest{}); return err },
+			"rpc error: code = Code(400) desc = id = empty player id",
+		},
+		{
+			"no player ids",
+			func() error { _, err := client.BulkDelete(ctx, &pb.BulkDeleteRequest{}); return err },
+			"rpc error: code = Code(400) desc = ids = no player ids",
+		},
+		{
+			"empty id in bulk",
+			func() error {
+				_, err := client.BulkDelete(ctx, &pb.BulkDeleteRequest{Ids: []string{players[0].Id, ""}})
+				return err
+			},
+			"rpc error: code = Code(400) desc = ids = empty player id",
+		},
+		{
+			"player id that isn't a UUID",
+			func() error { _, err := client.Delete(ctx, &pb.DeleteRequest{Id: "roamer"}); return err },
+			"rpc error: code = InvalidArgument desc = id = not a UUID",
+		},
+		{
+			"id in bulk that isn't a UUID",
+			func() error {
+				_, err := client.BulkDelete(ctx, &pb.BulkDeleteRequest{Ids: []string{players[0].Id, "roamer"}})
+				return err
+			},
+			`rpc error: code = InvalidArgument desc = ids = "roamer" is not a UUID`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			err := tt.call()
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("err -> \nWant: %q\nGot: %v\n", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestRecommendationServiceServer_BulkDeleteAudit(t *testing.T) {
+	ctx := context.Background()
+	var buffer bytes.Buffer
+	memory := store.NewMemory()
+	client := newTestClient(t, NewRecommendationServer(memory, audit.New(&buffer), 1, time.Minute))
+
+	ids := []string{"6844b415-aa94-43c9-8823-9389e4816902", "460a3311-fe2f-489c-ba95-73370cbaddfa"}
+	for _, id := range ids {
+		if _, err := client.Index(ctx, &pb.Request{Id: id, Level: 211, Kost: 0.76, Rank: 35, RankPoints: 3424, Season: 30}); err != nil {
+			t.Fatalf("Index() error = %v, want nil", err)
+		}
+	}
+
+	if _, err := client.BulkDelete(ctx, &pb.BulkDeleteRequest{Ids: ids, Reason: "cleanup"}); err != nil {
+		t.Fatalf("BulkDelete() error = %v, want nil", err)
+	}
+
+	// a bulk request is a single entry of every player
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], ids[0]) || !strings.Contains(lines[0], ids[1]) {
+		t.Errorf("audit lines = %q, want a single entry of both players", lines)
+	}
+
+	if memory.Len() != 0 {
+		t.Errorf("stored players = %v, want %v", memory.Len(), 0)
+	}
+}
+
+func TestRecommendationServiceServer_GetPlayer(t *testing.T) {
+	ctx := context.Background()
+	recommendationServer, _ := newTestServer()
+	client := newTestClient(t, recommendationServer)
+
+	player := &pb.Request{Id: "6844b415-aa94-43c9-8823-9389e4816902", Level: 211, Kost: 0.76, Rank: 35, RankPoints: 3424, Season: 30}
+	if _, err := client.Index(ctx, player); err != nil {
+		t.Fatalf("Index() error = %v, want nil", err)
+	}
+
+	response, err := client.GetPlayer(ctx, &pb.GetPlayerRequest{Id: player.Id})
+	if err != nil {
+		t.Fatalf("GetPlayer() error = %v, want nil", err)
+	}
+
+	got := response.GetPlayer()
+	if got.GetLevel() != player.Level || got.GetKost() != player.Kost || got.GetRank() != player.Rank || got.GetRankPoints() != player.RankPoints {
+		t.Errorf("GetPlayer() stats = %v, want %v", got, player)
+	}
+
+	if len(got.GetVector()) == 0 || got.GetSchemaVersion() == 0 || got.GetUpdatedAt().AsTime().IsZero() {
+		t.Errorf("GetPlayer() = %v, want vector, schema version and update time", got)
+	}
+
+	_, err = client.GetPlayer(ctx, &pb.GetPlayerRequest{Id: "460a3311-fe2f-489c-ba95-73370cbaddfa"})
+	want := "rpc error: code = Code(404) desc = id = player not found"
+	if err == nil || err.Error() != want {
+		t.Errorf("GetPlayer() unknown player err = %v, want %q", err, want)
+	}
+}
+
+// failingStore fails every write
+type failingStore struct {
+	*store.Memory
+}
+
+func (failingStore) Upsert(context.Context, []*store.Player) error {
+	return errors.New("weaviate: batch rejected")
+}
+
+func TestRecommendationServiceServer_FlushLogsRequestIDs(t *testing.T) {
+	var buffer bytes.Buffer
+	logger, err := logging.New(&buffer, "info", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	recommendationServer := NewRecommendationServer(failingStore{store.NewMemory()}, audit.New(io.Discard), 1, time.Minute)
+
+	ctx := logging.WithRequestID(context.Background(), "index-request-1")
+	if _, err := recommendationServer.Index(ctx, &pb.Request{Id: "6844b415-aa94-43c9-8823-9389e4816902", Level: 211, Season: 30}); err != nil {
+		t.Fatalf("Index() error = %v, want nil", err)
+	}
+
+	// the failed batch is traced back to the Index call that queued it
+	if !strings.Contains(buffer.String(), `"request_ids":["index-request-1"]`) {
+		t.Errorf("log = %s, want the request ID of the failed batch", buffer.String())
+	}
+}
+
+// flakyStore refuses to store rejected and can't delete unavailable for now
+type flakyStore struct {
+	*store.Memory
+	rejected, unavailable string
+}
+
+func (f *flakyStore) Upsert(ctx context.Context, players []*store.Player) error {
+	for _, player := range players {
+		if player.ID == f.rejected {
+			return fmt.Errorf("weaviate: upsert %s: invalid id: %w", player.ID, store.ErrRejected)
+		}
+	}
+	return f.Memory.Upsert(ctx, players)
+}
+
+func (f *flakyStore) Delete(ctx context.Context, ids []string) error {
+	for _, id := range ids {
+		if id == f.unavailable {
+			return errors.New("weaviate: 503 service unavailable")
+		}
+	}
+	return f.Memory.Delete(ctx, ids)
+}
+
+func TestRecommendationServiceServer_FlushSkipsFailedWrites(t *testing.T) {
+	ctx := context.Background()
+	ids := []string{"6844b415-aa94-43c9-8823-938900000021", "6844b415-aa94-43c9-8823-938900000022", "6844b415-aa94-43c9-8823-938900000023", "6844b415-aa94-43c9-8823-938900000024", "6844b415-aa94-43c9-8823-938900000025"}
+	indexed, rejected, unavailable, erased, later := ids[0], ids[1], ids[2], ids[3], ids[4]
+
+	flaky := &flakyStore{Memory: store.NewMemory(), rejected: rejected, unavailable: unavailable}
+	flaky.Memory.Upsert(ctx, []*store.Player{{ID: unavailable}, {ID: erased}})
+	recommendationServer := NewRecommendationServer(flaky, audit.New(io.Discard), 100, time.Hour)
+
+	upsert := func(id string) operation {
+		return operation{kind: upsertOperation, player: &store.Player{ID: id}}
+	}
+	deleteOf := operation{kind: deleteOperation, ids: []string{unavailable}}
+	reindexed := upsert(unavailable)
+	items := []interface{}{upsert(indexed), upsert(rejected), deleteOf, reindexed, operation{kind: deleteOperation, ids: []string{erased}, erasure: true}, upsert(later)}
+
+	// the failed delete holds back the later write of its player but no other
+	var retry *batch.RetryError
+	if err := recommendationServer.flush(items); !errors.As(err, &retry) {
+		t.Fatalf("flush() error = %v, want a RetryError", err)
+	}
+
+	if !reflect.DeepEqual(retry.Items, []interface{}{deleteOf, reindexed}) {
+		t.Errorf("flush() retries %v, want the failed delete and the write after it", retry.Items)
+	}
+
+	for _, id := range []string{indexed, later} {
+		if _, err := flaky.Get(ctx, id); err != nil {
+			t.Errorf("Get(%s) error = %v, want the player written", id, err)
+		}
+	}
+
+	for _, id := range []string{rejected, erased} {
+		if _, err := flaky.Get(ctx, id); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Get(%s) error = %v, want store.ErrNotFound", id, err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/squad"
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+	pb "github.com/eliassebastian/r6index-recommendation/pkg/proto/server"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultSquadAlternatives = 3
+	maxSquadAlternatives     = 10
+	// nearest players around the seeds the squads are built from
+	squadPoolSize = 30
+)
+
+func (s *RecommendationServer) BuildSquad(ctx context.Context, in *pb.SquadRequest) (*pb.SquadResponse, error) {
+
+	if len(in.GetSeedIds()) == 0 || len(in.GetSeedIds()) >= squad.Size {
+		return &pb.SquadResponse{}, status.Errorf(400, "seed_ids = need 1 to %d players", squad.Size-1)
+	}
+
+	alternatives := int(in.GetAlternatives())
+	switch {
+	case alternatives == 0:
+		alternatives = defaultSquadAlternatives
+	case alternatives < 0 || alternatives > maxSquadAlternatives:
+		return &pb.SquadResponse{}, status.Errorf(400, "alternatives = must be between 1 and %d", maxSquadAlternatives)
+	}
+
+	filter, err := filterFromRequest(in.GetFilters())
+	if err != nil {
+		return &pb.SquadResponse{}, err
+	}
+
+	g := s.generation()
+
+	// players excluded for any of the seeds can't join the squad
+	seeds := make([]*store.Player, 0, len(in.GetSeedIds()))
+	excluded := make(map[string]struct{})
+	for _, id := range in.GetSeedIds() {
+		if id == "" {
+			return &pb.SquadResponse{}, status.Error(400, "seed_ids = empty player id")
+		}
+
+		if _, ok := excluded[id]; ok {
+			return &pb.SquadResponse{}, status.Errorf(400, "seed_ids = %s is duplicated or excluded by another seed", id)
+		}
+
+		player, err := g.store.Get(ctx, id)
+		if errors.Is(err, store.ErrNotFound) {
+			return &pb.SquadResponse{}, status.Errorf(404, "seed_ids = player %s not found", id)
+		}
+
+		if err != nil {
+			slog.ErrorContext(ctx, "getting squad seed", "generation", g.name, "player", id, "err", err)
+			return &pb.SquadResponse{}, storeError(err, "store = could not get player")
+		}
+
+		// seeds indexed under an older schema are compared by their re-vectorized stats
+		player.Vector = g.storedVector(player)
+		seeds = append(seeds, player)
+		for other := range s.exclusions.Excluded(id) {
+			excluded[other] = struct{}{}
+		}
+	}
+
+	// the squad is scored around the target and its candidates are the players nearest to it
+	target := squad.Centroid(seeds)
+	if in.GetTarget() != nil {
+		target = g.vectorize(statsFromRequest(in.GetTarget()))
+	}
+
+	hits, err := g.candidates(ctx, target, recommendQuery{filter: filter, candidates: squadPoolSize}, excluded)
+	if err != nil {
+		slog.ErrorContext(ctx, "querying squad candidates", "generation", g.name, "err", err)
+		return &pb.SquadResponse{}, storeError(err, "store = could not query nearest players")
+	}
+
+	pool := make([]*store.Player, 0, len(hits))
+	for _, hit := range hits {
+		pool = append(pool, hit.Player)
+	}
+
+	// candidates that blocked or recently played with each other aren't paired either
+	poolExclusions := make(map[string]map[string]struct{}, len(pool))
+	for _, player := range pool {
+		poolExclusions[player.ID] = s.exclusions.Excluded(player.ID)
+	}
+
+	conflict := func(a, b string) bool {
+		_, ok := poolExclusions[b][a]
+		return ok
+	}
+
+	squads := squad.Build(seeds, pool, alternatives, target, conflict)
+
+	response := &pb.SquadResponse{
+		Code:    200,
+		Message: "OK",
+		Squads:  make([]*pb.Squad, 0, len(squads)),
+	}
+
+	for _, built := range squads {
+		ids := make([]string, 0, len(built.Candidates))
+		for _, candidate := range built.Candidates {
+			ids = append(ids, candidate.ID)
+		}
+
+		response.Squads = append(response.Squads, &pb.Squad{CandidateIds: ids, Balance: built.Balance(), Fit: built.Fit()})
+	}
+
+	return response, nil
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/eliassebastian/r6index-recommendation/pkg/proto/server"
+)
+
+func TestRecommendationServiceServer_BuildSquad(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newIndexedTestClient(t)
+
+	seeds := []string{"6844b415-aa94-43c9-8823-9389e4816918", "6844b415-aa94-43c9-8823-9389e4816454"}
+
+	if _, err := client.Block(ctx, &pb.BlockRequest{Id: seeds[0], BlockedId: "6844b415-aa94-43c9-8823-9389e4816905"}); err != nil {
+		t.Fatalf("Block() error = %v, want nil", err)
+	}
+
+	response, err := client.BuildSquad(ctx, &pb.SquadRequest{SeedIds: seeds, Alternatives: 2})
+	if err != nil {
+		t.Fatalf("BuildSquad() error = %v, want nil", err)
+	}
+
+	squads := response.GetSquads()
+	if len(squads) != 2 {
+		t.Fatalf("BuildSquad() = %v squads, want %v", len(squads), 2)
+	}
+
+	for _, squad := range squads {
+		if squad.GetBalance() <= 0 || squad.GetBalance() > 100 || squad.GetFit() <= 0 || squad.GetFit() > 100 {
+			t.Errorf("BuildSquad() balance = %v, fit = %v, want both in (0, 100]", squad.GetBalance(), squad.GetFit())
+		}
+
+		if len(squad.GetCandidateIds()) != 3 {
+			t.Errorf("BuildSquad() squad = %v, want 3 candidates", squad.GetCandidateIds())
+		}
+
+		for _, id := range squad.GetCandidateIds() {
+			if id == seeds[0] || id == seeds[1] || id == "6844b415-aa94-43c9-8823-9389e4816905" {
+				t.Errorf("BuildSquad() picked seed or blocked player %v", id)
+			}
+		}
+	}
+
+	// a target profile pulls the squad towards the players ranked like it
+	champions := &pb.Request{Level: 230, Kost: 0.78, Rank: 35, RankPoints: 4200, Season: 30}
+	response, err = client.BuildSquad(ctx, &pb.SquadRequest{SeedIds: seeds, Alternatives: 1, Target: champions})
+	if err != nil || len(response.GetSquads()) != 1 {
+		t.Fatalf("BuildSquad() with a target = %v, %v, want a squad", response, err)
+	}
+
+	picked := make(map[string]bool)
+	for _, id := range response.GetSquads()[0].GetCandidateIds() {
+		picked[id] = true
+	}
+
+	if !picked["6844b415-aa94-43c9-8823-9389e4816910"] || !picked["6844b415-aa94-43c9-8823-9389e4816914"] {
+		t.Errorf("BuildSquad() with a target = %v, want both champions", response.GetSquads()[0].GetCandidateIds())
+	}
+
+	if containsID(squads[0].GetCandidateIds(), "6844b415-aa94-43c9-8823-9389e4816914") {
+		t.Errorf("BuildSquad() without a target = %v, want the squad around the seeds", squads[0].GetCandidateIds())
+	}
+
+	tests := []struct {
+		testName string
+		req      *pb.SquadRequest
+		want     string
+	}{
+		{"no seeds", &pb.SquadRequest{}, "rpc error: code = Code(400) desc = seed_ids = need 1 to 4 players"},
+		{"unknown seed", &pb.SquadRequest{SeedIds: []string{"460a3311-fe2f-489c-ba95-73370cbaddfa"}}, "rpc error: code = Code(404) desc = seed_ids = player 460a3311-fe2f-489c-ba95-73370cbaddfa not found"},
+		{"blocked seeds", &pb.SquadRequest{SeedIds: []string{seeds[0], "6844b415-aa94-43c9-8823-9389e4816905"}}, "rpc error: code = Code(400) desc = seed_ids = 6844b415-aa94-43c9-8823-9389e4816905 is duplicated or excluded by another seed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			_, err := client.BuildSquad(ctx, tt.req)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("err -> \nWant: %q\nGot: %v\n", tt.want, err)
+			}
+		})
+	}
+}
+
+func containsID(ids []string, id string) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/statistics"
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
+	pb "github.com/eliassebastian/r6index-recommendation/pkg/proto/server"
+)
+
+// features need this many observations before the refit moves them off the schema's parameters
+const minRefitObservations = 100
+
+func (s *RecommendationServer) Statistics(ctx context.Context, in *pb.StatisticsRequest) (*pb.StatisticsResponse, error) {
+	features := s.statistics.Features()
+	if in.GetResetEstimates() {
+		features = s.statistics.Take()
+	}
+
+	refit := statistics.Refit(features, vectors.Schemas[s.statistics.SchemaVersion()].Normalization, minRefitObservations)
+
+	response := &pb.StatisticsResponse{
+		Code:          200,
+		Message:       "OK",
+		SchemaVersion: int32(s.statistics.SchemaVersion()),
+		Features:      make([]*pb.FeatureStatistics, 0, len(features)),
+		RefitMean:     refit.Mean,
+		RefitStdDev:   refit.StdDev,
+	}
+
+	for _, feature := range features {
+		statistics := &pb.FeatureStatistics{
+			Feature: feature.Name,
+			Count:   feature.Moments.Count,
+			Mean:    feature.Moments.Mean,
+			StdDev:  feature.Moments.StdDev(),
+		}
+
+		for _, quantile := range feature.Quantiles {
+			statistics.Quantiles = append(statistics.Quantiles, &pb.Quantile{P: quantile.P, Value: quantile.Value()})
+		}
+
+		response.Features = append(response.Features, statistics)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
+	pb "github.com/eliassebastian/r6index-recommendation/pkg/proto/server"
+)
+
+func TestRecommendationServiceServer_Statistics(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newIndexedTestClient(t)
+
+	response, err := client.Statistics(ctx, &pb.StatisticsRequest{})
+	if err != nil {
+		t.Fatalf("Statistics() error = %v, want nil", err)
+	}
+
+	if response.GetSchemaVersion() != int32(vectors.Current.Version) || len(response.GetFeatures()) != vectors.Current.Dimension() {
+		t.Fatalf("Statistics() = schema v%d with %d features, want v%d with %d", response.GetSchemaVersion(), len(response.GetFeatures()), vectors.Current.Version, vectors.Current.Dimension())
+	}
+
+	// the levels of testPlayers average 251.875
+	level := response.GetFeatures()[0]
+	if level.GetFeature() != "level" || level.GetCount() != int64(len(testPlayers)) || level.GetMean() != 251.875 {
+		t.Errorf("Statistics() level = %v, want %d observations averaging 251.875", level, len(testPlayers))
+	}
+
+	quantiles := level.GetQuantiles()
+	if len(quantiles) != 5 {
+		t.Fatalf("Statistics() level quantiles = %v, want 5", quantiles)
+	}
+
+	for i, quantile := range quantiles {
+		if quantile.GetValue() < 110 || quantile.GetValue() > 300 || (i > 0 && quantile.GetValue() < quantiles[i-1].GetValue()) {
+			t.Errorf("Statistics() level quantiles = %v, want ordered values between 110 and 300", quantiles)
+			break
+		}
+	}
+
+	// too few players to move the normalization off the schema's parameters
+	if len(response.GetRefitMean()) != vectors.Current.Dimension() || response.GetRefitMean()[0] != vectors.Current.Normalization.Mean[0] {
+		t.Errorf("Statistics() refit mean = %v, want the schema's", response.GetRefitMean())
+	}
+
+	// re-indexing a player doesn't count it twice
+	if _, err := client.Index(ctx, testPlayers[0]); err != nil {
+		t.Fatalf("Index() error = %v, want nil", err)
+	}
+
+	// the reset returns the estimates it drops
+	response, err = client.Statistics(ctx, &pb.StatisticsRequest{ResetEstimates: true})
+	if err != nil || response.GetFeatures()[0].GetCount() != int64(len(testPlayers)) {
+		t.Fatalf("Statistics(reset) = %v, %v, want %d observations", response.GetFeatures()[0], err, len(testPlayers))
+	}
+
+	response, err = client.Statistics(ctx, &pb.StatisticsRequest{})
+	if err != nil || response.GetFeatures()[0].GetCount() != 0 {
+		t.Errorf("Statistics() after reset = %v, %v, want no observations", response.GetFeatures()[0], err)
+	}
+}
@@ -0,0 +1,414 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/auth"
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+	"github.com/eliassebastian/r6index-recommendation/internal/tenant"
+	pb "github.com/eliassebastian/r6index-recommendation/pkg/proto/server"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// how long a tenant's record count is trusted before the store is counted again
+const recordCountTTL = time.Minute
+
+// TenantRouter serves several tenants, each from its own RecommendationServer with its
+// own store, pipeline and statistics, so their player pools never mix. A request goes
+// to the tenant named by its tenant.MetadataKey metadata, or the default tenant
+type TenantRouter struct {
+	pb.UnimplementedRecommendationServiceServer
+	fallback string
+	tenants  map[string]*tenantServer
+	names    []string
+}
+
+// tenantServer is a tenant's server, its quota use and request metrics
+type tenantServer struct {
+	config tenant.Config
+	server *RecommendationServer
+
+	mutex   sync.Mutex
+	records int
+	counted time.Time
+	// pending are the records reserved while their writes wait in the pipeline, stored
+	// the seasons of players the tenant is known to store. Indexing either again takes
+	// no more of the quota
+	pending map[record]struct{}
+	stored  map[string]map[int]struct{}
+	methods map[string]*methodMetrics
+}
+
+// record is a stored player's season, the unit of a tenant's quota
+type record struct {
+	id     string
+	season int
+}
+
+type methodMetrics struct {
+	requests int64
+	errors   int64
+	latency  time.Duration
+}
+
+// NewTenantRouter opens a server for every configured tenant
+func NewTenantRouter(configs tenant.Configs, open func(tenant.Config) (*RecommendationServer, error)) (*TenantRouter, error) {
+	if err := configs.Validate(); err != nil {
+		return nil, err
+	}
+
+	r := &TenantRouter{fallback: configs.Default, tenants: make(map[string]*tenantServer, len(configs.Tenants))}
+	for _, config := range configs.Tenants {
+		server, err := open(config)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", config.Name, err)
+		}
+
+		t := &tenantServer{config: config, server: server, pending: map[record]struct{}{}, stored: map[string]map[int]struct{}{}, methods: map[string]*methodMetrics{}}
+		if config.MaxRecords > 0 {
+			server.flushed = t.flushed
+		}
+
+		r.tenants[config.Name] = t
+		r.names = append(r.names, config.Name)
+	}
+
+	sort.Strings(r.names)
+	return r, nil
+}
+
+// tenant returns the tenant a request is for
+func (r *TenantRouter) tenant(ctx context.Context) (*tenantServer, error) {
+	name := tenant.FromContext(ctx)
+	if name == "" {
+		name = r.fallback
+	}
+
+	if name == "" {
+		return nil, status.Error(400, fmt.Sprintf("%s = no tenant", tenant.MetadataKey))
+	}
+
+	if !auth.TenantAllowed(ctx, name) {
+		return nil, status.Error(codes.PermissionDenied, fmt.Sprintf("%s = client %s may not call tenant %q", tenant.MetadataKey, auth.ClientFromContext(ctx), name))
+	}
+
+	t, ok := r.tenants[name]
+	if !ok {
+		return nil, status.Error(404, fmt.Sprintf("%s = unknown tenant %q", tenant.MetadataKey, name))
+	}
+	return t, nil
+}
+
+// route calls method on the server of the request's tenant and records its metrics
+func route[Req, Res any](r *TenantRouter, ctx context.Context, name string, in Req, method func(*tenantServer, context.Context, Req) (Res, error)) (Res, error) {
+	t, err := r.tenant(ctx)
+	if err != nil {
+		var empty Res
+		return empty, err
+	}
+
+	start := time.Now()
+	res, err := method(t, ctx, in)
+	t.observe(name, time.Since(start), err)
+	return res, err
+}
+
+// on adapts a RecommendationServer method to route
+func on[Req, Res any](method func(*RecommendationServer, context.Context, Req) (Res, error)) func(*tenantServer, context.Context, Req) (Res, error) {
+	return func(t *tenantServer, ctx context.Context, in Req) (Res, error) {
+		return method(t.server, ctx, in)
+	}
+}
+
+func (r *TenantRouter) Index(ctx context.Context, in *pb.Request) (*pb.Response, error) {
+	return route(r, ctx, "Index", in, (*tenantServer).index)
+}
+
+func (r *TenantRouter) Delete(ctx context.Context, in *pb.DeleteRequest) (*pb.Response, error) {
+	res, err := route(r, ctx, "Delete", in, on((*RecommendationServer).Delete))
+	if err == nil && in.GetErasure() {
+		err = r.erase(ctx, []string{in.GetId()})
+	}
+	return res, err
+}
+
+func (r *TenantRouter) BulkDelete(ctx context.Context, in *pb.BulkDeleteRequest) (*pb.Response, error) {
+	res, err := route(r, ctx, "BulkDelete", in, on((*RecommendationServer).BulkDelete))
+	if err == nil && in.GetErasure() {
+		err = r.erase(ctx, in.GetIds())
+	}
+	return res, err
+}
+
+// erase deletes players erased through the request's tenant from every other tenant.
+// The erasure's tombstone is in the audit log they share, it keeps every tenant from
+// indexing the players again
+func (r *TenantRouter) erase(ctx context.Context, ids []string) error {
+	erasedBy, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range r.names {
+		if t := r.tenants[name]; t != erasedBy {
+			if err := t.server.erase(ctx, ids); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *TenantRouter) GetPlayer(ctx context.Context, in *pb.GetPlayerRequest) (*pb.GetPlayerResponse, error) {
+	return route(r, ctx, "GetPlayer", in, on((*RecommendationServer).GetPlayer))
+}
+
+func (r *TenantRouter) Recommend(ctx context.Context, in *pb.RecommendRequest) (*pb.RecommendResponse, error) {
+	return route(r, ctx, "Recommend", in, on((*RecommendationServer).Recommend))
+}
+
+func (r *TenantRouter) RecommendByStats(ctx context.Context, in *pb.RecommendByStatsRequest) (*pb.RecommendResponse, error) {
+	return route(r, ctx, "RecommendByStats", in, on((*RecommendationServer).RecommendByStats))
+}
+
+func (r *TenantRouter) Block(ctx context.Context, in *pb.BlockRequest) (*pb.Response, error) {
+	return route(r, ctx, "Block", in, on((*RecommendationServer).Block))
+}
+
+func (r *TenantRouter) Unblock(ctx context.Context, in *pb.BlockRequest) (*pb.Response, error) {
+	return route(r, ctx, "Unblock", in, on((*RecommendationServer).Unblock))
+}
+
+func (r *TenantRouter) RecordTeammates(ctx context.Context, in *pb.TeammatesRequest) (*pb.Response, error) {
+	return route(r, ctx, "RecordTeammates", in, on((*RecommendationServer).RecordTeammates))
+}
+
+func (r *TenantRouter) BuildSquad(ctx context.Context, in *pb.SquadRequest) (*pb.SquadResponse, error) {
+	return route(r, ctx, "BuildSquad", in, on((*RecommendationServer).BuildSquad))
+}
+
+func (r *TenantRouter) Statistics(ctx context.Context, in *pb.StatisticsRequest) (*pb.StatisticsResponse, error) {
+	return route(r, ctx, "Statistics", in, on((*RecommendationServer).Statistics))
+}
+
+func (r *TenantRouter) Reindex(ctx context.Context, in *pb.ReindexRequest) (*pb.ReindexResponse, error) {
+	return route(r, ctx, "Reindex", in, on((*RecommendationServer).Reindex))
+}
+
+func (r *TenantRouter) ReindexStatus(ctx context.Context, in *pb.ReindexStatusRequest) (*pb.ReindexResponse, error) {
+	return route(r, ctx, "ReindexStatus", in, on((*RecommendationServer).ReindexStatus))
+}
+
+func (r *TenantRouter) Rollback(ctx context.Context, in *pb.RollbackRequest) (*pb.ReindexResponse, error) {
+	return route(r, ctx, "Rollback", in, on((*RecommendationServer).Rollback))
+}
+
+// Tenants reports on every tenant, whichever tenant the request names
+func (r *TenantRouter) Tenants(ctx context.Context, in *pb.TenantsRequest) (*pb.TenantsResponse, error) {
+	tenants := make([]*pb.TenantStatus, 0, len(r.names))
+	for _, name := range r.names {
+		tenants = append(tenants, r.tenants[name].status())
+	}
+
+	return &pb.TenantsResponse{
+		Code:    200,
+		Message: "OK",
+		Tenants: tenants,
+	}, nil
+}
+
+// index indexes the player's season unless it is a new record and the tenant's record
+// quota is used up. Records already stored can still be updated once it is
+func (t *tenantServer) index(ctx context.Context, in *pb.Request) (*pb.Response, error) {
+	if t.config.MaxRecords == 0 || in.GetId() == "" {
+		return t.server.Index(ctx, in)
+	}
+
+	key := record{id: in.GetId(), season: int(in.GetSeason())}
+	reserved, err := t.reserve(ctx, key)
+	if err != nil {
+		return &pb.Response{}, err
+	}
+
+	res, err := t.server.Index(ctx, in)
+	if err != nil && reserved {
+		t.release(key)
+	}
+	return res, err
+}
+
+// reserve takes a record of the quota for key unless the tenant is known to store it
+// already, and reports whether it took one. A record stored before the tenant saw it
+// takes one too while there are some left, until the store is counted again
+func (t *tenantServer) reserve(ctx context.Context, key record) (bool, error) {
+	if err := t.count(ctx); err != nil {
+		slog.ErrorContext(ctx, "tenant: counting records", "tenant", t.config.Name, "err", err)
+		return false, status.Error(codes.Internal, "store = could not count records")
+	}
+
+	if reserved, ok := t.tryReserve(key); ok {
+		return reserved, nil
+	}
+
+	// the quota is used up, only a record stored already can be indexed
+	history, err := t.server.generation().store.History(ctx, key.id)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		slog.ErrorContext(ctx, "tenant: looking up player", "tenant", t.config.Name, "id", key.id, "err", err)
+		return false, status.Error(codes.Internal, "store = could not look up player")
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for _, player := range history {
+		t.store(record{id: player.ID, season: player.Stats.Season})
+	}
+
+	if _, ok := t.stored[key.id][key.season]; !ok {
+		return false, status.Error(codes.ResourceExhausted, fmt.Sprintf("tenant = record quota of %d reached", t.config.MaxRecords))
+	}
+	return false, nil
+}
+
+// tryReserve reserves a record for key like reserve without asking the store, false
+// when key is unknown and the quota is used up
+func (t *tenantServer) tryReserve(key record) (reserved bool, ok bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if _, ok := t.pending[key]; ok {
+		return false, true
+	}
+
+	if _, ok := t.stored[key.id][key.season]; ok {
+		return false, true
+	}
+
+	if t.records >= t.config.MaxRecords {
+		return false, false
+	}
+
+	t.records++
+	t.pending[key] = struct{}{}
+	return true, true
+}
+
+// release gives back the record reserved for key
+func (t *tenantServer) release(key record) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if _, ok := t.pending[key]; ok {
+		delete(t.pending, key)
+		t.records--
+	}
+}
+
+// flushed follows the tenant's writes leaving the pipeline. Written reservations are
+// stored records, dropped ones are given back and deleted players give back every
+// record the tenant knows of
+func (t *tenantServer) flushed(ops []operation, written bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for _, op := range ops {
+		if op.kind == deleteOperation {
+			if written {
+				for _, id := range op.ids {
+					t.records -= len(t.stored[id])
+					delete(t.stored, id)
+				}
+			}
+			continue
+		}
+
+		key := record{id: op.player.ID, season: op.player.Stats.Season}
+		_, reserved := t.pending[key]
+		delete(t.pending, key)
+
+		switch {
+		case written:
+			t.store(key)
+		case reserved:
+			t.records--
+		}
+	}
+}
+
+// store records that the tenant stores key, the caller holds the mutex
+func (t *tenantServer) store(key record) {
+	if t.stored[key.id] == nil {
+		t.stored[key.id] = map[int]struct{}{}
+	}
+	t.stored[key.id][key.season] = struct{}{}
+}
+
+// count counts the records the tenant stores again once the count is older than
+// recordCountTTL. Records reserved but still waiting in the pipeline aren't stored
+// yet and are added. The mutex is held throughout so no reservation is settled while
+// counting, one written just before may be counted twice until the next count
+func (t *tenantServer) count(ctx context.Context) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if time.Since(t.counted) < recordCountTTL {
+		return nil
+	}
+
+	records, err := t.server.generation().store.Count(ctx)
+	if err != nil {
+		return err
+	}
+
+	t.records, t.counted = records+len(t.pending), time.Now()
+	return nil
+}
+
+func (t *tenantServer) observe(method string, latency time.Duration, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	metrics, ok := t.methods[method]
+	if !ok {
+		metrics = &methodMetrics{}
+		t.methods[method] = metrics
+	}
+
+	metrics.requests++
+	metrics.latency += latency
+	if err != nil {
+		metrics.errors++
+	}
+}
+
+func (t *tenantServer) status() *pb.TenantStatus {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	methods := make([]*pb.MethodMetrics, 0, len(t.methods))
+	for method, metrics := range t.methods {
+		methods = append(methods, &pb.MethodMetrics{
+			Method:      method,
+			Requests:    metrics.requests,
+			Errors:      metrics.errors,
+			MeanLatency: durationpb.New(metrics.latency / time.Duration(metrics.requests)),
+		})
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i].Method < methods[j].Method })
+
+	return &pb.TenantStatus{
+		Name:       t.config.Name,
+		Collection: t.config.Collection,
+		MaxRecords: int64(t.config.MaxRecords),
+		Records:    int64(t.records),
+		Methods:    methods,
+	}
+}
@@ -0,0 +1,304 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/audit"
+	"github.com/eliassebastian/r6index-recommendation/internal/auth"
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+	"github.com/eliassebastian/r6index-recommendation/internal/tenant"
+	pb "github.com/eliassebastian/r6index-recommendation/pkg/proto/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// newTenantTestClient returns a client of a router serving ranked, the default tenant,
+// and standard, which can store three records, each from its own in-memory store. They
+// share an audit log
+func newTenantTestClient(t *testing.T) (pb.RecommendationServiceClient, map[string]*store.Memory) {
+	memories := map[string]*store.Memory{}
+	auditLog := audit.New(io.Discard)
+	router, err := NewTenantRouter(tenant.Configs{
+		Default: "ranked",
+		Tenants: []tenant.Config{
+			{Name: "ranked", Collection: "Ranked"},
+			{Name: "standard", Collection: "Standard", MaxRecords: 3},
+		},
+	}, func(config tenant.Config) (*RecommendationServer, error) {
+		memories[config.Name] = store.NewMemory()
+		return NewRecommendationServer(memories[config.Name], auditLog, 1, time.Minute), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newTestClient(t, router), memories
+}
+
+func withTenant(name string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), tenant.MetadataKey, name)
+}
+
+func TestTenantRouter_Isolation(t *testing.T) {
+	client, memories := newTenantTestClient(t)
+
+	for _, player := range testPlayers[:2] {
+		if _, err := client.Index(withTenant("standard"), player); err != nil {
+			t.Fatalf("Index() error = %v, want nil", err)
+		}
+	}
+
+	// requests without a tenant go to ranked
+	for _, player := range testPlayers[2:] {
+		if _, err := client.Index(context.Background(), player); err != nil {
+			t.Fatalf("Index() error = %v, want nil", err)
+		}
+	}
+
+	if got := memories["standard"].Len(); got != 2 {
+		t.Errorf("standard records = %d, want 2", got)
+	}
+
+	if got := memories["ranked"].Len(); got != len(testPlayers)-2 {
+		t.Errorf("ranked records = %d, want %d", got, len(testPlayers)-2)
+	}
+
+	if _, err := client.GetPlayer(withTenant("ranked"), &pb.GetPlayerRequest{Id: testPlayers[0].GetId()}); status.Code(err) != codes.Code(404) {
+		t.Errorf("GetPlayer() of another tenant's player error = %v, want code 404", err)
+	}
+
+	response, err := client.Recommend(withTenant("standard"), &pb.RecommendRequest{Id: testPlayers[0].GetId(), Limit: 10})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v, want nil", err)
+	}
+
+	if got := recommendationIDs(response); len(got) != 1 || got[0] != testPlayers[1].GetId() {
+		t.Errorf("Recommend() = %v, want only %s", got, testPlayers[1].GetId())
+	}
+
+	if _, err := client.GetPlayer(withTenant("casual"), &pb.GetPlayerRequest{Id: testPlayers[0].GetId()}); status.Code(err) != codes.Code(404) {
+		t.Errorf("GetPlayer() of an unknown tenant error = %v, want code 404", err)
+	}
+}
+
+func TestTenantRouter_Quota(t *testing.T) {
+	client, _ := newTenantTestClient(t)
+	ctx := withTenant("standard")
+
+	for _, player := range testPlayers[:3] {
+		if _, err := client.Index(ctx, player); err != nil {
+			t.Fatalf("Index() error = %v, want nil", err)
+		}
+	}
+
+	if _, err := client.Index(ctx, testPlayers[3]); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Index() over quota error = %v, want %v", err, codes.ResourceExhausted)
+	}
+
+	// stored players can still be updated
+	if _, err := client.Index(ctx, testPlayers[0]); err != nil {
+		t.Errorf("Index() of a stored player over quota error = %v, want nil", err)
+	}
+
+	// the quota is the standard tenant's alone
+	if _, err := client.Index(withTenant("ranked"), testPlayers[3]); err != nil {
+		t.Errorf("Index() of another tenant error = %v, want nil", err)
+	}
+
+	response, err := client.Tenants(ctx, &pb.TenantsRequest{})
+	if err != nil {
+		t.Fatalf("Tenants() error = %v, want nil", err)
+	}
+
+	tenants := response.GetTenants()
+	if len(tenants) != 2 || tenants[0].GetName() != "ranked" || tenants[1].GetName() != "standard" {
+		t.Fatalf("Tenants() = %v, want ranked and standard", tenants)
+	}
+
+	standard := tenants[1]
+	if standard.GetMaxRecords() != 3 || standard.GetCollection() != "Standard" {
+		t.Errorf("standard = %v, want collection Standard with 3 max records", standard)
+	}
+
+	methods := standard.GetMethods()
+	if len(methods) != 1 || methods[0].GetMethod() != "Index" || methods[0].GetRequests() != 5 || methods[0].GetErrors() != 1 {
+		t.Errorf("standard methods = %v, want 5 Index requests with 1 error", methods)
+	}
+}
+
+func TestTenantRouter_QuotaCountsInserts(t *testing.T) {
+	client, _ := newTenantTestClient(t)
+	ctx := withTenant("standard")
+
+	// updating a player takes no more of the quota than indexing it did
+	for _, player := range []*pb.Request{testPlayers[0], testPlayers[0], testPlayers[1], testPlayers[2]} {
+		if _, err := client.Index(ctx, player); err != nil {
+			t.Fatalf("Index(%s) error = %v, want nil", player.GetId(), err)
+		}
+	}
+
+	if _, err := client.Index(ctx, testPlayers[3]); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Index() over quota error = %v, want %v", err, codes.ResourceExhausted)
+	}
+}
+
+func TestTenantRouter_QuotaConcurrent(t *testing.T) {
+	client, _ := newTenantTestClient(t)
+	ctx := withTenant("standard")
+
+	var wg sync.WaitGroup
+	var indexed atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			player := &pb.Request{Id: fmt.Sprintf("6844b415-aa94-43c9-8823-93890000%04d", i), Level: 100, Kost: 0.5, Rank: 20, Season: 30}
+			if _, err := client.Index(ctx, player); err == nil {
+				indexed.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := indexed.Load(); got != 3 {
+		t.Errorf("concurrent Index() indexed %d new players, want the quota of 3", got)
+	}
+}
+
+func TestTenantRouter_TenantAccess(t *testing.T) {
+	router, err := NewTenantRouter(tenant.Configs{
+		Default: "ranked",
+		Tenants: []tenant.Config{{Name: "ranked", Collection: "Ranked"}, {Name: "league", Collection: "League"}},
+	}, func(config tenant.Config) (*RecommendationServer, error) {
+		return NewRecommendationServer(store.NewMemory(), audit.New(io.Discard), 1, time.Minute), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator, err := auth.New(auth.Config{Clients: []auth.Client{
+		{Name: "league", KeyHash: auth.HashKey("league-key"), Permissions: []auth.Permission{auth.Read}, Tenants: []string{"league"}},
+	}}, Permissions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	getPlayer := func(name string) error {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(auth.APIKeyMetadata, "league-key", tenant.MetadataKey, name))
+		info := &grpc.UnaryServerInfo{FullMethod: "/RecommendationService/GetPlayer"}
+		_, err := authenticator.UnaryServerInterceptor(ctx, &pb.GetPlayerRequest{Id: testPlayers[0].GetId()}, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return router.GetPlayer(ctx, req.(*pb.GetPlayerRequest))
+		})
+		return err
+	}
+
+	if err := getPlayer("league"); status.Code(err) != codes.Code(404) {
+		t.Errorf("GetPlayer() of the client's tenant error = %v, want code 404", err)
+	}
+
+	if err := getPlayer("ranked"); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("GetPlayer() of another tenant error = %v, want %v", err, codes.PermissionDenied)
+	}
+}
+
+func TestTenantRouter_ErasureEveryTenant(t *testing.T) {
+	client, memories := newTenantTestClient(t)
+	player := testPlayers[0]
+
+	for _, name := range []string{"ranked", "standard"} {
+		if _, err := client.Index(withTenant(name), player); err != nil {
+			t.Fatalf("Index() of %s error = %v, want nil", name, err)
+		}
+	}
+
+	if _, err := client.Delete(withTenant("standard"), &pb.DeleteRequest{Id: player.GetId(), Erasure: true}); err != nil {
+		t.Fatalf("Delete() error = %v, want nil", err)
+	}
+
+	// an erasure applies to every tenant, not just the one it was requested of
+	for name, memory := range memories {
+		if _, err := memory.Get(context.Background(), player.GetId()); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("%s Get() error = %v, want the erased player deleted", name, err)
+		}
+	}
+
+	if _, err := client.Index(withTenant("ranked"), player); status.Code(err) != codes.Code(410) {
+		t.Errorf("Index() of the erased player error = %v, want code 410", err)
+	}
+}
+
+func TestTenantRouter_QuotaCountsSeasons(t *testing.T) {
+	client, _ := newTenantTestClient(t)
+	ctx := withTenant("standard")
+
+	season := func(player *pb.Request, season int32) *pb.Request {
+		seasoned := proto.Clone(player).(*pb.Request)
+		seasoned.Season = season
+		return seasoned
+	}
+
+	// every season is a record of the quota
+	for _, player := range []*pb.Request{testPlayers[0], season(testPlayers[0], 31), testPlayers[1]} {
+		if _, err := client.Index(ctx, player); err != nil {
+			t.Fatalf("Index(%s, %d) error = %v, want nil", player.GetId(), player.GetSeason(), err)
+		}
+	}
+
+	if _, err := client.Index(ctx, season(testPlayers[1], 31)); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Index() of a new season over quota error = %v, want %v", err, codes.ResourceExhausted)
+	}
+
+	// deleting a player gives back each of its records
+	if _, err := client.Delete(ctx, &pb.DeleteRequest{Id: testPlayers[0].GetId()}); err != nil {
+		t.Fatalf("Delete() error = %v, want nil", err)
+	}
+
+	for _, player := range []*pb.Request{testPlayers[2], testPlayers[3]} {
+		if _, err := client.Index(ctx, player); err != nil {
+			t.Errorf("Index() after Delete() error = %v, want nil", err)
+		}
+	}
+
+	if _, err := client.Index(ctx, testPlayers[4]); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Index() over quota error = %v, want %v", err, codes.ResourceExhausted)
+	}
+}
+
+func TestTenantRouter_QuotaRecountKeepsReservations(t *testing.T) {
+	router, err := NewTenantRouter(tenant.Configs{
+		Default: "standard",
+		Tenants: []tenant.Config{{Name: "standard", Collection: "Standard", MaxRecords: 2}},
+	}, func(config tenant.Config) (*RecommendationServer, error) {
+		// writes wait in the pipeline until the test ends
+		return NewRecommendationServer(store.NewMemory(), audit.New(io.Discard), 100, time.Hour), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, player := range testPlayers[:2] {
+		if _, err := router.Index(context.Background(), player); err != nil {
+			t.Fatalf("Index() error = %v, want nil", err)
+		}
+	}
+
+	// the store doesn't hold the waiting writes yet, counting it doesn't free their records
+	standard := router.tenants["standard"]
+	standard.mutex.Lock()
+	standard.counted = time.Time{}
+	standard.mutex.Unlock()
+
+	if _, err := router.Index(context.Background(), testPlayers[2]); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Index() after a recount error = %v, want %v", err, codes.ResourceExhausted)
+	}
+}
@@ -0,0 +1,179 @@
+package squad
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+)
+
+// Size is the number of players in a full squad
+const Size = 5
+
+// Squad is a full five-stack, the seeds plus the candidates picked to fill it
+type Squad struct {
+	Candidates []*store.Player
+	// Spread is the mean squared distance of the members to the squad centroid,
+	// lower means a more even squad
+	Spread float32
+	// Drift is the squared distance of the squad centroid to the target, lower
+	// means the squad averages out closer to it
+	Drift float32
+}
+
+// Balance maps the spread onto (0, 100], higher is better balanced
+func (s Squad) Balance() float32 {
+	return 100 / (1 + s.Spread)
+}
+
+// Fit maps the drift onto (0, 100], higher is closer to the target
+func (s Squad) Fit() float32 {
+	return 100 / (1 + s.Drift)
+}
+
+// Score is the mean squared distance of the members to the target, which is the
+// spread plus the drift. Squads are ranked by it, lower is better
+func (s Squad) Score() float32 {
+	return s.Spread + s.Drift
+}
+
+// Conflict reports whether two players must not be put in the same squad
+type Conflict func(a, b string) bool
+
+// Build fills the seeds up to a squad of five from the candidate pool and returns up
+// to alternatives distinct squads, the best Score around target first. A nil target
+// is the centroid of the seeds. It runs a beam search that adds one player at a time
+// and keeps the alternatives*2 best scored partial squads
+func Build(seeds []*store.Player, pool []*store.Player, alternatives int, target []float32, conflict Conflict) []Squad {
+	type partial struct {
+		members []*store.Player
+		added   []*store.Player
+		squad   Squad
+	}
+
+	if target == nil {
+		target = Centroid(seeds)
+	}
+
+	width := alternatives * 2
+	beam := []partial{{members: seeds}}
+
+	for len(beam[0].members) < Size {
+		next := make([]partial, 0, len(beam)*len(pool))
+		seen := make(map[string]struct{})
+
+		for _, squad := range beam {
+			for _, candidate := range pool {
+				if !fits(squad.members, candidate, conflict) {
+					continue
+				}
+
+				members := append(append([]*store.Player(nil), squad.members...), candidate)
+				added := append(append([]*store.Player(nil), squad.added...), candidate)
+
+				// the same players picked in another order are the same squad
+				key := squadKey(added)
+				if _, ok := seen[key]; ok {
+					continue
+				}
+				seen[key] = struct{}{}
+
+				spread, drift := measure(members, target)
+				next = append(next, partial{members: members, added: added, squad: Squad{Candidates: added, Spread: spread, Drift: drift}})
+			}
+		}
+
+		if len(next) == 0 {
+			return nil
+		}
+
+		sort.SliceStable(next, func(i, j int) bool {
+			if score, other := next[i].squad.Score(), next[j].squad.Score(); score != other {
+				return score < other
+			}
+			return squadKey(next[i].added) < squadKey(next[j].added)
+		})
+
+		if len(next) > width {
+			next = next[:width]
+		}
+		beam = next
+	}
+
+	if len(beam) > alternatives {
+		beam = beam[:alternatives]
+	}
+
+	squads := make([]Squad, 0, len(beam))
+	for _, squad := range beam {
+		squads = append(squads, squad.squad)
+	}
+
+	return squads
+}
+
+func fits(members []*store.Player, candidate *store.Player, conflict Conflict) bool {
+	for _, member := range members {
+		if member.ID == candidate.ID || (conflict != nil && conflict(member.ID, candidate.ID)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Centroid is the mean vector of the players
+func Centroid(players []*store.Player) []float32 {
+	if len(players) == 0 {
+		return nil
+	}
+
+	centroid := make([]float32, len(players[0].Vector))
+	for _, player := range players {
+		for i := range centroid {
+			if i < len(player.Vector) {
+				centroid[i] += player.Vector[i]
+			}
+		}
+	}
+
+	for i := range centroid {
+		centroid[i] /= float32(len(players))
+	}
+
+	return centroid
+}
+
+// measure returns the spread of the players around their centroid and the drift of
+// the centroid from target
+func measure(players []*store.Player, target []float32) (float32, float32) {
+	centroid := Centroid(players)
+
+	var spread float32
+	for _, player := range players {
+		for i := range centroid {
+			if i < len(player.Vector) {
+				diff := player.Vector[i] - centroid[i]
+				spread += diff * diff
+			}
+		}
+	}
+
+	var drift float32
+	for i := range centroid {
+		if i < len(target) {
+			diff := centroid[i] - target[i]
+			drift += diff * diff
+		}
+	}
+
+	return spread / float32(len(players)), drift
+}
+
+func squadKey(players []*store.Player) string {
+	ids := make([]string, 0, len(players))
+	for _, player := range players {
+		ids = append(ids, player.ID)
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
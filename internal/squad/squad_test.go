@@ -0,0 +1,96 @@
+package squad
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+)
+
+func player(id string, vector ...float32) *store.Player {
+	return &store.Player{ID: id, Vector: vector}
+}
+
+func candidateIDs(squad Squad) []string {
+	ids := make([]string, 0, len(squad.Candidates))
+	for _, candidate := range squad.Candidates {
+		ids = append(ids, candidate.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestBuild(t *testing.T) {
+	seeds := []*store.Player{player("seed-1", 0, 0), player("seed-2", 1, 0)}
+	pool := []*store.Player{
+		player("a", 0.5, 0),
+		player("b", 0.5, 0.5),
+		player("c", 0.5, -0.5),
+		player("d", 5, 5),
+		player("e", 0, 1),
+	}
+
+	squads := Build(seeds, pool, 2, nil, nil)
+	if len(squads) != 2 {
+		t.Fatalf("Build() = %v squads, want %v", len(squads), 2)
+	}
+
+	if got, want := candidateIDs(squads[0]), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Build() best squad = %v, want %v", got, want)
+	}
+
+	if squads[0].Score() > squads[1].Score() {
+		t.Errorf("Build() squads not ordered by score: %v then %v", squads[0].Score(), squads[1].Score())
+	}
+
+	for _, squad := range squads {
+		for _, candidate := range squad.Candidates {
+			if candidate.ID == "d" {
+				t.Errorf("Build() picked the outlier into %v", candidateIDs(squad))
+			}
+		}
+	}
+}
+
+func TestBuildWithConflicts(t *testing.T) {
+	seeds := []*store.Player{player("seed-1", 0, 0)}
+	pool := []*store.Player{player("a", 0.1, 0), player("b", 0.2, 0), player("c", 0.3, 0), player("d", 0.4, 0), player("e", 3, 0)}
+
+	// seed-1 blocked a, the squad has to fall back to the outlier
+	conflict := func(x, y string) bool {
+		return (x == "seed-1" && y == "a") || (x == "a" && y == "seed-1")
+	}
+
+	squads := Build(seeds, pool, 1, nil, conflict)
+	if got, want := candidateIDs(squads[0]), []string{"b", "c", "d", "e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+
+	if squads := Build(seeds, pool[:2], 1, nil, nil); squads != nil {
+		t.Errorf("Build() with a pool too small = %v, want nil", squads)
+	}
+}
+
+func TestBuildTarget(t *testing.T) {
+	seeds := []*store.Player{player("seed-1", 0, 0)}
+	pool := []*store.Player{
+		player("a", 0.1, 0), player("b", 0.2, 0), player("c", 0.3, 0), player("d", 0.4, 0),
+		player("e", 2.4, 0), player("f", 2.5, 0), player("g", 2.6, 0), player("h", 2.7, 0),
+	}
+
+	// without a target the squad stays around the seed
+	if got, want := candidateIDs(Build(seeds, pool, 1, nil, nil)[0]), []string{"a", "b", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+
+	// the squad averages out to the target even though no member is close to it
+	squads := Build(seeds, pool, 1, []float32{2, 0}, nil)
+	if got, want := candidateIDs(squads[0]), []string{"e", "f", "g", "h"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Build() with a target = %v, want %v", got, want)
+	}
+
+	if squads[0].Drift > 0.01 || squads[0].Fit() < 99 {
+		t.Errorf("Build() with a target drift = %v, fit = %v, want the centroid on the target", squads[0].Drift, squads[0].Fit())
+	}
+}
@@ -0,0 +1,104 @@
+package statistics
+
+import "sort"
+
+// P2 estimates a single quantile in constant memory with the P² algorithm of Jain and
+// Chlamtac. Five markers track the minimum, the quantile, the maximum and the points
+// halfway between, their heights are adjusted with a piecewise parabolic fit
+type P2 struct {
+	P     float64 `json:"p"`
+	Count int64   `json:"count"`
+	// Heights are the marker heights, the observations themselves until there are five
+	Heights   [5]float64 `json:"heights"`
+	Positions [5]float64 `json:"positions"`
+	Desired   [5]float64 `json:"desired"`
+}
+
+func NewP2(p float64) *P2 {
+	return &P2{
+		P:         p,
+		Positions: [5]float64{0, 1, 2, 3, 4},
+		Desired:   [5]float64{0, 2 * p, 4 * p, 2 + 2*p, 4},
+	}
+}
+
+// Add updates the estimate with an observation
+func (e *P2) Add(x float64) {
+	if e.Count < 5 {
+		e.Heights[e.Count] = x
+		e.Count++
+		if e.Count == 5 {
+			sort.Float64s(e.Heights[:])
+		}
+		return
+	}
+	e.Count++
+
+	// find the cell x falls in, stretching the extremes if needed
+	var k int
+	switch {
+	case x < e.Heights[0]:
+		e.Heights[0] = x
+		k = 0
+	case x >= e.Heights[4]:
+		e.Heights[4] = x
+		k = 3
+	default:
+		for k < 3 && x >= e.Heights[k+1] {
+			k++
+		}
+	}
+
+	for i := k + 1; i < 5; i++ {
+		e.Positions[i]++
+	}
+
+	increments := [5]float64{0, e.P / 2, e.P, (1 + e.P) / 2, 1}
+	for i := range e.Desired {
+		e.Desired[i] += increments[i]
+	}
+
+	// move the middle markers towards their desired positions
+	for i := 1; i < 4; i++ {
+		d := e.Desired[i] - e.Positions[i]
+		if (d >= 1 && e.Positions[i+1]-e.Positions[i] > 1) || (d <= -1 && e.Positions[i-1]-e.Positions[i] < -1) {
+			step := 1.0
+			if d < 0 {
+				step = -1
+			}
+
+			height := e.parabolic(i, step)
+			if height <= e.Heights[i-1] || height >= e.Heights[i+1] {
+				height = e.linear(i, step)
+			}
+
+			e.Heights[i] = height
+			e.Positions[i] += step
+		}
+	}
+}
+
+func (e *P2) parabolic(i int, d float64) float64 {
+	n, q := e.Positions, e.Heights
+	return q[i] + d/(n[i+1]-n[i-1])*((n[i]-n[i-1]+d)*(q[i+1]-q[i])/(n[i+1]-n[i])+(n[i+1]-n[i]-d)*(q[i]-q[i-1])/(n[i]-n[i-1]))
+}
+
+func (e *P2) linear(i int, d float64) float64 {
+	j := i + int(d)
+	return e.Heights[i] + d*(e.Heights[j]-e.Heights[i])/(e.Positions[j]-e.Positions[i])
+}
+
+// Value is the current estimate, exact while there are fewer than five observations
+func (e *P2) Value() float64 {
+	if e.Count == 0 {
+		return 0
+	}
+
+	if e.Count < 5 {
+		observed := append([]float64(nil), e.Heights[:e.Count]...)
+		sort.Float64s(observed)
+		return observed[int(e.P*float64(len(observed)-1)+0.5)]
+	}
+
+	return e.Heights[2]
+}
@@ -0,0 +1,44 @@
+package statistics
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestP2(t *testing.T) {
+	// exact while there are fewer than five observations
+	median := NewP2(0.5)
+	for _, x := range []float64{3, 1, 2} {
+		median.Add(x)
+	}
+
+	if median.Value() != 2 {
+		t.Errorf("P2.Value() = %v, want %v", median.Value(), 2)
+	}
+
+	random := rand.New(rand.NewSource(1))
+	testCases := []struct {
+		name   string
+		sample func() float64
+		p      float64
+		want   float64
+		within float64
+	}{
+		{"uniform median", random.Float64, 0.5, 0.5, 0.02},
+		{"uniform p95", random.Float64, 0.95, 0.95, 0.02},
+		{"normal p25", random.NormFloat64, 0.25, -0.6745, 0.05},
+		{"exponential p75", random.ExpFloat64, 0.75, math.Log(4), 0.05},
+	}
+
+	for _, testCase := range testCases {
+		estimator := NewP2(testCase.p)
+		for i := 0; i < 20000; i++ {
+			estimator.Add(testCase.sample())
+		}
+
+		if got := estimator.Value(); math.Abs(got-testCase.want) > testCase.within {
+			t.Errorf("%s: P2.Value() = %v, want %v ± %v", testCase.name, got, testCase.want, testCase.within)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package statistics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/atomicfile"
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
+)
+
+// Quantiles are the quantiles estimated for every feature
+var Quantiles = []float64{0.05, 0.25, 0.5, 0.75, 0.95}
+
+// Feature holds the streaming estimates of one dimension of the raw vectors
+type Feature struct {
+	Name      string  `json:"name"`
+	Moments   Welford `json:"moments"`
+	Quantiles []*P2   `json:"quantiles"`
+}
+
+func newFeature(name string) *Feature {
+	feature := &Feature{Name: name}
+	for _, p := range Quantiles {
+		feature.Quantiles = append(feature.Quantiles, NewP2(p))
+	}
+	return feature
+}
+
+// Collector estimates the population distribution of the raw, not yet normalized,
+// vectors of a schema from the players passing through Index. A player counts once
+// until the estimates are reset, re-indexing it doesn't weigh it more
+type Collector struct {
+	mutex         *sync.Mutex
+	schemaVersion int
+	features      []*Feature
+	// seen holds hashes of the players observed
+	seen map[uint64]struct{}
+}
+
+func New(schema vectors.Schema) *Collector {
+	features := make([]*Feature, 0, schema.Dimension())
+	for _, name := range schema.Names() {
+		features = append(features, newFeature(name))
+	}
+
+	return &Collector{
+		mutex:         &sync.Mutex{},
+		schemaVersion: schema.Version,
+		features:      features,
+		seen:          map[uint64]struct{}{},
+	}
+}
+
+// Observe adds the raw vector of an indexed player to the estimates, unless the player
+// was observed before
+func (c *Collector) Observe(id string, raw []float32) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := playerKey(id)
+	if _, ok := c.seen[key]; ok {
+		return
+	}
+	c.seen[key] = struct{}{}
+
+	for i, feature := range c.features {
+		if i >= len(raw) {
+			break
+		}
+
+		feature.Moments.Add(float64(raw[i]))
+		for _, quantile := range feature.Quantiles {
+			quantile.Add(float64(raw[i]))
+		}
+	}
+}
+
+// SchemaVersion is the version of the schema the estimates are for
+func (c *Collector) SchemaVersion() int {
+	return c.schemaVersion
+}
+
+// Features returns a copy of the current estimates
+func (c *Collector) Features() []Feature {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return copyFeatures(c.features)
+}
+
+// Take returns the current estimates and starts over in the same step, so no
+// observation is lost between reading and resetting them
+func (c *Collector) Take() []Feature {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	features := copyFeatures(c.features)
+	c.reset()
+	return features
+}
+
+func copyFeatures(from []*Feature) []Feature {
+	features := make([]Feature, 0, len(from))
+	for _, feature := range from {
+		copied := Feature{Name: feature.Name, Moments: feature.Moments}
+		for _, quantile := range feature.Quantiles {
+			q := *quantile
+			copied.Quantiles = append(copied.Quantiles, &q)
+		}
+		features = append(features, copied)
+	}
+	return features
+}
+
+// Reset drops every estimate, used to start over once the population has drifted
+func (c *Collector) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.reset()
+}
+
+func (c *Collector) reset() {
+	for i, feature := range c.features {
+		c.features[i] = newFeature(feature.Name)
+	}
+	c.seen = map[uint64]struct{}{}
+}
+
+// playerKey hashes a player id, the hashes of a large population are much smaller
+// than its ids
+func playerKey(id string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(id))
+	return hash.Sum64()
+}
+
+// Normalization refits base from the estimates, see Refit
+func (c *Collector) Normalization(base vectors.Normalization, minCount int64) vectors.Normalization {
+	return Refit(c.Features(), base, minCount)
+}
+
+// Refit refits base from features. Dimensions base passes through, like one-hot
+// encodings, keep passing through and dimensions with fewer than minCount
+// observations or without any spread keep their base parameters
+func Refit(features []Feature, base vectors.Normalization, minCount int64) vectors.Normalization {
+	refit := vectors.Normalization{
+		Mean:   append([]float32(nil), base.Mean...),
+		StdDev: append([]float32(nil), base.StdDev...),
+	}
+
+	for i, feature := range features {
+		if i >= len(refit.Mean) || i >= len(refit.StdDev) || refit.StdDev[i] == 0 {
+			continue
+		}
+
+		if feature.Moments.Count < minCount || feature.Moments.StdDev() == 0 {
+			continue
+		}
+
+		refit.Mean[i] = float32(feature.Moments.Mean)
+		refit.StdDev[i] = float32(feature.Moments.StdDev())
+	}
+
+	return refit
+}
+
+// snapshot is the persisted form of a Collector
+type snapshot struct {
+	SchemaVersion int        `json:"schemaVersion"`
+	Features      []*Feature `json:"features"`
+	Seen          []uint64   `json:"seen,omitempty"`
+}
+
+// Load restores the estimates saved at path. A missing file or estimates of another
+// schema start over, the raw vectors of different schemas aren't comparable
+func Load(path string, schema vectors.Schema) (*Collector, error) {
+	collector := New(schema)
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return collector, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var saved snapshot
+	if err := json.Unmarshal(raw, &saved); err != nil {
+		return nil, fmt.Errorf("statistics: %s: %w", path, err)
+	}
+
+	if saved.SchemaVersion != schema.Version || len(saved.Features) != len(collector.features) {
+		slog.Warn("statistics: estimates are of another schema, starting over", "path", path, "saved_schema_version", saved.SchemaVersion, "schema_version", schema.Version)
+		return collector, nil
+	}
+
+	for i, feature := range saved.Features {
+		if feature.Name != collector.features[i].Name || len(feature.Quantiles) != len(Quantiles) {
+			slog.Warn("statistics: estimates don't match the schema's features, starting over", "path", path, "schema_version", schema.Version)
+			return New(schema), nil
+		}
+		collector.features[i] = feature
+	}
+
+	for _, key := range saved.Seen {
+		collector.seen[key] = struct{}{}
+	}
+
+	return collector, nil
+}
+
+// Save writes the estimates to path, replacing the previous file atomically
+func (c *Collector) Save(path string) error {
+	c.mutex.Lock()
+	seen := make([]uint64, 0, len(c.seen))
+	for key := range c.seen {
+		seen = append(seen, key)
+	}
+	raw, err := json.Marshal(snapshot{SchemaVersion: c.schemaVersion, Features: c.features, Seen: seen})
+	c.mutex.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.WriteFile(path, raw, 0o644)
+}
+
+// Run saves the estimates to path every interval and once more when ctx is done
+func (c *Collector) Run(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := c.Save(path); err != nil {
+				slog.Error("statistics: saving estimates", "path", path, "err", err)
+			}
+			return
+		case <-ticker.C:
+			if err := c.Save(path); err != nil {
+				slog.Error("statistics: saving estimates", "path", path, "err", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package statistics
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
+)
+
+func TestCollector(t *testing.T) {
+	collector := New(vectors.SchemaV1)
+
+	// levels 100..199, kost fixed
+	for i := 0; i < 100; i++ {
+		collector.Observe(fmt.Sprint(i), []float32{float32(100 + i), 0.5, 18, 2000})
+	}
+
+	// a re-indexed player counts once
+	collector.Observe("0", []float32{1000, 0.5, 18, 2000})
+
+	features := collector.Features()
+	if len(features) != 4 || features[0].Name != "level" {
+		t.Fatalf("Collector.Features() = %v, want the schema's features", features)
+	}
+
+	level := features[0]
+	if level.Moments.Count != 100 || level.Moments.Mean != 149.5 {
+		t.Errorf("level moments = %+v, want 100 observations around 149.5", level.Moments)
+	}
+
+	if median := level.Quantiles[2]; median.P != 0.5 || median.Value() < 145 || median.Value() > 155 {
+		t.Errorf("level median = %v, want around 149.5", median.Value())
+	}
+
+	// kost has no spread and keeps its base parameters, like dimensions with too few observations
+	refit := collector.Normalization(vectors.SchemaV1.Normalization, 10)
+	if refit.Mean[0] != 149.5 || refit.StdDev[0] < 29 || refit.StdDev[0] > 29.1 {
+		t.Errorf("refit level = %v ± %v, want 149.5 ± 29.0", refit.Mean[0], refit.StdDev[0])
+	}
+
+	if refit.Mean[1] != vectors.SchemaV1.Normalization.Mean[1] || refit.StdDev[1] != vectors.SchemaV1.Normalization.StdDev[1] {
+		t.Errorf("refit kost = %v ± %v, want the base parameters", refit.Mean[1], refit.StdDev[1])
+	}
+
+	if unchanged := collector.Normalization(vectors.SchemaV1.Normalization, 1000); unchanged.Mean[0] != vectors.SchemaV1.Normalization.Mean[0] {
+		t.Errorf("refit with too few observations = %v, want the base mean", unchanged.Mean[0])
+	}
+
+	if taken := collector.Take(); taken[0].Moments != level.Moments {
+		t.Errorf("Collector.Take() = %+v, want the estimates before it", taken[0].Moments)
+	}
+
+	if features := collector.Features(); features[0].Moments.Count != 0 {
+		t.Errorf("Collector.Take() kept %v", features[0])
+	}
+
+	// players count again once the estimates start over
+	collector.Observe("0", []float32{100, 0.5, 18, 2000})
+	collector.Reset()
+	if features := collector.Features(); features[0].Moments.Count != 0 || features[0].Quantiles[0].Count != 0 {
+		t.Errorf("Collector.Reset() kept %v", features[0])
+	}
+
+	collector.Observe("0", []float32{100, 0.5, 18, 2000})
+	if features := collector.Features(); features[0].Moments.Count != 1 {
+		t.Errorf("Collector.Observe() after Reset() = %d observations, want 1", features[0].Moments.Count)
+	}
+}
+
+func TestCollectorPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "statistics.json")
+
+	// nothing saved yet
+	collector, err := Load(path, vectors.SchemaV1)
+	if err != nil {
+		t.Fatalf("Load() error = %v, want nil", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		collector.Observe(fmt.Sprint(i), []float32{float32(i), 0.5, 18, 2000})
+	}
+
+	if err := collector.Save(path); err != nil {
+		t.Fatalf("Collector.Save() error = %v, want nil", err)
+	}
+
+	restored, err := Load(path, vectors.SchemaV1)
+	if err != nil {
+		t.Fatalf("Load() error = %v, want nil", err)
+	}
+
+	got, want := restored.Features()[0], collector.Features()[0]
+	if got.Moments != want.Moments || got.Quantiles[2].Value() != want.Quantiles[2].Value() {
+		t.Errorf("Load() = %+v, want %+v", got, want)
+	}
+
+	// players observed before the restart still count once
+	restored.Observe("0", []float32{100, 0.5, 18, 2000})
+	if got := restored.Features()[0].Moments.Count; got != 10 {
+		t.Errorf("Collector.Observe() of a saved player = %d observations, want 10", got)
+	}
+
+	// estimates of another schema are dropped
+	other, err := Load(path, vectors.SchemaV2)
+	if err != nil {
+		t.Fatalf("Load() error = %v, want nil", err)
+	}
+
+	if other.SchemaVersion() != 2 || other.Features()[0].Moments.Count != 0 {
+		t.Errorf("Load() of another schema kept the saved estimates")
+	}
+}
@@ -0,0 +1,31 @@
+package statistics
+
+import "math"
+
+// Welford keeps a running mean and variance in a single pass without storing the
+// observations, numerically stable unlike summing squares
+type Welford struct {
+	Count int64   `json:"count"`
+	Mean  float64 `json:"mean"`
+	M2    float64 `json:"m2"`
+}
+
+// Add updates the estimates with an observation
+func (w *Welford) Add(x float64) {
+	w.Count++
+	delta := x - w.Mean
+	w.Mean += delta / float64(w.Count)
+	w.M2 += delta * (x - w.Mean)
+}
+
+// Variance is the sample variance, 0 until there are two observations
+func (w *Welford) Variance() float64 {
+	if w.Count < 2 {
+		return 0
+	}
+	return w.M2 / float64(w.Count-1)
+}
+
+func (w *Welford) StdDev() float64 {
+	return math.Sqrt(w.Variance())
+}
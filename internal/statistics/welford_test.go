@@ -0,0 +1,27 @@
+package statistics
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWelford(t *testing.T) {
+	var w Welford
+
+	if w.Variance() != 0 || w.StdDev() != 0 {
+		t.Errorf("Welford.Variance() = %v without observations, want 0", w.Variance())
+	}
+
+	// offset by a large constant, naive sums of squares lose the variance here
+	for _, x := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		w.Add(1e9 + x)
+	}
+
+	if w.Count != 8 || w.Mean != 1e9+5 {
+		t.Errorf("Welford = %d observations, mean %v, want 8 and %v", w.Count, w.Mean, 1e9+5)
+	}
+
+	if got, want := w.Variance(), 32.0/7; math.Abs(got-want) > 1e-6 {
+		t.Errorf("Welford.Variance() = %v, want %v", got, want)
+	}
+}
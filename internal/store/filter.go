@@ -0,0 +1,193 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+type Operator int
+
+const (
+	Equal Operator = iota
+	NotEqual
+	GreaterThan
+	GreaterThanEqual
+	LessThan
+	LessThanEqual
+	// In matches any of Condition.Values
+	In
+)
+
+type PropertyType int
+
+const (
+	TextProperty PropertyType = iota
+	NumberProperty
+	DateProperty
+)
+
+// FilterableProperties are the player properties conditions can be written against
+var FilterableProperties = map[string]PropertyType{
+	"platform":   TextProperty,
+	"region":     TextProperty,
+	"language":   TextProperty,
+	"lastSeen":   DateProperty,
+	"level":      NumberProperty,
+	"kost":       NumberProperty,
+	"rank":       NumberProperty,
+	"rankPoints": NumberProperty,
+	"season":     NumberProperty,
+}
+
+// Condition compares a single property, values are string, float64 or time.Time
+// depending on the property type
+type Condition struct {
+	Property string
+	Operator Operator
+	Value    interface{}
+	// Values is used by the In operator instead of Value
+	Values []interface{}
+}
+
+// Filter is a conjunction of conditions, an empty filter matches every player
+type Filter []Condition
+
+// Validate checks that every condition targets a filterable property with values of its type
+func (f Filter) Validate() error {
+	for _, condition := range f {
+		propertyType, ok := FilterableProperties[condition.Property]
+		if !ok {
+			return fmt.Errorf("unknown property %q", condition.Property)
+		}
+
+		if propertyType == TextProperty && condition.Operator != Equal && condition.Operator != NotEqual && condition.Operator != In {
+			return fmt.Errorf("property %q only supports equality and in", condition.Property)
+		}
+
+		values := []interface{}{condition.Value}
+		if condition.Operator == In {
+			if len(condition.Values) == 0 {
+				return fmt.Errorf("property %q: in needs at least one value", condition.Property)
+			}
+			values = condition.Values
+		}
+
+		for _, value := range values {
+			if !hasType(value, propertyType) {
+				return fmt.Errorf("property %q: value %v has the wrong type", condition.Property, value)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Match reports whether the player satisfies every condition
+func (f Filter) Match(player *Player) bool {
+	for _, condition := range f {
+		if !condition.match(player.property(condition.Property)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c Condition) match(value interface{}) bool {
+	if c.Operator == In {
+		for _, candidate := range c.Values {
+			if compare(value, candidate) == 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	cmp := compare(value, c.Value)
+	switch c.Operator {
+	case Equal:
+		return cmp == 0
+	case NotEqual:
+		return cmp != 0
+	case GreaterThan:
+		return cmp > 0
+	case GreaterThanEqual:
+		return cmp >= 0
+	case LessThan:
+		return cmp < 0
+	case LessThanEqual:
+		return cmp <= 0
+	}
+
+	return false
+}
+
+func (p *Player) property(name string) interface{} {
+	switch name {
+	case "platform":
+		return p.Platform
+	case "region":
+		return p.Region
+	case "language":
+		return p.Language
+	case "lastSeen":
+		return p.LastSeen
+	case "level":
+		return float64(p.Stats.Level)
+	case "kost":
+		return p.Stats.Kost
+	case "rank":
+		return float64(p.Stats.Rank)
+	case "rankPoints":
+		return float64(p.Stats.RankPoints)
+	case "season":
+		return float64(p.Stats.Season)
+	}
+
+	return nil
+}
+
+// compare orders two values of the same type, mismatched types never compare equal
+func compare(a, b interface{}) int {
+	switch a := a.(type) {
+	case string:
+		b, ok := b.(string)
+		if !ok || a != b {
+			return boolToCmp(ok && a > b)
+		}
+	case float64:
+		b, ok := b.(float64)
+		if !ok || a != b {
+			return boolToCmp(ok && a > b)
+		}
+	case time.Time:
+		b, ok := b.(time.Time)
+		if !ok || !a.Equal(b) {
+			return boolToCmp(ok && a.After(b))
+		}
+	default:
+		return -1
+	}
+
+	return 0
+}
+
+func boolToCmp(greater bool) int {
+	if greater {
+		return 1
+	}
+	return -1
+}
+
+func hasType(value interface{}, propertyType PropertyType) bool {
+	switch value.(type) {
+	case string:
+		return propertyType == TextProperty
+	case float64:
+		return propertyType == NumberProperty
+	case time.Time:
+		return propertyType == DateProperty
+	}
+
+	return false
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
+)
+
+func TestFilterMatch(t *testing.T) {
+	lastSeen := time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC)
+	player := &Player{
+		Stats:    vectors.Player{Level: 300, Kost: 0.55, Rank: 18, RankPoints: 1250},
+		Platform: "pc",
+		Region:   "emea",
+		LastSeen: lastSeen,
+	}
+
+	testCases := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"empty filter", nil, true},
+		{"equal", Filter{{Property: "platform", Operator: Equal, Value: "pc"}}, true},
+		{"not equal", Filter{{Property: "platform", Operator: NotEqual, Value: "pc"}}, false},
+		{"in", Filter{{Property: "region", Operator: In, Values: []interface{}{"ncsa", "emea"}}}, true},
+		{"not in", Filter{{Property: "region", Operator: In, Values: []interface{}{"ncsa", "apac"}}}, false},
+		{"rank band", Filter{
+			{Property: "rankPoints", Operator: GreaterThanEqual, Value: 1200.0},
+			{Property: "rankPoints", Operator: LessThan, Value: 1300.0},
+		}, true},
+		{"outside rank band", Filter{
+			{Property: "rankPoints", Operator: GreaterThanEqual, Value: 1300.0},
+		}, false},
+		{"seen recently", Filter{{Property: "lastSeen", Operator: GreaterThan, Value: lastSeen.Add(-time.Hour)}}, true},
+		{"conjunction", Filter{
+			{Property: "platform", Operator: Equal, Value: "pc"},
+			{Property: "region", Operator: Equal, Value: "apac"},
+		}, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := testCase.filter.Validate(); err != nil {
+				t.Fatalf("Filter.Validate() error = %v, want nil", err)
+			}
+
+			if got := testCase.filter.Match(player); got != testCase.want {
+				t.Errorf("Filter.Match() = %v, want %v", got, testCase.want)
+			}
+		})
+	}
+}
+
+func TestFilterValidate(t *testing.T) {
+	testCases := []struct {
+		name   string
+		filter Filter
+	}{
+		{"unknown property", Filter{{Property: "kd", Operator: Equal, Value: 1.0}}},
+		{"range on text", Filter{{Property: "region", Operator: GreaterThan, Value: "emea"}}},
+		{"wrong value type", Filter{{Property: "level", Operator: Equal, Value: "300"}}},
+		{"empty in", Filter{{Property: "region", Operator: In}}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := testCase.filter.Validate(); err == nil {
+				t.Errorf("Filter.Validate() error = nil, want error")
+			}
+		})
+	}
+}
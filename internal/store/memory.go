@@ -0,0 +1,214 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Memory is an in-process Store, used in tests and for local development
+type Memory struct {
+	mutex *sync.RWMutex
+	// players holds the records of every player by id and season
+	players map[string]map[int]*Player
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		mutex:   &sync.RWMutex{},
+		players: make(map[string]map[int]*Player),
+	}
+}
+
+func (m *Memory) Upsert(ctx context.Context, players []*Player) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, player := range players {
+		seasons, ok := m.players[player.ID]
+		if !ok {
+			seasons = make(map[int]*Player)
+			m.players[player.ID] = seasons
+		}
+
+		// a player's first seasoned record replaces the one indexed before seasons were
+		// required
+		if player.Stats.Season != 0 {
+			delete(seasons, 0)
+		}
+
+		// store a copy so callers can't mutate the stored record
+		seasons[player.Stats.Season] = clone(player)
+	}
+
+	return nil
+}
+
+func (m *Memory) Delete(ctx context.Context, ids []string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, id := range ids {
+		delete(m.players, id)
+	}
+
+	return nil
+}
+
+func (m *Memory) Get(ctx context.Context, id string) (*Player, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var latest *Player
+	for _, player := range m.players[id] {
+		if latest == nil || player.Stats.Season > latest.Stats.Season {
+			latest = player
+		}
+	}
+
+	if latest == nil {
+		return nil, ErrNotFound
+	}
+
+	return clone(latest), nil
+}
+
+func (m *Memory) History(ctx context.Context, id string) ([]*Player, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	seasons := m.players[id]
+	if len(seasons) == 0 {
+		return nil, ErrNotFound
+	}
+
+	history := make([]*Player, 0, len(seasons))
+	for _, player := range seasons {
+		history = append(history, clone(player))
+	}
+
+	sort.Slice(history, func(i, j int) bool { return history[i].Stats.Season < history[j].Stats.Season })
+	return history, nil
+}
+
+// NearVector does an exhaustive l2-squared search over the players of the schema
+// version matching the filter, ties are broken by id and season
+func (m *Memory) NearVector(ctx context.Context, query Query) ([]Hit, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	hits := make([]Hit, 0, len(m.players))
+	for _, seasons := range m.players {
+		for _, player := range seasons {
+			if player.SchemaVersion != query.SchemaVersion || !query.Filter.Match(player) {
+				continue
+			}
+
+			hits = append(hits, Hit{Player: player, Distance: l2Squared(query.Vector, player.Vector)})
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Distance != hits[j].Distance {
+			return hits[i].Distance < hits[j].Distance
+		}
+		if hits[i].Player.ID != hits[j].Player.ID {
+			return hits[i].Player.ID < hits[j].Player.ID
+		}
+		return hits[i].Player.Stats.Season > hits[j].Player.Stats.Season
+	})
+
+	if len(hits) > query.Limit {
+		hits = hits[:max(query.Limit, 0)]
+	}
+
+	for i := range hits {
+		hits[i].Player = clone(hits[i].Player)
+	}
+
+	return hits, nil
+}
+
+func (m *Memory) Scan(ctx context.Context, cursor string, limit int) ([]*Player, string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	keys := make([]string, 0, len(m.players))
+	records := make(map[string]*Player, len(m.players))
+	for _, seasons := range m.players {
+		for _, player := range seasons {
+			key := scanKey(player)
+			if key > cursor {
+				keys = append(keys, key)
+				records[key] = player
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	if limit < 1 {
+		limit = 1
+	}
+
+	if len(keys) <= limit {
+		limit = len(keys)
+	}
+
+	page := make([]*Player, 0, limit)
+	for _, key := range keys[:limit] {
+		page = append(page, clone(records[key]))
+	}
+
+	if limit == len(keys) {
+		return page, "", nil
+	}
+	return page, keys[limit-1], nil
+}
+
+func (m *Memory) Count(ctx context.Context) (int, error) {
+	return m.Len(), nil
+}
+
+// scanKey orders records by player and season, seasons are padded to sort as numbers
+func scanKey(player *Player) string {
+	return fmt.Sprintf("%s/%010d", player.ID, player.Stats.Season)
+}
+
+// Len returns the number of stored records, a record per player and season
+func (m *Memory) Len() int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	records := 0
+	for _, seasons := range m.players {
+		records += len(seasons)
+	}
+	return records
+}
+
+func clone(player *Player) *Player {
+	cloned := *player
+	cloned.Vector = append([]float32(nil), player.Vector...)
+
+	if player.Stats.OperatorPickRates != nil {
+		cloned.Stats.OperatorPickRates = make(map[string]float64, len(player.Stats.OperatorPickRates))
+		for operator, rate := range player.Stats.OperatorPickRates {
+			cloned.Stats.OperatorPickRates[operator] = rate
+		}
+	}
+
+	return &cloned
+}
+
+func l2Squared(a, b []float32) float32 {
+	var distance float32
+	for i := range a {
+		if i >= len(b) {
+			break
+		}
+		diff := a[i] - b[i]
+		distance += diff * diff
+	}
+	return distance
+}
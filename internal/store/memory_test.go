@@ -0,0 +1,255 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
+)
+
+func TestMemoryUpsertAndDelete(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemory()
+
+	players := []*Player{
+		{ID: "6844b415-aa94-43c9-8823-9389e4816918", Stats: vectors.Player{Level: 300, Kost: 0.55, Rank: 18, RankPoints: 1250}},
+		{ID: "6844b415-aa94-43c9-8823-9389e4816454", Stats: vectors.Player{Level: 300, Kost: 0.58, Rank: 18, RankPoints: 1245}},
+	}
+
+	if err := memory.Upsert(ctx, players); err != nil {
+		t.Fatalf("Memory.Upsert() error = %v, want nil", err)
+	}
+
+	// replacing an existing player must not grow the store
+	if err := memory.Upsert(ctx, players[:1]); err != nil {
+		t.Fatalf("Memory.Upsert() error = %v, want nil", err)
+	}
+
+	if memory.Len() != 2 {
+		t.Errorf("Memory.Len() = %v, want %v", memory.Len(), 2)
+	}
+
+	if err := memory.Delete(ctx, []string{players[0].ID, "unknown"}); err != nil {
+		t.Fatalf("Memory.Delete() error = %v, want nil", err)
+	}
+
+	if memory.Len() != 1 {
+		t.Errorf("Memory.Len() = %v, want %v", memory.Len(), 1)
+	}
+}
+
+func TestMemoryGet(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemory()
+
+	player := &Player{
+		ID:     "6844b415-aa94-43c9-8823-9389e4816918",
+		Stats:  vectors.Player{Level: 300, Kost: 0.55, Rank: 18, RankPoints: 1250},
+		Vector: []float32{300, 0.55, 18, 1250},
+	}
+
+	if err := memory.Upsert(ctx, []*Player{player}); err != nil {
+		t.Fatalf("Memory.Upsert() error = %v, want nil", err)
+	}
+
+	got, err := memory.Get(ctx, player.ID)
+	if err != nil {
+		t.Fatalf("Memory.Get() error = %v, want nil", err)
+	}
+
+	if !reflect.DeepEqual(got, player) {
+		t.Errorf("Memory.Get() = %+v, want %+v", got, player)
+	}
+
+	// the returned record is a copy
+	got.Vector[0] = 0
+	if again, _ := memory.Get(ctx, player.ID); again.Vector[0] != 300 {
+		t.Errorf("Memory.Get() returned a shared vector")
+	}
+
+	if _, err := memory.Get(ctx, "unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Memory.Get() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestMemoryNearVector(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemory()
+
+	players := []*Player{
+		{ID: "6844b415-aa94-43c9-8823-9389e4816918", Vector: []float32{3, 0}, SchemaVersion: 1},
+		{ID: "6844b415-aa94-43c9-8823-9389e4816454", Vector: []float32{1, 0}, SchemaVersion: 1},
+		{ID: "6844b415-aa94-43c9-8823-9389e4816861", Vector: []float32{0, 2}, SchemaVersion: 1},
+		{ID: "6844b415-aa94-43c9-8823-9389e4816300", Vector: []float32{0, 1}, SchemaVersion: 1},
+		// built with another schema, never compared with the query
+		{ID: "6844b415-aa94-43c9-8823-9389e4816905", Vector: []float32{0, 0}, SchemaVersion: 2},
+	}
+
+	if err := memory.Upsert(ctx, players); err != nil {
+		t.Fatalf("Memory.Upsert() error = %v, want nil", err)
+	}
+
+	hits, err := memory.NearVector(ctx, Query{Vector: []float32{0, 0}, SchemaVersion: 1, Limit: 3})
+	if err != nil {
+		t.Fatalf("Memory.NearVector() error = %v, want nil", err)
+	}
+
+	// equal distances are ordered by id
+	want := []string{"6844b415-aa94-43c9-8823-9389e4816300", "6844b415-aa94-43c9-8823-9389e4816454", "6844b415-aa94-43c9-8823-9389e4816861"}
+	var got []string
+	for _, hit := range hits {
+		got = append(got, hit.Player.ID)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Memory.NearVector() = %v, want %v", got, want)
+	}
+
+	if hits[2].Distance != 4 {
+		t.Errorf("Memory.NearVector() distance = %v, want %v", hits[2].Distance, 4)
+	}
+}
+
+func TestMemorySeasons(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemory()
+
+	id := "6844b415-aa94-43c9-8823-9389e4816918"
+	players := []*Player{
+		{ID: id, Stats: vectors.Player{Rank: 20, Season: 30}, Vector: []float32{0, 3}, SchemaVersion: 1},
+		{ID: id, Stats: vectors.Player{Rank: 18, Season: 28}, Vector: []float32{0, 1}, SchemaVersion: 1},
+		{ID: id, Stats: vectors.Player{Rank: 19, Season: 29}, Vector: []float32{0, 2}, SchemaVersion: 1},
+		{ID: "6844b415-aa94-43c9-8823-9389e4816454", Stats: vectors.Player{Season: 30}, Vector: []float32{0, 0}, SchemaVersion: 1},
+	}
+
+	if err := memory.Upsert(ctx, players); err != nil {
+		t.Fatalf("Memory.Upsert() error = %v, want nil", err)
+	}
+
+	if memory.Len() != 4 {
+		t.Errorf("Memory.Len() = %v, want a record per season", memory.Len())
+	}
+
+	latest, err := memory.Get(ctx, id)
+	if err != nil || latest.Stats.Season != 30 {
+		t.Errorf("Memory.Get() = %v, %v, want season 30", latest, err)
+	}
+
+	history, err := memory.History(ctx, id)
+	if err != nil {
+		t.Fatalf("Memory.History() error = %v, want nil", err)
+	}
+
+	var seasons []int
+	for _, player := range history {
+		seasons = append(seasons, player.Stats.Season)
+	}
+	if !reflect.DeepEqual(seasons, []int{28, 29, 30}) {
+		t.Errorf("Memory.History() seasons = %v, want [28 29 30]", seasons)
+	}
+
+	// every season is a record of its own and can be filtered on
+	hits, err := memory.NearVector(ctx, Query{Vector: []float32{0, 0}, SchemaVersion: 1, Filter: Filter{{Property: "season", Operator: LessThan, Value: float64(30)}}, Limit: 10})
+	if err != nil || len(hits) != 2 || hits[0].Player.Stats.Season != 28 {
+		t.Errorf("Memory.NearVector(season < 30) = %v, %v, want seasons 28 and 29", hits, err)
+	}
+
+	if err := memory.Delete(ctx, []string{id}); err != nil {
+		t.Fatalf("Memory.Delete() error = %v, want nil", err)
+	}
+
+	if _, err := memory.History(ctx, id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Memory.History() after Delete() error = %v, want ErrNotFound", err)
+	}
+
+	if memory.Len() != 1 {
+		t.Errorf("Memory.Len() after Delete() = %v, want every season deleted", memory.Len())
+	}
+
+	// the first seasoned record replaces the one indexed without a season
+	memory.Upsert(ctx, []*Player{{ID: id, Vector: []float32{0, 1}, SchemaVersion: 1}})
+	memory.Upsert(ctx, []*Player{{ID: id, Stats: vectors.Player{Season: 30}, Vector: []float32{0, 3}, SchemaVersion: 1}})
+
+	if history, err := memory.History(ctx, id); err != nil || len(history) != 1 || history[0].Stats.Season != 30 {
+		t.Errorf("Memory.History() = %v, %v, want only season 30", history, err)
+	}
+}
+
+func TestMemoryScan(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemory()
+
+	players := []*Player{
+		{ID: "b", Stats: vectors.Player{Season: 30}},
+		{ID: "a", Stats: vectors.Player{Season: 30}},
+		{ID: "a", Stats: vectors.Player{Season: 9}},
+	}
+
+	if err := memory.Upsert(ctx, players); err != nil {
+		t.Fatalf("Memory.Upsert() error = %v, want nil", err)
+	}
+
+	if count, err := memory.Count(ctx); err != nil || count != 3 {
+		t.Errorf("Memory.Count() = %v, %v, want 3", count, err)
+	}
+
+	// records come back by player and then season, seasons ordered as numbers
+	want := []string{"a/9", "a/30", "b/30"}
+	var got []string
+	var cursor string
+	for pages := 0; ; pages++ {
+		page, next, err := memory.Scan(ctx, cursor, 2)
+		if err != nil {
+			t.Fatalf("Memory.Scan() error = %v, want nil", err)
+		}
+
+		for _, player := range page {
+			got = append(got, fmt.Sprintf("%s/%d", player.ID, player.Stats.Season))
+		}
+
+		if next == "" {
+			if pages != 1 {
+				t.Errorf("Memory.Scan() took %d pages, want 2", pages+1)
+			}
+			break
+		}
+		cursor = next
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Memory.Scan() = %v, want %v", got, want)
+	}
+}
+
+func TestMemoryNearVectorNegativeLimit(t *testing.T) {
+	memory := NewMemory()
+	if err := memory.Upsert(context.Background(), []*Player{{ID: "6844b415-aa94-43c9-8823-9389e4816902", Vector: []float32{1}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	hits, err := memory.NearVector(context.Background(), Query{Vector: []float32{1}, Limit: -40})
+	if err != nil || len(hits) != 0 {
+		t.Errorf("NearVector() with a negative limit = %v, %v, want no hits", hits, err)
+	}
+}
+
+func TestValidID(t *testing.T) {
+	tests := map[string]bool{
+		"6844b415-aa94-43c9-8823-9389e4816918": true,
+		"6844B415-AA94-43C9-8823-9389E4816918": true,
+		"":                                     false,
+		"roamer":                               false,
+		"6844b415aa9443c988239389e4816918":     false,
+		"6844b415-aa94-43c9-8823-9389e481691g": false,
+		"6844b415-aa94-43c9-8823_9389e4816918": false,
+	}
+
+	for id, want := range tests {
+		if got := ValidID(id); got != want {
+			t.Errorf("ValidID(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
+)
+
+// ErrNotFound is returned when no player is stored under the requested id
+var ErrNotFound = errors.New("store: player not found")
+
+// ErrUnavailable is returned without asking the store when it is known to be degraded
+var ErrUnavailable = errors.New("store: unavailable")
+
+// ErrRejected is returned when the store refuses records as invalid, writing them
+// again fails the same way
+var ErrRejected = errors.New("store: records rejected")
+
+// ValidID reports whether id is a player id, a UUID in its canonical text form
+func ValidID(id string) bool {
+	if len(id) != 36 {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		switch c := id[i]; {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if c != '-' {
+				return false
+			}
+		case '0' <= c && c <= '9', 'a' <= c && c <= 'f', 'A' <= c && c <= 'F':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Player is the record persisted for every indexed player and season, a player has a
+// record per season they were indexed for, keyed by ID and Stats.Season. ID is a UUID
+type Player struct {
+	ID            string
+	Stats         vectors.Player
+	Vector        []float32
+	SchemaVersion int
+	UpdatedAt     time.Time
+
+	// filterable metadata, not part of the vector
+	Platform string
+	Region   string
+	Language string
+	LastSeen time.Time
+}
+
+// Query describes a nearest neighbour search
+type Query struct {
+	Vector []float32
+	// SchemaVersion restricts the search to vectors built with the same schema
+	SchemaVersion int
+	Filter        Filter
+	Limit         int
+}
+
+// Hit is a single nearest neighbour result
+type Hit struct {
+	Player   *Player
+	Distance float32
+}
+
+// Store is implemented by every vector store backend the service can write to
+type Store interface {
+	// Upsert creates or replaces the given players, vector and properties
+	Upsert(ctx context.Context, players []*Player) error
+	// Delete removes every season of the given players, unknown ids are ignored
+	Delete(ctx context.Context, ids []string) error
+	// Get returns the latest season stored for the player including its vector, or ErrNotFound
+	Get(ctx context.Context, id string) (*Player, error)
+	// History returns every season stored for the player, oldest first, or ErrNotFound
+	History(ctx context.Context, id string) ([]*Player, error)
+	// Scan returns up to limit records after cursor in a stable order, and the cursor to
+	// continue from which is empty once every record has been returned. An empty cursor
+	// starts from the first record
+	Scan(ctx context.Context, cursor string, limit int) ([]*Player, string, error)
+	// Count returns the number of stored records
+	Count(ctx context.Context) (int, error)
+	// NearVector returns up to query.Limit records matching query.Filter closest to
+	// query.Vector, nearest first. A player can be hit once per stored season
+	NearVector(ctx context.Context, query Query) ([]Hit, error)
+}
@@ -0,0 +1,128 @@
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// MetadataKey is the request metadata naming the tenant a request is for
+const MetadataKey = "x-tenant"
+
+// Config describes a tenant, a player pool such as Ranked, Standard or a community
+// league that never mixes with the others
+type Config struct {
+	Name string `json:"name"`
+	// Collection is the logical collection the tenant's players are stored in
+	Collection string `json:"collection"`
+	// Class is the class a new collection starts out on, a versioned class of the
+	// collection when not set
+	Class string `json:"class,omitempty"`
+	// MaxRecords caps the records the tenant can store, 0 is unlimited
+	MaxRecords int `json:"maxRecords"`
+	// MaxBatchSize and MaxBatchWait configure the tenant's write pipeline
+	MaxBatchSize int      `json:"maxBatchSize"`
+	MaxBatchWait Duration `json:"maxBatchWait"`
+}
+
+// Configs are every tenant served, requests without a tenant go to Default
+type Configs struct {
+	Default string   `json:"default"`
+	Tenants []Config `json:"tenants"`
+}
+
+// Duration is a time.Duration written as a string like "5s" in JSON
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(raw []byte) error {
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return err
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+
+	d.Duration = parsed
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Load reads the tenants at path, fallback when there is no file
+func Load(path string, fallback Configs) (Configs, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fallback, fallback.Validate()
+	}
+
+	if err != nil {
+		return Configs{}, err
+	}
+
+	var configs Configs
+	if err := json.Unmarshal(raw, &configs); err != nil {
+		return Configs{}, fmt.Errorf("tenant: %s: %w", path, err)
+	}
+
+	return configs, configs.Validate()
+}
+
+// Validate checks that tenants have distinct names and collections, and that the
+// default tenant, when set, is one of them
+func (c Configs) Validate() error {
+	if len(c.Tenants) == 0 {
+		return errors.New("tenant: no tenants")
+	}
+
+	names := make(map[string]struct{}, len(c.Tenants))
+	collections := make(map[string]struct{}, len(c.Tenants))
+	for _, config := range c.Tenants {
+		if config.Name == "" || config.Collection == "" {
+			return fmt.Errorf("tenant: %q needs a name and a collection", config.Name)
+		}
+
+		if _, ok := names[config.Name]; ok {
+			return fmt.Errorf("tenant: %s is configured twice", config.Name)
+		}
+
+		// pools must never mix, not even by sharing a collection
+		if _, ok := collections[config.Collection]; ok {
+			return fmt.Errorf("tenant: collection %s is shared by several tenants", config.Collection)
+		}
+
+		if config.MaxRecords < 0 || config.MaxBatchSize < 0 || config.MaxBatchWait.Duration < 0 {
+			return fmt.Errorf("tenant: %s has a negative limit", config.Name)
+		}
+
+		names[config.Name] = struct{}{}
+		collections[config.Collection] = struct{}{}
+	}
+
+	if _, ok := names[c.Default]; c.Default != "" && !ok {
+		return fmt.Errorf("tenant: default tenant %s is not configured", c.Default)
+	}
+
+	return nil
+}
+
+// FromContext returns the tenant named by the incoming request metadata, empty when
+// the request names none
+func FromContext(ctx context.Context) string {
+	values := metadata.ValueFromIncomingContext(ctx, MetadataKey)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
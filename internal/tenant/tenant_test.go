@@ -0,0 +1,78 @@
+package tenant
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	fallback := Configs{Default: "default", Tenants: []Config{{Name: "default", Collection: "Players"}}}
+
+	configs, err := Load(filepath.Join(dir, "missing.json"), fallback)
+	if err != nil || configs.Default != "default" || len(configs.Tenants) != 1 {
+		t.Fatalf("Load() of a missing file = %v, %v, want the fallback", configs, err)
+	}
+
+	path := filepath.Join(dir, "tenants.json")
+	raw := `{"default": "ranked", "tenants": [
+		{"name": "ranked", "collection": "Ranked", "maxRecords": 1000, "maxBatchWait": "2s"},
+		{"name": "league", "collection": "League", "maxBatchSize": 10}
+	]}`
+	if err := os.WriteFile(path, []byte(raw), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	configs, err = Load(path, fallback)
+	if err != nil {
+		t.Fatalf("Load() error = %v, want nil", err)
+	}
+
+	if configs.Default != "ranked" || len(configs.Tenants) != 2 {
+		t.Fatalf("Load() = %v, want ranked and league", configs)
+	}
+
+	if ranked := configs.Tenants[0]; ranked.MaxRecords != 1000 || ranked.MaxBatchWait.Duration != 2*time.Second {
+		t.Errorf("ranked = %+v, want 1000 max records and a 2s batch wait", ranked)
+	}
+}
+
+func TestConfigsValidate(t *testing.T) {
+	tests := []struct {
+		testName string
+		configs  Configs
+		valid    bool
+	}{
+		{"single tenant", Configs{Tenants: []Config{{Name: "ranked", Collection: "Ranked"}}}, true},
+		{"no tenants", Configs{}, false},
+		{"no collection", Configs{Tenants: []Config{{Name: "ranked"}}}, false},
+		{"duplicate name", Configs{Tenants: []Config{{Name: "ranked", Collection: "Ranked"}, {Name: "ranked", Collection: "Other"}}}, false},
+		{"shared collection", Configs{Tenants: []Config{{Name: "ranked", Collection: "Players"}, {Name: "standard", Collection: "Players"}}}, false},
+		{"negative quota", Configs{Tenants: []Config{{Name: "ranked", Collection: "Ranked", MaxRecords: -1}}}, false},
+		{"unknown default", Configs{Default: "casual", Tenants: []Config{{Name: "ranked", Collection: "Ranked"}}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			if err := tt.configs.Validate(); (err == nil) != tt.valid {
+				t.Errorf("Validate() error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if got := FromContext(context.Background()); got != "" {
+		t.Errorf("FromContext() without metadata = %q, want empty", got)
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, "ranked"))
+	if got := FromContext(ctx); got != "ranked" {
+		t.Errorf("FromContext() = %q, want ranked", got)
+	}
+}
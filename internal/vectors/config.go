@@ -0,0 +1,159 @@
+package vectors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+)
+
+// Rank encodings of the player's tier
+const (
+	// RankEncodingOrdinal places tiers on a tier-distance table, the default
+	RankEncodingOrdinal = "ordinal"
+	// RankEncodingGroup one-hot encodes the tier group
+	RankEncodingGroup = "group"
+	// RankEncodingEmbedding looks tiers up in a learned embedding
+	RankEncodingEmbedding = "embedding"
+)
+
+// Playstyle encodings of the operator pick rates
+const (
+	// PlaystyleArchetypes reduces the roster to Playstyles, the default
+	PlaystyleArchetypes = "archetypes"
+	// PlaystyleOperators keeps a dimension per operator of the roster
+	PlaystyleOperators = "operators"
+)
+
+// Config derives a schema from Current with other encodings or normalization. Vectors
+// built with it are stamped with Version, which must not be taken by another layout.
+// Changing any of the encodings or the normalization, a retrained embedding or a refit
+// included, needs a new version
+type Config struct {
+	Version int    `json:"version"`
+	Rank    string `json:"rank,omitempty"`
+	// RankGaps replaces the gaps of DefaultRankOrdinal's tier-distance table, see
+	// NewRankOrdinal. RankEmbedding is the file of the learned embedding
+	RankGaps      map[string]float64 `json:"rankGaps,omitempty"`
+	RankEmbedding string             `json:"rankEmbedding,omitempty"`
+	Playstyle     string             `json:"playstyle,omitempty"`
+	// Normalization replaces the derived one, with a mean and standard deviation per
+	// dimension of the encodings, e.g. the refit reported by the Statistics RPC
+	Normalization *Normalization `json:"normalization,omitempty"`
+}
+
+// LoadConfig reads the config at path, nil when there is none
+func LoadConfig(path string) (*Config, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return nil, fmt.Errorf("vectors: %s: %w", path, err)
+	}
+
+	return &config, nil
+}
+
+// Schema returns the schema the config derives from Current
+func (c Config) Schema() (Schema, error) {
+	if c.Version <= 0 {
+		return Schema{}, fmt.Errorf("vectors: config: version must be positive")
+	}
+
+	schema := Current
+	schema.Version = c.Version
+
+	rank, err := c.rankEncoding()
+	if err != nil {
+		return Schema{}, err
+	}
+	schema = schema.withFeature(Feature{Name: "rank", Source: "Tier", Transform: rank})
+
+	switch c.Playstyle {
+	case "", PlaystyleArchetypes:
+		schema = schema.withFeature(Feature{Name: "playstyle", Source: "OperatorPickRates", Transform: PlaystyleHistogram})
+	case PlaystyleOperators:
+		schema = schema.withFeature(Feature{Name: "playstyle", Source: "OperatorPickRates", Transform: OperatorHistogram})
+	default:
+		return Schema{}, fmt.Errorf("vectors: config: unknown playstyle encoding %q", c.Playstyle)
+	}
+
+	if c.Normalization != nil {
+		schema.Normalization = Normalization{
+			Mean:   append([]float32(nil), c.Normalization.Mean...),
+			StdDev: append([]float32(nil), c.Normalization.StdDev...),
+		}
+	}
+
+	return schema, schema.Validate()
+}
+
+func (c Config) rankEncoding() (Transform, error) {
+	switch c.Rank {
+	case "", RankEncodingOrdinal:
+		if c.RankGaps == nil {
+			return DefaultRankOrdinal, nil
+		}
+		return NewRankOrdinal(c.RankGaps), nil
+	case RankEncodingGroup:
+		return RankGroup(RankGroups), nil
+	case RankEncodingEmbedding:
+		file, err := os.Open(c.RankEmbedding)
+		if err != nil {
+			return nil, fmt.Errorf("vectors: config: %w", err)
+		}
+		defer file.Close()
+
+		return LoadRankEmbedding(file)
+	}
+	return nil, fmt.Errorf("vectors: config: unknown rank encoding %q", c.Rank)
+}
+
+// Register adds schema to Schemas. A version already taken by another layout is
+// refused, the stored vectors of it would be misread
+func Register(schema Schema) error {
+	if registered, ok := Schemas[schema.Version]; ok && !reflect.DeepEqual(registered, schema) {
+		return fmt.Errorf("vectors: schema v%d is already taken by another layout", schema.Version)
+	}
+
+	Schemas[schema.Version] = schema
+	return nil
+}
+
+// withFeature returns a copy of the schema with the feature of the same name replaced.
+// Its normalization carries over when the dimension is the same, other encodings are
+// passed through as is
+func (s Schema) withFeature(replacement Feature) Schema {
+	features := make([]Feature, 0, len(s.Features))
+	normalization := Normalization{}
+
+	offset := 0
+	for _, feature := range s.Features {
+		dimension := feature.Transform.Dimension()
+		mean, stdDev := s.Normalization.Mean[offset:offset+dimension], s.Normalization.StdDev[offset:offset+dimension]
+		offset += dimension
+
+		if feature.Name == replacement.Name {
+			feature = replacement
+			if feature.Transform.Dimension() != dimension {
+				mean, stdDev = make([]float32, feature.Transform.Dimension()), make([]float32, feature.Transform.Dimension())
+			}
+		}
+
+		features = append(features, feature)
+		normalization.Mean = append(normalization.Mean, mean...)
+		normalization.StdDev = append(normalization.StdDev, stdDev...)
+	}
+
+	s.Features = features
+	s.Normalization = normalization
+	return s
+}
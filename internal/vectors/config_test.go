@@ -0,0 +1,138 @@
+package vectors
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConfigSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(path, []byte(`{"version": 90, "playstyle": "operators"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v, want nil", err)
+	}
+
+	schema, err := config.Schema()
+	if err != nil {
+		t.Fatalf("Config.Schema() error = %v, want nil", err)
+	}
+
+	// a dimension per operator instead of per archetype, the rest carries over
+	if want := Current.Dimension() - len(Playstyles) + len(Operators); schema.Version != 90 || schema.Dimension() != want {
+		t.Errorf("Config.Schema() = v%d with %d dimensions, want v90 with %d", schema.Version, schema.Dimension(), want)
+	}
+
+	if schema.Normalization.Mean[0] != Current.Normalization.Mean[0] {
+		t.Errorf("Config.Schema() normalization = %v, want Current's for the stats", schema.Normalization.Mean[:4])
+	}
+
+	if _, err := (Config{Version: 91, Playstyle: "mains"}).Schema(); err == nil {
+		t.Errorf("Config.Schema() error = nil for an unknown encoding, want error")
+	}
+
+	if missing, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json")); missing != nil || err != nil {
+		t.Errorf("LoadConfig() = %v, %v without a file, want nil", missing, err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if err := Register(SchemaV3); err != nil {
+		t.Errorf("Register() error = %v for the same layout, want nil", err)
+	}
+
+	taken := SchemaV4
+	taken.Version = SchemaV3.Version
+	if err := Register(taken); err == nil {
+		t.Errorf("Register() error = nil for a taken version, want error")
+	}
+}
+
+func TestConfigRankEncoding(t *testing.T) {
+	embedding := filepath.Join(t.TempDir(), "rank.json")
+	if err := os.WriteFile(embedding, []byte(`{"gold": [0.5, 0.5, 0], "champion": [1, 1, 1]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	gold := Player{Rank: 18}
+	testCases := []struct {
+		config    Config
+		dimension int
+		want      []float64
+	}{
+		{Config{Version: 90}, 1, []float64{DefaultRankOrdinal["gold_3"]}},
+		{Config{Version: 90, RankGaps: map[string]float64{"gold": 10}}, 1, []float64{NewRankOrdinal(map[string]float64{"gold": 10})["gold_3"]}},
+		{Config{Version: 90, Rank: RankEncodingGroup}, len(RankGroups), []float64{0, 0, 0, 1, 0, 0, 0, 0}},
+		{Config{Version: 90, Rank: RankEncodingEmbedding, RankEmbedding: embedding}, 3, []float64{0.5, 0.5, 0}},
+	}
+
+	for _, testCase := range testCases {
+		schema, err := testCase.config.Schema()
+		if err != nil {
+			t.Fatalf("Config.Schema() error = %v, want nil", err)
+		}
+
+		if got := schema.Dimension() - Current.Dimension() + 1; got != testCase.dimension {
+			t.Errorf("rank dimension of %+v = %d, want %d", testCase.config, got, testCase.dimension)
+		}
+
+		// rank follows level and kost
+		values := schema.Values(gold)
+		if got := values[2 : 2+testCase.dimension]; !reflect.DeepEqual(got, testCase.want) {
+			t.Errorf("rank of %+v = %v, want %v", testCase.config, got, testCase.want)
+		}
+	}
+
+	if _, err := (Config{Version: 90, Rank: RankEncodingEmbedding}).Schema(); err == nil {
+		t.Errorf("Config.Schema() error = nil without an embedding file, want error")
+	}
+}
+
+func TestConfigNormalization(t *testing.T) {
+	dimension := Current.Dimension()
+	mean, stdDev := make([]float32, dimension), make([]float32, dimension)
+	for i := range mean {
+		mean[i], stdDev[i] = float32(i), 2
+	}
+
+	path := filepath.Join(t.TempDir(), "schema.json")
+	raw, err := json.Marshal(Config{Version: 90, Normalization: &Normalization{Mean: mean, StdDev: stdDev}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v, want nil", err)
+	}
+
+	schema, err := config.Schema()
+	if err != nil {
+		t.Fatalf("Config.Schema() error = %v, want nil", err)
+	}
+
+	if !reflect.DeepEqual(schema.Normalization, Normalization{Mean: mean, StdDev: stdDev}) {
+		t.Errorf("Config.Schema() normalization = %+v, want the config's", schema.Normalization)
+	}
+
+	// the encodings keep Current's, the vectors only differ by the normalization
+	player := Player{Level: 120, Kost: 0.6, Rank: 18, Season: 30}
+	if got, want := schema.Vector(player), (Normalization{Mean: mean, StdDev: stdDev}).Apply(Current.Raw(player)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Schema.Vector() = %v, want %v", got, want)
+	}
+
+	// a refit of another encoding's dimension is refused
+	short := Config{Version: 91, Normalization: &Normalization{Mean: mean[1:], StdDev: stdDev[1:]}}
+	if _, err := short.Schema(); err == nil {
+		t.Errorf("Config.Schema() error = nil for a normalization of %d dimensions, want error", dimension-1)
+	}
+}
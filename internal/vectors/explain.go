@@ -0,0 +1,44 @@
+package vectors
+
+// Contribution is the part of a squared euclidean distance owed to a single feature
+type Contribution struct {
+	Feature  string
+	Distance float32
+	// Share of the total distance, the shares of a breakdown add up to one
+	Share float32
+}
+
+// Explain breaks the l2-squared distance between two vectors of the schema down per
+// dimension. The vectors are compared as stored, so the breakdown reflects the
+// normalization they were built with
+func (s Schema) Explain(query, candidate []float32) []Contribution {
+	names := s.Names()
+
+	contributions := make([]Contribution, 0, len(query))
+
+	var total float32
+	for i := range query {
+		if i >= len(candidate) {
+			break
+		}
+
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+
+		diff := query[i] - candidate[i]
+		contributions = append(contributions, Contribution{Feature: name, Distance: diff * diff})
+		total += diff * diff
+	}
+
+	if total == 0 {
+		return contributions
+	}
+
+	for i := range contributions {
+		contributions[i].Share = contributions[i].Distance / total
+	}
+
+	return contributions
+}
@@ -0,0 +1,41 @@
+package vectors
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	testCases := []struct {
+		query     []float32
+		candidate []float32
+		want      []Contribution
+	}{
+		{
+			[]float32{0, 1, 2, 3},
+			[]float32{0, 1, 4, 2},
+			[]Contribution{
+				{Feature: "level", Distance: 0, Share: 0},
+				{Feature: "kost", Distance: 0, Share: 0},
+				{Feature: "rank", Distance: 4, Share: 0.8},
+				{Feature: "rank_points", Distance: 1, Share: 0.2},
+			},
+		},
+		{
+			[]float32{1, 1},
+			[]float32{1, 1},
+			[]Contribution{
+				{Feature: "level", Distance: 0, Share: 0},
+				{Feature: "kost", Distance: 0, Share: 0},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		got := SchemaV1.Explain(testCase.query, testCase.candidate)
+
+		if !reflect.DeepEqual(got, testCase.want) {
+			t.Errorf("Explain(%v, %v) = %v, want %v", testCase.query, testCase.candidate, got, testCase.want)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package vectors
+
+// Normalization rescales every feature of a vector to zero mean and unit variance so
+// rank points (thousands) don't drown out KOST (around one) in the distance
+type Normalization struct {
+	Mean   []float32 `json:"mean"`
+	StdDev []float32 `json:"stdDev"`
+}
+
+// Apply returns a normalized copy of vector, features without parameters are passed through
+func (n Normalization) Apply(vector []float32) []float32 {
+	normalized := make([]float32, len(vector))
+
+	for i, val := range vector {
+		if i >= len(n.Mean) || i >= len(n.StdDev) || n.StdDev[i] == 0 {
+			normalized[i] = val
+			continue
+		}
+
+		normalized[i] = (val - n.Mean[i]) / n.StdDev[i]
+	}
+
+	return normalized
+}
@@ -0,0 +1,30 @@
+package vectors
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizationApply(t *testing.T) {
+	normalization := Normalization{
+		Mean:   []float32{100, 0.5},
+		StdDev: []float32{50, 0},
+	}
+
+	testCases := []struct {
+		vector []float32
+		want   []float32
+	}{
+		{[]float32{100, 0.5}, []float32{0, 0.5}},
+		{[]float32{200, 0.7}, []float32{2, 0.7}},
+		{[]float32{50, 1, 3}, []float32{-1, 1, 3}},
+	}
+
+	for _, testCase := range testCases {
+		got := normalization.Apply(testCase.vector)
+
+		if !reflect.DeepEqual(got, testCase.want) {
+			t.Errorf("Normalization.Apply(%v) = %v, want %v", testCase.vector, got, testCase.want)
+		}
+	}
+}
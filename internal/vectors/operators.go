@@ -0,0 +1,102 @@
+package vectors
+
+import (
+	"math"
+	"strings"
+)
+
+// Operators is the operator roster pick rates are keyed by, lower case without accents
+var Operators = []string{
+	// attackers
+	"sledge", "thatcher", "ash", "thermite", "twitch", "montagne", "glaz", "fuze",
+	"blitz", "iq", "buck", "blackbeard", "capitao", "hibana", "jackal", "ying",
+	"zofia", "dokkaebi", "lion", "finka", "maverick", "nomad", "gridlock", "nokk",
+	"amaru", "kali", "iana", "ace", "zero", "flores", "osa", "sens", "grim", "brava",
+	"ram", "deimos", "striker",
+	// defenders
+	"smoke", "mute", "castle", "pulse", "doc", "rook", "kapkan", "tachanka", "jager",
+	"bandit", "frost", "valkyrie", "caveira", "echo", "mira", "lesion", "ela", "vigil",
+	"maestro", "alibi", "clash", "kaid", "mozzie", "warden", "goyo", "wamai", "oryx",
+	"melusi", "aruni", "thunderbird", "thorn", "azami", "solis", "fenrir", "tubarao",
+	"sentry", "skopos",
+}
+
+// Playstyles are the archetypes the operator roster is reduced to
+var Playstyles = []string{
+	"hard_breach", "utility_clear", "entry", "attack_intel", "flank_watch",
+	"anti_breach", "anchor", "roam", "defense_intel", "trap",
+}
+
+// OperatorPlaystyles maps every operator of the roster onto its archetype
+var OperatorPlaystyles = map[string]string{
+	"thermite": "hard_breach", "hibana": "hard_breach", "maverick": "hard_breach", "ace": "hard_breach",
+	"thatcher": "utility_clear", "twitch": "utility_clear", "kali": "utility_clear", "flores": "utility_clear",
+	"brava": "utility_clear", "zero": "utility_clear", "capitao": "utility_clear", "fuze": "utility_clear",
+	"sledge": "entry", "ash": "entry", "zofia": "entry", "ram": "entry", "buck": "entry", "blitz": "entry",
+	"montagne": "entry", "glaz": "entry", "blackbeard": "entry", "ying": "entry", "striker": "entry",
+	"finka": "entry", "amaru": "entry", "iana": "entry", "osa": "entry", "sens": "entry",
+	"lion": "attack_intel", "dokkaebi": "attack_intel", "jackal": "attack_intel", "iq": "attack_intel",
+	"deimos": "attack_intel", "grim": "attack_intel", "nokk": "attack_intel",
+	"gridlock": "flank_watch", "nomad": "flank_watch",
+	"mute": "anti_breach", "bandit": "anti_breach", "kaid": "anti_breach", "jager": "anti_breach",
+	"wamai": "anti_breach", "mozzie": "anti_breach", "sentry": "anti_breach",
+	"smoke": "anchor", "rook": "anchor", "doc": "anchor", "castle": "anchor", "tachanka": "anchor",
+	"goyo": "anchor", "thunderbird": "anchor", "azami": "anchor", "tubarao": "anchor", "warden": "anchor", "clash": "anchor",
+	"caveira": "roam", "vigil": "roam", "oryx": "roam", "solis": "roam", "fenrir": "roam",
+	"valkyrie": "defense_intel", "echo": "defense_intel", "maestro": "defense_intel", "pulse": "defense_intel",
+	"mira": "defense_intel", "alibi": "defense_intel", "skopos": "defense_intel", "aruni": "defense_intel",
+	"kapkan": "trap", "frost": "trap", "lesion": "trap", "ela": "trap", "melusi": "trap", "thorn": "trap",
+}
+
+// Histogram encodes a map of weights, like operator pick rates, as a histogram over
+// Bins that sums to one. Group optionally reduces the keys onto fewer bins, keys
+// without a group are their own bin and keys outside every bin are dropped. A player
+// without any weights encodes as all zeros
+type Histogram struct {
+	Bins  []string
+	Group map[string]string
+}
+
+func (h Histogram) Apply(value interface{}) []float64 {
+	encoded := make([]float64, len(h.Bins))
+	weights, _ := value.(map[string]float64)
+
+	bins := make(map[string]int, len(h.Bins))
+	for i, bin := range h.Bins {
+		bins[bin] = i
+	}
+
+	var total float64
+	for key, weight := range weights {
+		key = strings.ToLower(key)
+		if group, ok := h.Group[key]; ok {
+			key = group
+		}
+
+		bin, ok := bins[key]
+		if !ok || weight <= 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+			continue
+		}
+
+		encoded[bin] += weight
+		total += weight
+	}
+
+	if total == 0 {
+		return encoded
+	}
+
+	for i := range encoded {
+		encoded[i] /= total
+	}
+	return encoded
+}
+func (h Histogram) Dimension() int   { return len(h.Bins) }
+func (h Histogram) Labels() []string { return h.Bins }
+
+// OperatorHistogram keeps a bin per operator of the roster
+var OperatorHistogram = Histogram{Bins: Operators}
+
+// PlaystyleHistogram reduces the roster to a bin per archetype, two players maining
+// different hard breachers still play alike
+var PlaystyleHistogram = Histogram{Bins: Playstyles, Group: OperatorPlaystyles}
@@ -0,0 +1,65 @@
+package vectors
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOperatorPlaystyles(t *testing.T) {
+	playstyles := make(map[string]struct{}, len(Playstyles))
+	for _, playstyle := range Playstyles {
+		playstyles[playstyle] = struct{}{}
+	}
+
+	for _, operator := range Operators {
+		playstyle, ok := OperatorPlaystyles[operator]
+		if !ok {
+			t.Errorf("operator %s has no playstyle", operator)
+			continue
+		}
+
+		if _, ok := playstyles[playstyle]; !ok {
+			t.Errorf("operator %s has unknown playstyle %s", operator, playstyle)
+		}
+	}
+
+	if len(OperatorPlaystyles) != len(Operators) {
+		t.Errorf("OperatorPlaystyles has %d operators, roster has %d", len(OperatorPlaystyles), len(Operators))
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	histogram := Histogram{Bins: []string{"breach", "roam"}, Group: map[string]string{"thermite": "breach", "hibana": "breach", "vigil": "roam"}}
+
+	testCases := []struct {
+		name    string
+		weights map[string]float64
+		want    []float64
+	}{
+		{"empty", nil, []float64{0, 0}},
+		{"grouped", map[string]float64{"thermite": 0.2, "Hibana": 0.4, "vigil": 0.2}, []float64{0.75, 0.25}},
+		{"bin key", map[string]float64{"roam": 1}, []float64{0, 1}},
+		{"unknown and negative dropped", map[string]float64{"sledge": 0.5, "vigil": -1, "thermite": 0.1}, []float64{1, 0}},
+	}
+
+	for _, testCase := range testCases {
+		got := histogram.Apply(testCase.weights)
+		for i := range got {
+			if diff := got[i] - testCase.want[i]; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("%s: Histogram.Apply() = %v, want %v", testCase.name, got, testCase.want)
+				break
+			}
+		}
+	}
+
+	// mains of two hard breachers play alike once reduced to playstyles
+	thermite := PlaystyleHistogram.Apply(map[string]float64{"thermite": 0.6, "smoke": 0.4})
+	hibana := PlaystyleHistogram.Apply(map[string]float64{"hibana": 0.6, "rook": 0.4})
+	if !reflect.DeepEqual(thermite, hibana) {
+		t.Errorf("PlaystyleHistogram.Apply() = %v and %v, want equal", thermite, hibana)
+	}
+
+	if len(OperatorHistogram.Apply(map[string]float64{"thermite": 1})) != len(Operators) {
+		t.Errorf("OperatorHistogram.Dimension() = %d, want %d", OperatorHistogram.Dimension(), len(Operators))
+	}
+}
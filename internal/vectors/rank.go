@@ -0,0 +1,196 @@
+package vectors
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// RankGroups are the ranked tier groups from lowest to highest, across every season
+var RankGroups = []string{"copper", "bronze", "silver", "gold", "platinum", "emerald", "diamond", "champion"}
+
+// Tier is a ranked tier, Division counts down towards the next group and is 0 for
+// groups without divisions. The zero Tier is unranked
+type Tier struct {
+	Group    string
+	Division int
+}
+
+// Name is the key tiers are looked up by in encoding tables, e.g. gold_3 or champion
+func (t Tier) Name() string {
+	switch {
+	case t.Group == "":
+		return "unranked"
+	case t.Division == 0:
+		return t.Group
+	}
+	return fmt.Sprintf("%s_%d", t.Group, t.Division)
+}
+
+// RankSystem is the tier ladder used from FirstSeason on. A player's Rank is the
+// index into Tiers starting at 1, 0 is unranked
+type RankSystem struct {
+	Name        string
+	FirstSeason int
+	Tiers       []Tier
+}
+
+// RankSystems are the ranked ladders Siege has used, ordered by the season they were
+// introduced in. Seasons are numbered from Y1S1 Black Ice as 1
+var RankSystems = []RankSystem{
+	{Name: "original", FirstSeason: 1, Tiers: ladder(map[string]int{"copper": 4, "bronze": 4, "silver": 4, "gold": 4, "platinum": 3, "diamond": 0})},
+	{Name: "ember_rise", FirstSeason: 15, Tiers: ladder(map[string]int{"copper": 5, "bronze": 5, "silver": 5, "gold": 3, "platinum": 3, "diamond": 0, "champion": 0})},
+	{Name: "ranked_2", FirstSeason: 28, Tiers: ladder(map[string]int{"copper": 5, "bronze": 5, "silver": 5, "gold": 5, "platinum": 5, "diamond": 5, "champion": 0})},
+	{Name: "emerald", FirstSeason: 30, Tiers: ladder(map[string]int{"copper": 5, "bronze": 5, "silver": 5, "gold": 5, "platinum": 5, "emerald": 5, "diamond": 5, "champion": 0})},
+}
+
+// RankSystemFor returns the ladder of a season, the current ladder for 0
+func RankSystemFor(season int) RankSystem {
+	if season <= 0 {
+		return RankSystems[len(RankSystems)-1]
+	}
+
+	i := sort.Search(len(RankSystems), func(i int) bool { return RankSystems[i].FirstSeason > season })
+	if i == 0 {
+		return RankSystems[0]
+	}
+	return RankSystems[i-1]
+}
+
+// RankTier resolves a rank index of a season to its tier, ranks outside the
+// season's ladder are unranked
+func RankTier(season, rank int) Tier {
+	tiers := RankSystemFor(season).Tiers
+	if rank <= 0 || rank > len(tiers) {
+		return Tier{}
+	}
+	return tiers[rank-1]
+}
+
+// ladder lists the tiers of the groups in RankGroups order, divisions counting down
+func ladder(divisions map[string]int) []Tier {
+	var tiers []Tier
+	for _, group := range RankGroups {
+		count, ok := divisions[group]
+		if !ok {
+			continue
+		}
+
+		if count == 0 {
+			tiers = append(tiers, Tier{Group: group})
+			continue
+		}
+
+		for division := count; division >= 1; division-- {
+			tiers = append(tiers, Tier{Group: group, Division: division})
+		}
+	}
+	return tiers
+}
+
+// RankOrdinal encodes a tier as its position on a single scale, looked up by tier
+// name. Unknown tiers, unranked included, encode as 0
+type RankOrdinal map[string]float64
+
+// NewRankOrdinal builds the tier-distance table of the current ladder. Divisions are
+// 1 apart and gaps sets how far the first division of a group is from the last of the
+// group below, 1 when not set. Groups that had no divisions in earlier seasons sit in
+// the middle of their divisions
+func NewRankOrdinal(gaps map[string]float64) RankOrdinal {
+	positions := RankOrdinal{}
+
+	var position float64
+	var group string
+	var divisions []float64
+	for _, tier := range RankSystemFor(0).Tiers {
+		if tier.Group != group {
+			if gap, ok := gaps[tier.Group]; ok {
+				position += gap
+			} else {
+				position++
+			}
+			group, divisions = tier.Group, nil
+		} else {
+			position++
+		}
+
+		positions[tier.Name()] = position
+		divisions = append(divisions, position)
+		positions[tier.Group] = (divisions[0] + position) / 2
+	}
+
+	return positions
+}
+
+// DefaultRankOrdinal spreads the top of the ladder out, a few hundred players separate
+// Diamond I from Champion while Copper V and IV are a single bad evening apart
+var DefaultRankOrdinal = NewRankOrdinal(map[string]float64{"platinum": 1.5, "emerald": 2, "diamond": 2.5, "champion": 4})
+
+func (r RankOrdinal) Apply(value interface{}) []float64 {
+	tier, _ := value.(Tier)
+	return []float64{r[tier.Name()]}
+}
+func (r RankOrdinal) Dimension() int { return 1 }
+
+// RankGroup one-hot encodes the group of a tier and ignores the division, unranked
+// encodes as all zeros
+type RankGroup []string
+
+func (r RankGroup) Apply(value interface{}) []float64 {
+	tier, _ := value.(Tier)
+	return OneHot(r).Apply(tier.Group)
+}
+func (r RankGroup) Dimension() int   { return len(r) }
+func (r RankGroup) Labels() []string { return r }
+
+// RankEmbedding encodes a tier with a learned vector, looked up by tier name and
+// falling back to the tier's group. Tiers without a vector encode as all zeros
+type RankEmbedding struct {
+	Size    int
+	Vectors map[string][]float64
+}
+
+// LoadRankEmbedding reads a JSON object of vectors by tier name, as exported by the
+// offline training job. Every vector must have the same length
+func LoadRankEmbedding(r io.Reader) (RankEmbedding, error) {
+	var embedding RankEmbedding
+	if err := json.NewDecoder(r).Decode(&embedding.Vectors); err != nil {
+		return RankEmbedding{}, fmt.Errorf("vectors: rank embedding: %w", err)
+	}
+
+	for name, vector := range embedding.Vectors {
+		if embedding.Size == 0 {
+			embedding.Size = len(vector)
+		}
+
+		if len(vector) != embedding.Size || embedding.Size == 0 {
+			return RankEmbedding{}, fmt.Errorf("vectors: rank embedding: %s has %d dimensions, want %d", name, len(vector), embedding.Size)
+		}
+
+		for _, val := range vector {
+			if math.IsNaN(val) || math.IsInf(val, 0) {
+				return RankEmbedding{}, fmt.Errorf("vectors: rank embedding: %s is not finite", name)
+			}
+		}
+	}
+
+	return embedding, nil
+}
+
+func (r RankEmbedding) Apply(value interface{}) []float64 {
+	tier, _ := value.(Tier)
+
+	vector, ok := r.Vectors[tier.Name()]
+	if !ok {
+		vector, ok = r.Vectors[tier.Group]
+	}
+
+	encoded := make([]float64, r.Size)
+	if ok {
+		copy(encoded, vector)
+	}
+	return encoded
+}
+func (r RankEmbedding) Dimension() int { return r.Size }
@@ -0,0 +1,113 @@
+package vectors
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRankTier(t *testing.T) {
+	testCases := []struct {
+		season, rank int
+		want         string
+	}{
+		{0, 0, "unranked"},
+		{0, 1, "copper_5"},
+		{0, 18, "gold_3"},
+		{0, 30, "emerald_1"},
+		{0, 36, "champion"},
+		{0, 37, "unranked"},
+		{3, 1, "copper_4"},
+		{14, 20, "diamond"},
+		{15, 16, "gold_3"},
+		{15, 23, "champion"},
+		{28, 30, "diamond_1"},
+		{30, 30, "emerald_1"},
+		{99, 31, "diamond_5"},
+	}
+
+	for _, testCase := range testCases {
+		if got := RankTier(testCase.season, testCase.rank).Name(); got != testCase.want {
+			t.Errorf("RankTier(%d, %d) = %s, want %s", testCase.season, testCase.rank, got, testCase.want)
+		}
+	}
+
+	for _, system := range RankSystems {
+		if last := system.Tiers[len(system.Tiers)-1].Group; last != "diamond" && last != "champion" {
+			t.Errorf("%s ladder ends with %s", system.Name, last)
+		}
+	}
+}
+
+func TestRankOrdinal(t *testing.T) {
+	position := func(name string) float64 { return DefaultRankOrdinal[name] }
+
+	if got := position("copper_4") - position("copper_5"); got != 1 {
+		t.Errorf("copper_5 -> copper_4 = %v, want 1", got)
+	}
+
+	if got := position("champion") - position("diamond_1"); got != 4 {
+		t.Errorf("diamond_1 -> champion = %v, want 4", got)
+	}
+
+	// the single division diamond of the original ladder sits mid diamond
+	if got, want := position("diamond"), (position("diamond_5")+position("diamond_1"))/2; got != want {
+		t.Errorf("diamond = %v, want %v", got, want)
+	}
+
+	ordinal := NewRankOrdinal(nil)
+	if got := ordinal.Apply(RankTier(0, 36)); !reflect.DeepEqual(got, []float64{36}) {
+		t.Errorf("NewRankOrdinal(nil).Apply(champion) = %v, want [36]", got)
+	}
+
+	if got := ordinal.Apply(Tier{}); !reflect.DeepEqual(got, []float64{0}) {
+		t.Errorf("RankOrdinal.Apply(unranked) = %v, want [0]", got)
+	}
+
+	// a diamond of the original ladder lands next to a current diamond, not next to gold
+	original := SchemaV4.Raw(Player{Season: 5, Rank: 20})[2]
+	current := SchemaV4.Raw(Player{Rank: 33})[2]
+	if original != current {
+		t.Errorf("SchemaV4 rank of season 5 diamond = %v, current diamond_3 = %v, want equal", original, current)
+	}
+}
+
+func TestRankGroup(t *testing.T) {
+	groups := RankGroup(RankGroups)
+
+	got := groups.Apply(RankTier(0, 18))
+	want := []float64{0, 0, 0, 1, 0, 0, 0, 0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RankGroup.Apply(gold_3) = %v, want %v", got, want)
+	}
+
+	if got := groups.Apply(Tier{}); !reflect.DeepEqual(got, make([]float64, len(RankGroups))) {
+		t.Errorf("RankGroup.Apply(unranked) = %v, want zeros", got)
+	}
+}
+
+func TestRankEmbedding(t *testing.T) {
+	embedding, err := LoadRankEmbedding(strings.NewReader(`{"gold": [0.5, 0.1], "champion": [2, 1.5]}`))
+	if err != nil {
+		t.Fatalf("LoadRankEmbedding() error = %v, want nil", err)
+	}
+
+	testCases := []struct {
+		tier Tier
+		want []float64
+	}{
+		{RankTier(0, 36), []float64{2, 1.5}},
+		{RankTier(0, 18), []float64{0.5, 0.1}},
+		{RankTier(0, 1), []float64{0, 0}},
+	}
+
+	for _, testCase := range testCases {
+		if got := embedding.Apply(testCase.tier); !reflect.DeepEqual(got, testCase.want) {
+			t.Errorf("RankEmbedding.Apply(%s) = %v, want %v", testCase.tier.Name(), got, testCase.want)
+		}
+	}
+
+	if _, err := LoadRankEmbedding(strings.NewReader(`{"gold": [0.5], "champion": [2, 1.5]}`)); err == nil {
+		t.Errorf("LoadRankEmbedding() error = nil for mismatched sizes, want error")
+	}
+}
@@ -0,0 +1,184 @@
+package vectors
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Transform turns the value of a Player field into the floats of a feature
+type Transform interface {
+	Apply(value interface{}) []float64
+	Dimension() int
+}
+
+// Feature declares a slice of the vector built from a single Player field
+type Feature struct {
+	Name string
+	// Source is the name of the Player field or method the feature is read from, one
+	// of the accessors in sources
+	Source    string
+	Transform Transform
+}
+
+// Schema declares how a Player is turned into a vector. Vectors are only comparable
+// within a schema, every stored vector is stamped with the version it was built with
+type Schema struct {
+	Version       int
+	Features      []Feature
+	Normalization Normalization
+}
+
+// Dimension is the length of the vectors built by the schema
+func (s Schema) Dimension() int {
+	dimension := 0
+	for _, feature := range s.Features {
+		dimension += feature.Transform.Dimension()
+	}
+	return dimension
+}
+
+// Names returns a name per vector dimension, features spanning several dimensions
+// get one name per dimension from their transform
+func (s Schema) Names() []string {
+	names := make([]string, 0, s.Dimension())
+	for _, feature := range s.Features {
+		if labelled, ok := feature.Transform.(interface{ Labels() []string }); ok {
+			for _, label := range labelled.Labels() {
+				names = append(names, feature.Name+":"+label)
+			}
+			continue
+		}
+
+		names = append(names, feature.Name)
+	}
+	return names
+}
+
+// Raw returns the transformed but not yet normalized vector of the player
+func (s Schema) Raw(player Player) []float32 {
+	vector := make([]float32, 0, s.Dimension())
+
+	for _, feature := range s.Features {
+		value := sourceValue(player, feature.Source)
+		for _, val := range feature.Transform.Apply(value) {
+			vector = append(vector, float32(val))
+		}
+	}
+
+	return vector
+}
+
+// Values returns the untransformed value behind every dimension, used to report
+// differences in units people know. Non numeric sources like tiers report their encoding
+func (s Schema) Values(player Player) []float64 {
+	values := make([]float64, 0, s.Dimension())
+
+	for _, feature := range s.Features {
+		value := sourceValue(player, feature.Source)
+		if _, ok := toNumber(value); ok && feature.Transform.Dimension() == 1 {
+			values = append(values, toFloat(value))
+			continue
+		}
+
+		values = append(values, feature.Transform.Apply(value)...)
+	}
+
+	return values
+}
+
+// Vector returns the normalized vector that is stored and queried
+func (s Schema) Vector(player Player) []float32 {
+	return s.Normalization.Apply(s.Raw(player))
+}
+
+// Validate checks that every feature reads an existing Player field or method
+func (s Schema) Validate() error {
+	for _, feature := range s.Features {
+		if _, ok := sources[feature.Source]; !ok {
+			return fmt.Errorf("vectors: schema v%d feature %s: unknown Player field %s", s.Version, feature.Name, feature.Source)
+		}
+	}
+
+	if len(s.Normalization.Mean) != s.Dimension() || len(s.Normalization.StdDev) != s.Dimension() {
+		return fmt.Errorf("vectors: schema v%d: normalization doesn't match dimension %d", s.Version, s.Dimension())
+	}
+
+	return nil
+}
+
+// Identity passes a numeric field through
+type Identity struct{}
+
+func (Identity) Apply(value interface{}) []float64 { return []float64{toFloat(value)} }
+func (Identity) Dimension() int                    { return 1 }
+
+// Log1p compresses long tailed counts like matches or hours played
+type Log1p struct{}
+
+func (Log1p) Apply(value interface{}) []float64 {
+	return []float64{math.Log1p(math.Max(toFloat(value), 0))}
+}
+func (Log1p) Dimension() int { return 1 }
+
+// OneHot encodes a categorical string field, unknown values encode as all zeros
+type OneHot []string
+
+func (o OneHot) Apply(value interface{}) []float64 {
+	encoded := make([]float64, len(o))
+	for i, category := range o {
+		if fmt.Sprint(value) == category {
+			encoded[i] = 1
+		}
+	}
+	return encoded
+}
+func (o OneHot) Dimension() int   { return len(o) }
+func (o OneHot) Labels() []string { return o }
+
+// sources reads every Player field and method a feature can be built from, resolved
+// once instead of by reflection on every vector
+var sources = map[string]func(Player) interface{}{
+	"Level":             func(p Player) interface{} { return p.Level },
+	"Kost":              func(p Player) interface{} { return p.Kost },
+	"Rank":              func(p Player) interface{} { return p.Rank },
+	"RankPoints":        func(p Player) interface{} { return p.RankPoints },
+	"Season":            func(p Player) interface{} { return p.Season },
+	"WinRate":           func(p Player) interface{} { return p.WinRate },
+	"HeadshotRate":      func(p Player) interface{} { return p.HeadshotRate },
+	"KD":                func(p Player) interface{} { return p.KD },
+	"MatchesPlayed":     func(p Player) interface{} { return p.MatchesPlayed },
+	"TimePlayed":        func(p Player) interface{} { return p.TimePlayed },
+	"PreferredRole":     func(p Player) interface{} { return p.PreferredRole },
+	"OperatorPickRates": func(p Player) interface{} { return p.OperatorPickRates },
+	"Tier":              func(p Player) interface{} { return p.Tier() },
+}
+
+// sourceValue reads a Player field, or calls a Player method without arguments
+func sourceValue(player Player, source string) interface{} {
+	if accessor, ok := sources[source]; ok {
+		return accessor(player)
+	}
+	return nil
+}
+
+func toFloat(value interface{}) float64 {
+	number, _ := toNumber(value)
+	return number
+}
+
+func toNumber(value interface{}) (float64, bool) {
+	switch value := value.(type) {
+	case int:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case float32:
+		return float64(value), true
+	case float64:
+		return value, true
+	case time.Duration:
+		return value.Hours(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,72 @@
+package vectors
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSchemasValidate(t *testing.T) {
+	for version, schema := range Schemas {
+		if schema.Version != version {
+			t.Errorf("Schemas[%d].Version = %d", version, schema.Version)
+		}
+
+		if err := schema.Validate(); err != nil {
+			t.Errorf("Schema.Validate() error = %v, want nil", err)
+		}
+	}
+
+	broken := Schema{Version: 99, Features: []Feature{{Name: "elo", Source: "Elo", Transform: Identity{}}}}
+	if err := broken.Validate(); err == nil {
+		t.Errorf("Schema.Validate() error = nil for an unknown field, want error")
+	}
+}
+
+func TestSourcesCoverPlayer(t *testing.T) {
+	player := reflect.ValueOf(Player{Level: 211, Kost: 0.76, Rank: 35, Season: 30, PreferredRole: "defender"})
+	for i := 0; i < player.NumField(); i++ {
+		name := player.Type().Field(i).Name
+		accessor, ok := sources[name]
+		if !ok {
+			t.Errorf("sources has no accessor of Player.%s", name)
+			continue
+		}
+
+		if got, want := accessor(player.Interface().(Player)), player.Field(i).Interface(); !reflect.DeepEqual(got, want) {
+			t.Errorf("sources[%s]() = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestSchemaV2Raw(t *testing.T) {
+	player := Player{
+		Level:         211,
+		Kost:          0.76,
+		Rank:          35,
+		RankPoints:    3424,
+		WinRate:       0.55,
+		HeadshotRate:  0.48,
+		KD:            1.2,
+		MatchesPlayed: 999,
+		TimePlayed:    99 * time.Hour,
+		PreferredRole: "defender",
+	}
+
+	want := []float32{211, 0.76, 35, 3424, 0.55, 0.48, 1.2, float32(math.Log(1000)), float32(math.Log(100)), 0, 1}
+	if got := SchemaV2.Raw(player); !reflect.DeepEqual(got, want) {
+		t.Errorf("SchemaV2.Raw() = %v, want %v", got, want)
+	}
+
+	// differences are reported in the original units, not log scaled
+	values := SchemaV2.Values(player)
+	if values[7] != 999 || values[8] != 99 || values[10] != 1 {
+		t.Errorf("SchemaV2.Values() = %v, want raw counts and one-hot role", values)
+	}
+
+	names := SchemaV2.Names()
+	if len(names) != SchemaV2.Dimension() || names[9] != "preferred_role:attacker" || names[10] != "preferred_role:defender" {
+		t.Errorf("SchemaV2.Names() = %v", names)
+	}
+}
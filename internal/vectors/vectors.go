@@ -1,15 +1,120 @@
 package vectors
 
+import "time"
+
 type Player struct {
-	Level      int
-	Kost       float64
+	Level int
+	Kost  float64
+	// Rank indexes the ranked ladder of Season, see RankTier
 	Rank       int
 	RankPoints int
+	// Season the ranked stats are from, numbered from Y1S1 as 1. Stored players always
+	// have one, 0 ranks a profile on the current ladder
+	Season int
+
+	WinRate       float64
+	HeadshotRate  float64
+	KD            float64
+	MatchesPlayed int
+	TimePlayed    time.Duration
+	// PreferredRole is "attacker" or "defender", empty when unknown
+	PreferredRole string
+	// OperatorPickRates maps operator names to the share of rounds they were picked in
+	OperatorPickRates map[string]float64
+}
+
+// SchemaV1 is the original four feature layout
+var SchemaV1 = Schema{
+	Version: 1,
+	Features: []Feature{
+		{Name: "level", Source: "Level", Transform: Identity{}},
+		{Name: "kost", Source: "Kost", Transform: Identity{}},
+		{Name: "rank", Source: "Rank", Transform: Identity{}},
+		{Name: "rank_points", Source: "RankPoints", Transform: Identity{}},
+	},
+	Normalization: Normalization{
+		Mean:   []float32{150, 0.6, 17, 2500},
+		StdDev: []float32{100, 0.1, 8, 900},
+	},
+}
+
+// SchemaV2 adds the rest of the stats pipeline, counts are log scaled and the
+// preferred role is one-hot encoded
+var SchemaV2 = Schema{
+	Version: 2,
+	Features: []Feature{
+		{Name: "level", Source: "Level", Transform: Identity{}},
+		{Name: "kost", Source: "Kost", Transform: Identity{}},
+		{Name: "rank", Source: "Rank", Transform: Identity{}},
+		{Name: "rank_points", Source: "RankPoints", Transform: Identity{}},
+		{Name: "win_rate", Source: "WinRate", Transform: Identity{}},
+		{Name: "headshot_rate", Source: "HeadshotRate", Transform: Identity{}},
+		{Name: "kd", Source: "KD", Transform: Identity{}},
+		{Name: "matches_played", Source: "MatchesPlayed", Transform: Log1p{}},
+		{Name: "time_played", Source: "TimePlayed", Transform: Log1p{}},
+		{Name: "preferred_role", Source: "PreferredRole", Transform: OneHot{"attacker", "defender"}},
+	},
+	Normalization: Normalization{
+		// the one-hot role has no standard deviation and is passed through as is
+		Mean:   []float32{150, 0.6, 17, 2500, 0.5, 0.45, 1, 5, 5, 0, 0},
+		StdDev: []float32{100, 0.1, 8, 900, 0.08, 0.12, 0.25, 1.5, 1.5, 0, 0},
+	},
+}
+
+// SchemaV3 concatenates a playstyle sub-vector onto v2, the operator pick rates
+// reduced to a histogram over archetypes. Two players with the same stats but a
+// different role in the team end up apart
+var SchemaV3 = Schema{
+	Version:  3,
+	Features: append(append([]Feature(nil), SchemaV2.Features...), Feature{Name: "playstyle", Source: "OperatorPickRates", Transform: PlaystyleHistogram}),
+	Normalization: Normalization{
+		// histogram shares are already on a comparable scale and passed through as is
+		Mean:   append(append([]float32(nil), SchemaV2.Normalization.Mean...), make([]float32, len(Playstyles))...),
+		StdDev: append(append([]float32(nil), SchemaV2.Normalization.StdDev...), make([]float32, len(Playstyles))...),
+	},
+}
+
+// SchemaV4 encodes rank as a position on the tier-distance table of DefaultRankOrdinal
+// instead of the raw ladder index, which also makes ranks of older seasons comparable.
+// Positions stay on the scale of the current ladder so the normalization carries over
+var SchemaV4 = Schema{
+	Version:       4,
+	Features:      replaceFeature(SchemaV3.Features, Feature{Name: "rank", Source: "Tier", Transform: DefaultRankOrdinal}),
+	Normalization: SchemaV3.Normalization,
+}
+
+// Schemas holds every schema stored vectors may have been built with, by version
+var Schemas = map[int]Schema{
+	SchemaV1.Version: SchemaV1,
+	SchemaV2.Version: SchemaV2,
+	SchemaV3.Version: SchemaV3,
+	SchemaV4.Version: SchemaV4,
+}
+
+// Current is the schema new collections are built with and re-indexes move to, a
+// Config may replace it at startup
+var Current = SchemaV4
+
+// replaceFeature returns a copy of features with the feature of the same name replaced
+func replaceFeature(features []Feature, replacement Feature) []Feature {
+	replaced := append([]Feature(nil), features...)
+	for i, feature := range replaced {
+		if feature.Name == replacement.Name {
+			replaced[i] = replacement
+		}
+	}
+	return replaced
+}
+
+// Tier is the ranked tier of the player's Rank in their Season
+func (p Player) Tier() Tier {
+	return RankTier(p.Season, p.Rank)
 }
 
-// Convert an interface to a float vector
+// Convert an interface to a float vector, the features of Current before normalization
+// including the playstyle sub-vector
 func ConvertPlayerToVector(player Player) []float32 {
-	return []float32{float32(player.Level), float32(player.Kost), float32(player.Rank), float32(player.RankPoints)}
+	return Current.Raw(player)
 }
 
 type numeric interface {
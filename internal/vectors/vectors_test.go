@@ -11,18 +11,33 @@ func TestConvertPlayerToVector(t *testing.T) {
 		player Player
 		want   []float32
 	}{
-		{Player{Level: 10, Kost: 0.76, Rank: 3, RankPoints: 500}, []float32{10.0, 0.76, 3.0, 500.0}},
-		{Player{Level: 5, Kost: 10.5, Rank: 1, RankPoints: 1000}, []float32{5.0, 10.5, 1.0, 1000.0}},
-		{Player{Level: 20, Kost: 50.0, Rank: 5, RankPoints: 250}, []float32{20.0, 50.0, 5.0, 250.0}},
+		{Player{Level: 10, Kost: 0.76, Rank: 3, RankPoints: 500}, []float32{10.0, 0.76, float32(DefaultRankOrdinal["copper_3"]), 500.0}},
+		{Player{Level: 5, Kost: 10.5, Rank: 1, RankPoints: 1000}, []float32{5.0, 10.5, float32(DefaultRankOrdinal["copper_5"]), 1000.0}},
+		{Player{Level: 20, Kost: 50.0, Rank: 5, RankPoints: 250}, []float32{20.0, 50.0, float32(DefaultRankOrdinal["copper_1"]), 250.0}},
 	}
 
 	// Loop through test cases
 	for _, testCase := range testCases {
 		got := ConvertPlayerToVector(testCase.player)
 
-		// Check if the output is correct
-		if !reflect.DeepEqual(got, testCase.want) {
-			t.Errorf("ConvertPlayerToVector(%v) = %v, want %v", testCase.player, got, testCase.want)
+		// Check if the output is correct, the stats lead the vector of the current schema
+		if len(got) != Current.Dimension() || !reflect.DeepEqual(got[:4], testCase.want) {
+			t.Errorf("ConvertPlayerToVector(%v) = %v, want %v first", testCase.player, got, testCase.want)
 		}
 	}
+
+	// the playstyle sub-vector is concatenated onto the stats
+	player := Player{OperatorPickRates: map[string]float64{"thermite": 1}}
+	got := ConvertPlayerToVector(player)
+	if want := PlaystyleHistogram.Apply(player.OperatorPickRates); !reflect.DeepEqual(got[len(got)-len(want):], toFloat32(want)) {
+		t.Errorf("ConvertPlayerToVector() playstyle = %v, want %v", got[len(got)-len(want):], want)
+	}
+}
+
+func toFloat32(values []float64) []float32 {
+	converted := make([]float32, len(values))
+	for i, value := range values {
+		converted[i] = float32(value)
+	}
+	return converted
 }
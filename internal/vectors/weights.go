@@ -0,0 +1,78 @@
+package vectors
+
+import (
+	"fmt"
+	"math"
+)
+
+// Weights scales what every dimension of a schema's vectors adds to the l2-squared
+// distance, a weight of 0 ignores the dimension
+type Weights []float32
+
+// Weights builds per dimension weights from weights per feature name, features that
+// aren't named keep a weight of 1. Weighting a multi-dimensional feature like
+// playstyle weights every dimension of it
+func (s Schema) Weights(features map[string]float32) (Weights, error) {
+	known := make(map[string]struct{}, len(s.Features))
+	for _, feature := range s.Features {
+		known[feature.Name] = struct{}{}
+	}
+
+	for name, weight := range features {
+		if _, ok := known[name]; !ok {
+			return nil, fmt.Errorf("unknown feature %q", name)
+		}
+
+		if weight < 0 || math.IsNaN(float64(weight)) || math.IsInf(float64(weight), 0) {
+			return nil, fmt.Errorf("feature %q: weight must not be negative", name)
+		}
+	}
+
+	weights := make(Weights, 0, s.Dimension())
+	for _, feature := range s.Features {
+		weight, ok := features[feature.Name]
+		if !ok {
+			weight = 1
+		}
+
+		for i := 0; i < feature.Transform.Dimension(); i++ {
+			weights = append(weights, weight)
+		}
+	}
+
+	return weights, nil
+}
+
+// Apply scales vector so that the plain l2-squared distance between two scaled vectors
+// is the weighted distance between the originals
+func (w Weights) Apply(vector []float32) []float32 {
+	weighted := make([]float32, len(vector))
+	for i, val := range vector {
+		weighted[i] = val
+		if i < len(w) {
+			weighted[i] = val * float32(math.Sqrt(float64(w[i])))
+		}
+	}
+
+	return weighted
+}
+
+// Distance is the weighted l2-squared distance between two vectors
+func (w Weights) Distance(a, b []float32) float32 {
+	var distance float32
+	for i := range a {
+		if i >= len(b) {
+			break
+		}
+
+		weight := float32(1)
+		if i < len(w) {
+			weight = w[i]
+		}
+
+		diff := a[i] - b[i]
+		distance += weight * diff * diff
+	}
+
+	return distance
+}
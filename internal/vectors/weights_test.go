@@ -0,0 +1,54 @@
+package vectors
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSchemaWeights(t *testing.T) {
+	weights, err := SchemaV3.Weights(map[string]float32{"playstyle": 4, "kd": 0})
+	if err != nil {
+		t.Fatalf("Schema.Weights() error = %v, want nil", err)
+	}
+
+	if len(weights) != SchemaV3.Dimension() {
+		t.Fatalf("Schema.Weights() = %d dimensions, want %d", len(weights), SchemaV3.Dimension())
+	}
+
+	names := SchemaV3.Names()
+	for i, weight := range weights {
+		want := float32(1)
+		switch {
+		case names[i] == "kd":
+			want = 0
+		case len(names[i]) > len("playstyle:") && names[i][:len("playstyle:")] == "playstyle:":
+			want = 4
+		}
+
+		if weight != want {
+			t.Errorf("weight of %s = %v, want %v", names[i], weight, want)
+		}
+	}
+
+	if _, err := SchemaV3.Weights(map[string]float32{"elo": 1}); err == nil {
+		t.Errorf("Schema.Weights() error = nil for an unknown feature, want error")
+	}
+
+	if _, err := SchemaV3.Weights(map[string]float32{"kd": -1}); err == nil {
+		t.Errorf("Schema.Weights() error = nil for a negative weight, want error")
+	}
+}
+
+func TestWeightsApply(t *testing.T) {
+	weights := Weights{4, 0}
+	a, b := []float32{1, 1}, []float32{2, 3}
+
+	if got := weights.Apply(a); !reflect.DeepEqual(got, []float32{2, 0}) {
+		t.Errorf("Weights.Apply() = %v, want [2 0]", got)
+	}
+
+	// the plain distance of applied vectors is the weighted distance
+	if got := Weights(nil).Distance(weights.Apply(a), weights.Apply(b)); got != weights.Distance(a, b) {
+		t.Errorf("distance of applied vectors = %v, want %v", got, weights.Distance(a, b))
+	}
+}
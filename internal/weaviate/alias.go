@@ -0,0 +1,360 @@
+package weaviate
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/reindex"
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+// AliasClass holds an object per alias, Weaviate has no aliases of its own
+const AliasClass = "CollectionAlias"
+
+// Alias maps a logical collection name onto the versioned class that serves it, and
+// remembers the class it pointed to before the last promote for rollbacks
+type Alias struct {
+	Name                  string
+	Class                 string
+	SchemaVersion         int
+	PreviousClass         string
+	PreviousSchemaVersion int
+	// SwitchedAt is when the alias last switched classes, writes since only reached Class
+	SwitchedAt time.Time
+}
+
+// VersionedClass names the physical class of a collection version, e.g. Players_v3
+func VersionedClass(name string, version int) string {
+	return fmt.Sprintf("%s_v%d", name, version)
+}
+
+// Aliases resolves logical collection names to classes. Aliases are read by Load and
+// change through Create, Promote and Rollback, so every request resolves them without
+// a round trip. Run reloads them to pick up the switches of other replicas
+type Aliases struct {
+	client  *weaviate.Client
+	mutex   *sync.RWMutex
+	aliases map[string]Alias
+	// saves counts the aliases saved, a Load that raced a save doesn't revert it
+	saves uint64
+}
+
+func NewAliases(client *weaviate.Client) *Aliases {
+	return &Aliases{
+		client:  client,
+		mutex:   &sync.RWMutex{},
+		aliases: map[string]Alias{},
+	}
+}
+
+// Load reads every alias, migrating the alias class first
+func (a *Aliases) Load(ctx context.Context) error {
+	if err := Migrate(ctx, a.client, aliasClass()); err != nil {
+		return err
+	}
+
+	return a.reload(ctx)
+}
+
+// reload reads every alias
+func (a *Aliases) reload(ctx context.Context) error {
+	a.mutex.RLock()
+	saves := a.saves
+	a.mutex.RUnlock()
+
+	objects, err := a.client.Data().ObjectsGetter().WithClassName(AliasClass).WithLimit(maxAliases).Do(ctx)
+	if err != nil {
+		return err
+	}
+
+	aliases := make(map[string]Alias, len(objects))
+	for _, object := range objects {
+		properties, _ := object.Properties.(map[string]interface{})
+		// aliases saved before switches were timed have none
+		switchedAt, _ := time.Parse(time.RFC3339Nano, stringProperty(properties, "switchedAt"))
+		alias := Alias{
+			Name:                  stringProperty(properties, "name"),
+			Class:                 stringProperty(properties, "class"),
+			SchemaVersion:         int(numberProperty(properties, "schemaVersion")),
+			PreviousClass:         stringProperty(properties, "previousClass"),
+			PreviousSchemaVersion: int(numberProperty(properties, "previousSchemaVersion")),
+			SwitchedAt:            switchedAt,
+		}
+		aliases[alias.Name] = alias
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	// the objects may have been read before a save of this process, the next reload
+	// picks both up
+	if a.saves == saves {
+		a.aliases = aliases
+	}
+	return nil
+}
+
+// Run reloads the aliases every interval until ctx is done, so a promote or rollback
+// of another replica switches this one's reads too
+func (a *Aliases) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.reload(ctx); err != nil {
+				slog.Error("weaviate: reloading aliases", "err", err)
+			}
+		}
+	}
+}
+
+// maxAliases bounds the aliases read by Load
+const maxAliases = 100
+
+// Get returns the alias of name
+func (a *Aliases) Get(name string) (Alias, bool) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	alias, ok := a.aliases[name]
+	return alias, ok
+}
+
+// Resolve returns the class name currently points to, a name without an alias is a
+// class of its own
+func (a *Aliases) Resolve(name string) string {
+	if alias, ok := a.Get(name); ok {
+		return alias.Class
+	}
+	return name
+}
+
+// Store returns a store that reads and writes whatever class name points to
+func (a *Aliases) Store(name string) *Store {
+	return &Store{client: a.client, className: name, aliases: a}
+}
+
+// Create points name to class, creating class unless it exists. Once name has an
+// alias Create is a no-op, the alias only changes through Promote and Rollback.
+// A class that already holds records keeps the schema version they were built with
+// instead of schemaVersion, switching would hide them until a re-index
+func (a *Aliases) Create(ctx context.Context, name, class string, schemaVersion int) (Alias, error) {
+	if alias, ok := a.Get(name); ok {
+		return alias, nil
+	}
+
+	if err := Migrate(ctx, a.client, Class(class)); err != nil {
+		return Alias{}, err
+	}
+
+	stored, _, err := New(a.client, class).Scan(ctx, "", 1)
+	if err != nil {
+		return Alias{}, err
+	}
+
+	if len(stored) > 0 {
+		schemaVersion = stored[0].SchemaVersion
+	}
+
+	alias := Alias{Name: name, Class: class, SchemaVersion: schemaVersion}
+	return alias, a.save(ctx, alias)
+}
+
+// Backfill returns a job re-embedding every record name serves into class with schema,
+// class must have been created. The caller sets the job's lock and checkpoint and runs it
+func (a *Aliases) Backfill(name, class string, schema vectors.Schema) *reindex.Job {
+	return &reindex.Job{
+		Source:     a.Store(name),
+		Target:     New(a.client, class),
+		TargetName: class,
+		Schema:     schema,
+	}
+}
+
+// Promote points name to class, the class it pointed to before is kept for Rollback
+func (a *Aliases) Promote(ctx context.Context, name, class string, schemaVersion int) (Alias, error) {
+	alias, ok := a.Get(name)
+	if !ok {
+		return Alias{}, fmt.Errorf("weaviate: no alias %s", name)
+	}
+
+	if alias.Class == class {
+		return alias, nil
+	}
+
+	promoted := Alias{Name: name, Class: class, SchemaVersion: schemaVersion, PreviousClass: alias.Class, PreviousSchemaVersion: alias.SchemaVersion, SwitchedAt: time.Now().UTC()}
+	return promoted, a.save(ctx, promoted)
+}
+
+// Rollback points name back to the class it pointed to before the last Promote.
+// Writes since the promote only reached the promoted class, so they are replayed
+// into the previous class first: the players deleted since are deleted and the
+// records updated since are copied back, re-embedded with the previous schema
+func (a *Aliases) Rollback(ctx context.Context, name string, deleted reindex.Deletions) (Alias, error) {
+	alias, ok := a.Get(name)
+	if !ok {
+		return Alias{}, fmt.Errorf("weaviate: no alias %s", name)
+	}
+
+	if alias.PreviousClass == "" {
+		return Alias{}, reindex.ErrNoPrevious
+	}
+
+	ids, err := deleted(alias.SwitchedAt)
+	if err != nil {
+		return Alias{}, err
+	}
+
+	previous := New(a.client, alias.PreviousClass)
+	for start := 0; start < len(ids); start += replayBatchSize {
+		if err := previous.Delete(ctx, ids[start:min(start+replayBatchSize, len(ids))]); err != nil {
+			return Alias{}, err
+		}
+	}
+
+	// deletes go first, a player deleted and indexed again since is in the promoted class
+	if err := a.replayUpserts(ctx, alias, previous); err != nil {
+		return Alias{}, err
+	}
+
+	rolledBack := Alias{Name: name, Class: alias.PreviousClass, SchemaVersion: alias.PreviousSchemaVersion, PreviousClass: alias.Class, PreviousSchemaVersion: alias.SchemaVersion, SwitchedAt: time.Now().UTC()}
+	return rolledBack, a.save(ctx, rolledBack)
+}
+
+// replayUpserts copies the records of the promoted class updated since the promote
+// into previous. A rollback is refused when the previous schema is unknown, the
+// copies couldn't be re-embedded and the writes would be lost
+func (a *Aliases) replayUpserts(ctx context.Context, alias Alias, previous *Store) error {
+	schema, known := vectors.Schemas[alias.PreviousSchemaVersion]
+	since := alias.SwitchedAt.Add(-replayClockSkew)
+
+	promoted := New(a.client, alias.Class)
+	for cursor := ""; ; {
+		players, next, err := promoted.Scan(ctx, cursor, replayBatchSize)
+		if err != nil {
+			return err
+		}
+
+		written := make([]*store.Player, 0, len(players))
+		for _, player := range players {
+			if player.UpdatedAt.Before(since) {
+				continue
+			}
+			if !known {
+				return fmt.Errorf("weaviate: rollback %s: %s was written since the promote and schema v%d is unknown", alias.Name, alias.Class, alias.PreviousSchemaVersion)
+			}
+			player.Vector = schema.Vector(player.Stats)
+			player.SchemaVersion = schema.Version
+			written = append(written, player)
+		}
+
+		if len(written) > 0 {
+			if err := previous.Upsert(ctx, written); err != nil {
+				return err
+			}
+		}
+
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// replayBatchSize bounds the players replayed by a single request of a rollback
+const replayBatchSize = 100
+
+// replayClockSkew widens the records a rollback replays, other replicas stamp
+// their writes with their own clocks
+const replayClockSkew = time.Minute
+
+// Collection returns the versioned collections behind name
+func (a *Aliases) Collection(name string) *Collection {
+	return &Collection{aliases: a, name: name}
+}
+
+// Collection builds new versions of a logical collection and switches its alias
+// between them, it is what a server re-indexes through
+type Collection struct {
+	aliases *Aliases
+	name    string
+}
+
+// Name is the logical name of the collection
+func (c *Collection) Name() string {
+	return c.name
+}
+
+// Active returns the class and schema version the alias points to
+func (c *Collection) Active() (string, int) {
+	alias, ok := c.aliases.Get(c.name)
+	if !ok {
+		return c.name, vectors.Current.Version
+	}
+	return alias.Class, alias.SchemaVersion
+}
+
+// Store returns the store reading and writing through the alias
+func (c *Collection) Store() *Store {
+	return c.aliases.Store(c.name)
+}
+
+func (c *Collection) Backfill(ctx context.Context, target string, schema vectors.Schema) (*reindex.Job, error) {
+	if err := Migrate(ctx, c.aliases.client, Class(target)); err != nil {
+		return nil, err
+	}
+	return c.aliases.Backfill(c.name, target, schema), nil
+}
+
+func (c *Collection) Promote(ctx context.Context, target string, schemaVersion int) error {
+	_, err := c.aliases.Promote(ctx, c.name, target, schemaVersion)
+	return err
+}
+
+func (c *Collection) Rollback(ctx context.Context, deleted reindex.Deletions) (string, int, error) {
+	alias, err := c.aliases.Rollback(ctx, c.name, deleted)
+	return alias.Class, alias.SchemaVersion, err
+}
+
+// save persists alias before any request resolves it
+func (a *Aliases) save(ctx context.Context, alias Alias) error {
+	object := &models.Object{
+		Class: AliasClass,
+		ID:    nameUUID("alias/" + alias.Name),
+		Properties: map[string]interface{}{
+			"name":                  alias.Name,
+			"class":                 alias.Class,
+			"schemaVersion":         alias.SchemaVersion,
+			"previousClass":         alias.PreviousClass,
+			"previousSchemaVersion": alias.PreviousSchemaVersion,
+			"switchedAt":            alias.SwitchedAt.Format(time.RFC3339Nano),
+		},
+	}
+
+	results, err := a.client.Batch().ObjectsBatcher().WithObjects(object).Do(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		if result.Result != nil && result.Result.Errors != nil && len(result.Result.Errors.Error) > 0 {
+			return fmt.Errorf("weaviate: alias %s: %s", alias.Name, result.Result.Errors.Error[0].Message)
+		}
+	}
+
+	a.mutex.Lock()
+	a.aliases[alias.Name] = alias
+	a.saves++
+	a.mutex.Unlock()
+	return nil
+}
@@ -0,0 +1,173 @@
+package weaviate
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/reindex"
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
+)
+
+func TestAliasesResolve(t *testing.T) {
+	aliases := NewAliases(nil)
+	aliases.aliases["Players"] = Alias{Name: "Players", Class: VersionedClass("Players", 3), SchemaVersion: 3}
+
+	if got := aliases.Resolve("Players"); got != "Players_v3" {
+		t.Errorf("Aliases.Resolve() = %s, want Players_v3", got)
+	}
+
+	// names without an alias are classes of their own
+	if got := aliases.Resolve("Player"); got != "Player" {
+		t.Errorf("Aliases.Resolve() = %s, want Player", got)
+	}
+
+	if got := aliases.Store("Players").class(); got != "Players_v3" {
+		t.Errorf("Store.class() = %s, want Players_v3", got)
+	}
+
+	if got := New(nil, "Players").class(); got != "Players" {
+		t.Errorf("Store.class() = %s, want Players", got)
+	}
+}
+
+func TestAliasesPromoteAndRollback(t *testing.T) {
+	ctx := context.Background()
+	client := createSimpleTestClient(t)
+
+	aliases := NewAliases(client)
+	if err := aliases.Load(ctx); err != nil {
+		t.Fatalf("Aliases.Load() error = %v, want nil", err)
+	}
+
+	alias, err := aliases.Create(ctx, "Players", VersionedClass("Players", 3), 3)
+	if err != nil || alias.Class != "Players_v3" {
+		t.Fatalf("Aliases.Create() = %+v, %v, want Players_v3", alias, err)
+	}
+
+	// creating it again keeps the alias where it is
+	if alias, err := aliases.Create(ctx, "Players", "Players_v9", 9); err != nil || alias.Class != "Players_v3" {
+		t.Errorf("Aliases.Create() again = %+v, %v, want Players_v3", alias, err)
+	}
+
+	collection := aliases.Collection("Players")
+	none := func(time.Time) ([]string, error) { return nil, nil }
+	if _, _, err := collection.Rollback(ctx, none); !errors.Is(err, reindex.ErrNoPrevious) {
+		t.Errorf("Collection.Rollback() error = %v, want %v", err, reindex.ErrNoPrevious)
+	}
+
+	players := collection.Store()
+	record := &store.Player{ID: "6844b415-aa94-43c9-8823-9389e4816910", Stats: vectors.Player{Season: 30}, Vector: []float32{1, 2}, SchemaVersion: 3}
+	erased := &store.Player{ID: "6844b415-aa94-43c9-8823-9389e4816914", Stats: vectors.Player{Season: 30}, Vector: []float32{2, 1}, SchemaVersion: 3}
+	if err := players.Upsert(ctx, []*store.Player{record, erased}); err != nil {
+		t.Fatalf("Store.Upsert() error = %v, want nil", err)
+	}
+
+	job, err := collection.Backfill(ctx, "Players_v4", vectors.SchemaV4)
+	if err != nil {
+		t.Fatalf("Collection.Backfill() error = %v, want nil", err)
+	}
+
+	job.BatchSize = 10
+	if err := job.Run(ctx); err != nil {
+		t.Fatalf("Job.Run() error = %v, want nil", err)
+	}
+
+	if err := collection.Promote(ctx, "Players_v4", 4); err != nil {
+		t.Fatalf("Collection.Promote() error = %v, want nil", err)
+	}
+
+	// the store goes through the alias, its reads now come from the backfilled class
+	got, err := players.Get(ctx, record.ID)
+	if err != nil || got.SchemaVersion != 4 {
+		t.Errorf("Store.Get() after promote = %+v, %v, want the v4 record", got, err)
+	}
+
+	// a new process sees the promoted alias
+	reloaded := NewAliases(client)
+	if err := reloaded.Load(ctx); err != nil {
+		t.Fatalf("Aliases.Load() error = %v, want nil", err)
+	}
+
+	alias, _ = reloaded.Get("Players")
+	if alias.Class != "Players_v4" || alias.PreviousClass != "Players_v3" || alias.SchemaVersion != 4 || alias.SwitchedAt.IsZero() {
+		t.Errorf("Aliases.Get() = %+v, want Players_v4 after Players_v3", alias)
+	}
+
+	// a player erased since the promote only left the promoted class
+	if err := players.Delete(ctx, []string{erased.ID}); err != nil {
+		t.Fatalf("Store.Delete() error = %v, want nil", err)
+	}
+
+	// a player indexed since the promote only reached the promoted class
+	indexed := &store.Player{ID: "6844b415-aa94-43c9-8823-9389e4816918", Stats: vectors.Player{Level: 120, Season: 30, KD: 1.4}, Vector: []float32{3, 4}, SchemaVersion: 4, UpdatedAt: time.Now().UTC()}
+	if err := players.Upsert(ctx, []*store.Player{indexed}); err != nil {
+		t.Fatalf("Store.Upsert() error = %v, want nil", err)
+	}
+
+	var since time.Time
+	deleted := func(switchedAt time.Time) ([]string, error) {
+		since = switchedAt
+		return []string{erased.ID}, nil
+	}
+
+	class, version, err := collection.Rollback(ctx, deleted)
+	if err != nil || class != "Players_v3" || version != 3 {
+		t.Fatalf("Collection.Rollback() = %s, %d, %v, want Players_v3 with v3", class, version, err)
+	}
+
+	if !since.Equal(alias.SwitchedAt) {
+		t.Errorf("Collection.Rollback() replayed deletes since %v, want since the promote at %v", since, alias.SwitchedAt)
+	}
+
+	if got, err := players.Get(ctx, record.ID); err != nil || got.SchemaVersion != 3 {
+		t.Errorf("Store.Get() after rollback = %+v, %v, want the v3 record", got, err)
+	}
+
+	if _, err := players.Get(ctx, erased.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Store.Get() of the erased player after rollback error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	want := vectors.Schemas[3].Vector(indexed.Stats)
+	if got, err := players.Get(ctx, indexed.ID); err != nil || got.SchemaVersion != 3 || !reflect.DeepEqual(got.Vector, want) {
+		t.Errorf("Store.Get() of the player indexed since the promote = %+v, %v, want it re-embedded with v3", got, err)
+	}
+
+	// the other process follows the rollback once it reloads
+	if err := reloaded.reload(ctx); err != nil {
+		t.Fatalf("Aliases.reload() error = %v, want nil", err)
+	}
+
+	if got := reloaded.Resolve("Players"); got != "Players_v3" {
+		t.Errorf("Aliases.Resolve() after reload = %s, want Players_v3", got)
+	}
+}
+
+func TestAliasesCreateKeepsStoredSchema(t *testing.T) {
+	ctx := context.Background()
+	client := createSimpleTestClient(t)
+
+	// a class from before aliases, its vectors were built with v1
+	legacy := New(client, "Player")
+	if err := Migrate(ctx, client, Class("Player")); err != nil {
+		t.Fatal(err)
+	}
+
+	record := &store.Player{ID: "6844b415-aa94-43c9-8823-9389e4816910", Vector: []float32{1, 2, 3, 4}, SchemaVersion: 1}
+	if err := legacy.Upsert(ctx, []*store.Player{record}); err != nil {
+		t.Fatalf("Store.Upsert() error = %v, want nil", err)
+	}
+
+	aliases := NewAliases(client)
+	if err := aliases.Load(ctx); err != nil {
+		t.Fatalf("Aliases.Load() error = %v, want nil", err)
+	}
+
+	alias, err := aliases.Create(ctx, "Players", "Player", vectors.Current.Version)
+	if err != nil || alias.SchemaVersion != 1 {
+		t.Errorf("Aliases.Create() = %+v, %v, want the stored schema version 1", alias, err)
+	}
+}
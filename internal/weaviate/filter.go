@@ -0,0 +1,67 @@
+package weaviate
+
+import (
+	"time"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
+)
+
+var operators = map[store.Operator]filters.WhereOperator{
+	store.Equal:            filters.Equal,
+	store.NotEqual:         filters.NotEqual,
+	store.GreaterThan:      filters.GreaterThan,
+	store.GreaterThanEqual: filters.GreaterThanEqual,
+	store.LessThan:         filters.LessThan,
+	store.LessThanEqual:    filters.LessThanEqual,
+}
+
+// whereFilter translates a validated store filter into a Weaviate where filter, nil when empty
+func whereFilter(filter store.Filter) *filters.WhereBuilder {
+	operands := make([]*filters.WhereBuilder, 0, len(filter))
+	for _, condition := range filter {
+		operands = append(operands, conditionFilter(condition))
+	}
+
+	switch len(operands) {
+	case 0:
+		return nil
+	case 1:
+		return operands[0]
+	}
+
+	return filters.Where().WithOperator(filters.And).WithOperands(operands)
+}
+
+func conditionFilter(condition store.Condition) *filters.WhereBuilder {
+	if condition.Operator != store.In {
+		return valueFilter(condition.Property, operators[condition.Operator], condition.Value)
+	}
+
+	// Weaviate has no in-set operator, an Or of equalities does the same
+	operands := make([]*filters.WhereBuilder, 0, len(condition.Values))
+	for _, value := range condition.Values {
+		operands = append(operands, valueFilter(condition.Property, filters.Equal, value))
+	}
+
+	if len(operands) == 1 {
+		return operands[0]
+	}
+
+	return filters.Where().WithOperator(filters.Or).WithOperands(operands)
+}
+
+func valueFilter(property string, operator filters.WhereOperator, value interface{}) *filters.WhereBuilder {
+	where := filters.Where().WithPath([]string{property}).WithOperator(operator)
+
+	switch value := value.(type) {
+	case string:
+		where.WithValueText(value)
+	case float64:
+		where.WithValueNumber(value)
+	case time.Time:
+		where.WithValueDate(value)
+	}
+
+	return where
+}
@@ -0,0 +1,46 @@
+package weaviate
+
+import (
+	"testing"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+)
+
+func TestWhereFilter(t *testing.T) {
+	testCases := []struct {
+		name   string
+		filter store.Filter
+		want   string
+	}{
+		{
+			"single equality",
+			store.Filter{{Property: "platform", Operator: store.Equal, Value: "pc"}},
+			`where:{operator: Equal path: ["platform"] valueText: "pc"}`,
+		},
+		{
+			"in becomes or",
+			store.Filter{{Property: "region", Operator: store.In, Values: []interface{}{"emea", "ncsa"}}},
+			`where:{operator: Or operands:[{operator: Equal path: ["region"] valueText: "emea"},{operator: Equal path: ["region"] valueText: "ncsa"}]}`,
+		},
+		{
+			"range becomes and",
+			store.Filter{
+				{Property: "rankPoints", Operator: store.GreaterThanEqual, Value: 1200.0},
+				{Property: "rankPoints", Operator: store.LessThan, Value: 1300.0},
+			},
+			`where:{operator: And operands:[{operator: GreaterThanEqual path: ["rankPoints"] valueNumber: 1200},{operator: LessThan path: ["rankPoints"] valueNumber: 1300}]}`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if got := whereFilter(testCase.filter).String(); got != testCase.want {
+				t.Errorf("whereFilter() = %v, want %v", got, testCase.want)
+			}
+		})
+	}
+
+	if whereFilter(nil) != nil {
+		t.Errorf("whereFilter(nil) = not nil, want nil")
+	}
+}
@@ -0,0 +1,83 @@
+package weaviate
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
+	"github.com/go-openapi/strfmt"
+)
+
+func TestObjectID(t *testing.T) {
+	id := "6844b415-aa94-43c9-8823-9389e4816910"
+
+	if got := objectID(id, 0); string(got) != id {
+		t.Errorf("objectID(season 0) = %s, want the player id", got)
+	}
+
+	season := objectID(id, 30)
+	if !strfmt.IsUUID5(string(season)) || string(season) == id || season != objectID(id, 30) {
+		t.Errorf("objectID(season 30) = %s, want a stable uuid of its own", season)
+	}
+
+	if season == objectID(id, 31) || season == objectID("6844b415-aa94-43c9-8823-9389e4816914", 30) {
+		t.Errorf("objectID() collides across seasons or players")
+	}
+}
+
+func TestPlayerObjectRoundTrip(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	player := &store.Player{
+		ID: "6844b415-aa94-43c9-8823-9389e4816910",
+		Stats: vectors.Player{
+			Level: 211, Kost: 0.76, Rank: 35, RankPoints: 3424, Season: 30,
+			WinRate: 0.5, HeadshotRate: 0.25, KD: 1.5, MatchesPlayed: 120, TimePlayed: 90 * time.Minute,
+			PreferredRole: "attacker", OperatorPickRates: map[string]float64{"ash": 0.5, "smoke": 0.25},
+		},
+		Vector:        []float32{1, 2, 3},
+		SchemaVersion: 4,
+		UpdatedAt:     now,
+		Platform:      "pc",
+		LastSeen:      now,
+	}
+
+	object := playerToObject("Player", player)
+	if object.ID == "" || string(object.ID) == player.ID {
+		t.Errorf("playerToObject().ID = %s, want the season's object id", object.ID)
+	}
+
+	// properties come back from JSON, where every number is a float64 and arrays are untyped
+	properties := map[string]interface{}{}
+	for name, value := range object.Properties.(map[string]interface{}) {
+		switch value := value.(type) {
+		case int:
+			properties[name] = float64(value)
+		case []string:
+			values := make([]interface{}, len(value))
+			for i := range value {
+				values[i] = value[i]
+			}
+			properties[name] = values
+		case []float64:
+			values := make([]interface{}, len(value))
+			for i := range value {
+				values[i] = value[i]
+			}
+			properties[name] = values
+		default:
+			properties[name] = value
+		}
+	}
+
+	got, err := propertiesToPlayer(string(object.ID), object.Vector, properties)
+	if err != nil {
+		t.Fatalf("propertiesToPlayer() error = %v, want nil", err)
+	}
+
+	if !reflect.DeepEqual(got, player) {
+		t.Errorf("propertiesToPlayer() = %+v, want %+v", got, player)
+	}
+}
@@ -0,0 +1,200 @@
+package weaviate
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/weaviate/weaviate-go-client/v4/weaviate"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+// HNSW parameters of the player classes. Distance, efConstruction and maxConnections
+// are fixed once a class is created, changing them takes a new class version
+const (
+	Distance       = "l2-squared"
+	EF             = 128
+	EFConstruction = 128
+	MaxConnections = 64
+)
+
+// Class is the definition of a class players are stored in, vectors are brought along
+// and compared by squared euclidean distance
+func Class(name string) *models.Class {
+	return &models.Class{
+		Class:       name,
+		Description: "R6Index players, an object per player and season",
+		Vectorizer:  "none",
+		VectorIndexConfig: map[string]interface{}{
+			"distance":       Distance,
+			"ef":             EF,
+			"efConstruction": EFConstruction,
+			"maxConnections": MaxConnections,
+		},
+		Properties: []*models.Property{
+			// word tokenization would match a player by any of the id's parts
+			{Name: "uuid", DataType: []string{"text"}, Tokenization: "field", Description: "player id, shared by every season"},
+			{Name: "level", DataType: []string{"number"}},
+			{Name: "kost", DataType: []string{"number"}},
+			{Name: "rank", DataType: []string{"number"}},
+			{Name: "rankPoints", DataType: []string{"number"}},
+			{Name: "season", DataType: []string{"number"}},
+			{Name: "winRate", DataType: []string{"number"}},
+			{Name: "headshotRate", DataType: []string{"number"}},
+			{Name: "kd", DataType: []string{"number"}},
+			{Name: "matchesPlayed", DataType: []string{"number"}},
+			{Name: "timePlayed", DataType: []string{"number"}, Description: "seconds"},
+			{Name: "preferredRole", DataType: []string{"text"}},
+			{Name: "operators", DataType: []string{"text[]"}, Description: "operators of operatorPickRates"},
+			{Name: "operatorPickRates", DataType: []string{"number[]"}},
+			{Name: "schemaVersion", DataType: []string{"number"}},
+			{Name: "updatedAt", DataType: []string{"date"}},
+			{Name: "platform", DataType: []string{"text"}},
+			{Name: "region", DataType: []string{"text"}},
+			{Name: "language", DataType: []string{"text"}},
+			{Name: "lastSeen", DataType: []string{"date"}},
+		},
+	}
+}
+
+// aliasClass is the definition of AliasClass, its objects have no vectors
+func aliasClass() *models.Class {
+	return &models.Class{
+		Class:       AliasClass,
+		Description: "collection aliases, an object per logical collection",
+		Vectorizer:  "none",
+		Properties: []*models.Property{
+			{Name: "name", DataType: []string{"text"}},
+			{Name: "class", DataType: []string{"text"}},
+			{Name: "schemaVersion", DataType: []string{"number"}},
+			{Name: "previousClass", DataType: []string{"text"}},
+			{Name: "previousSchemaVersion", DataType: []string{"number"}},
+			{Name: "switchedAt", DataType: []string{"date"}},
+		},
+	}
+}
+
+// Migrate brings the live schema in line with classes. Missing classes are created and
+// missing properties added, so running it again is a no-op. Drift that can't be
+// migrated in place fails without changing anything
+func Migrate(ctx context.Context, client *weaviate.Client, classes ...*models.Class) error {
+	live, err := client.Schema().Getter().Do(ctx)
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]*models.Class, len(live.Classes))
+	for _, class := range live.Classes {
+		existing[class.Class] = class
+	}
+
+	migrations := make(map[*models.Class][]*models.Property, len(classes))
+	var problems []string
+	for _, class := range classes {
+		missing, drift := diff(class, existing[class.Class])
+		migrations[class] = missing
+		problems = append(problems, drift...)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("weaviate: incompatible schema drift: %s", strings.Join(problems, "; "))
+	}
+
+	for _, class := range classes {
+		if existing[class.Class] == nil {
+			slog.Info("weaviate: creating class", "class", class.Class)
+			if err := client.Schema().ClassCreator().WithClass(class).Do(ctx); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, property := range migrations[class] {
+			slog.Info("weaviate: adding property", "class", class.Class, "property", property.Name)
+			if err := client.Schema().PropertyCreator().WithClassName(class.Class).WithProperty(property).Do(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// diff compares a desired class with its live definition, nil when it doesn't exist
+// yet. It returns the properties to add and every difference that can't be migrated
+func diff(desired, live *models.Class) ([]*models.Property, []string) {
+	if live == nil {
+		return nil, nil
+	}
+
+	var problems []string
+	if vectorizer(live) != vectorizer(desired) {
+		problems = append(problems, fmt.Sprintf("%s vectorizer is %q, want %q", desired.Class, vectorizer(live), vectorizer(desired)))
+	}
+
+	want, _ := desired.VectorIndexConfig.(map[string]interface{})
+	have, _ := live.VectorIndexConfig.(map[string]interface{})
+	for _, key := range []string{"distance", "efConstruction", "maxConnections"} {
+		if value, ok := want[key]; ok && fmt.Sprint(value) != fmt.Sprint(have[key]) {
+			problems = append(problems, fmt.Sprintf("%s %s is %v, want %v", desired.Class, key, have[key], value))
+		}
+	}
+
+	// ef can be changed on a live class, but not through this client
+	if value, ok := want["ef"]; ok && fmt.Sprint(value) != fmt.Sprint(have["ef"]) {
+		slog.Warn("weaviate: ef differs, update it through the schema API", "class", desired.Class, "ef", have["ef"], "want", value)
+	}
+
+	properties := make(map[string]*models.Property, len(live.Properties))
+	for _, property := range live.Properties {
+		properties[property.Name] = property
+	}
+
+	var missing []*models.Property
+	for _, property := range desired.Properties {
+		existing, ok := properties[property.Name]
+		if !ok {
+			missing = append(missing, property)
+			continue
+		}
+
+		if dataType(existing) != dataType(property) {
+			problems = append(problems, fmt.Sprintf("%s.%s is %s, want %s", desired.Class, property.Name, dataType(existing), dataType(property)))
+		}
+
+		// tokenization is fixed once a property is created
+		if tokenization(existing) != tokenization(property) {
+			problems = append(problems, fmt.Sprintf("%s.%s is tokenized by %s, want %s", desired.Class, property.Name, tokenization(existing), tokenization(property)))
+		}
+	}
+
+	return missing, problems
+}
+
+// vectorizer is the class's vectorizer, Weaviate reports none when it isn't set
+func vectorizer(class *models.Class) string {
+	if class.Vectorizer == "" {
+		return "none"
+	}
+	return class.Vectorizer
+}
+
+// dataType is the property's data type, string is the text type before 1.19 and what
+// classes created by auto-schema report
+func dataType(property *models.Property) string {
+	return strings.NewReplacer("string", "text").Replace(strings.Join(property.DataType, ","))
+}
+
+// tokenization is how a text property is tokenized, Weaviate tokenizes by word when
+// it isn't set. Other properties aren't tokenized
+func tokenization(property *models.Property) string {
+	if !strings.HasPrefix(dataType(property), "text") {
+		return ""
+	}
+
+	if property.Tokenization == "" {
+		return "word"
+	}
+	return property.Tokenization
+}
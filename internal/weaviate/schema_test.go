@@ -0,0 +1,121 @@
+package weaviate
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+func TestDiff(t *testing.T) {
+	desired := Class("Players_v3")
+
+	if missing, problems := diff(desired, nil); missing != nil || problems != nil {
+		t.Errorf("diff() of a missing class = %v, %v, want it created as is", missing, problems)
+	}
+
+	// the live schema reports numbers as float64 and classes made by auto-schema use string
+	live := &models.Class{
+		Class:      "Players_v3",
+		Vectorizer: "none",
+		VectorIndexConfig: map[string]interface{}{
+			"distance": "l2-squared", "ef": float64(-1), "efConstruction": float64(128), "maxConnections": float64(64),
+		},
+		Properties: []*models.Property{
+			{Name: "uuid", DataType: []string{"string"}, Tokenization: "field"},
+			{Name: "level", DataType: []string{"number"}},
+		},
+	}
+
+	missing, problems := diff(desired, live)
+	if len(problems) != 0 {
+		t.Errorf("diff() problems = %v, want none", problems)
+	}
+
+	if len(missing) != len(desired.Properties)-2 || missing[0].Name != "kost" {
+		t.Errorf("diff() = %d missing properties starting with %v, want every property but uuid and level", len(missing), missing[0])
+	}
+
+	// properties already added aren't added again
+	live.Properties = desired.Properties
+	if missing, problems := diff(desired, live); len(missing) != 0 || len(problems) != 0 {
+		t.Errorf("diff() of a migrated class = %v, %v, want nothing to do", missing, problems)
+	}
+
+	drifted := &models.Class{
+		Class:             "Players_v3",
+		Vectorizer:        "text2vec-contextionary",
+		VectorIndexConfig: map[string]interface{}{"distance": "cosine", "efConstruction": float64(128), "maxConnections": float64(32)},
+		Properties:        []*models.Property{{Name: "uuid", DataType: []string{"text"}}, {Name: "level", DataType: []string{"int"}}},
+	}
+
+	// Weaviate tokenizes text by word unless told otherwise
+	_, problems = diff(desired, drifted)
+	want := []string{"vectorizer", "distance", "maxConnections", "uuid is tokenized by word", "level"}
+	if len(problems) != len(want) {
+		t.Fatalf("diff() problems = %v, want %d", problems, len(want))
+	}
+
+	for i, problem := range problems {
+		if !strings.Contains(problem, want[i]) {
+			t.Errorf("diff() problem = %q, want it about %s", problem, want[i])
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	client := createSimpleTestClient(t)
+
+	// a class made by auto-schema from the first write, with the default distance
+	legacy := &models.Class{
+		Class:             "Player",
+		VectorIndexConfig: map[string]interface{}{"distance": "l2-squared"},
+		Properties:        []*models.Property{{Name: "uuid", DataType: []string{"text"}, Tokenization: "field"}},
+	}
+	if err := client.Schema().ClassCreator().WithClass(legacy).Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Batch().ObjectsBatcher().WithObjects(&models.Object{Class: "Player", Properties: map[string]interface{}{"uuid": "x", "level": 10}}).Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := Migrate(ctx, client, Class("Player"), Class("Players_v4")); err != nil {
+			t.Fatalf("Migrate() error = %v, want nil", err)
+		}
+	}
+
+	for _, name := range []string{"Player", "Players_v4"} {
+		class, err := client.Schema().ClassGetter().WithClassName(name).Do(ctx)
+		if err != nil || len(class.Properties) != len(Class(name).Properties) {
+			t.Errorf("%s after Migrate() = %v, %v, want every property once", name, class, err)
+		}
+	}
+
+	// a class with another distance can't be migrated in place
+	if err := client.Schema().ClassCreator().WithClass(&models.Class{Class: "Players_v5"}).Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	err := Migrate(ctx, client, Class("Players_v6"), Class("Players_v5"))
+	if err == nil || !strings.Contains(err.Error(), "distance") {
+		t.Fatalf("Migrate() error = %v, want distance drift", err)
+	}
+
+	// nothing is changed when any class drifted
+	if exists, _ := client.Schema().ClassExistenceChecker().WithClassName("Players_v6").Do(ctx); exists {
+		t.Errorf("Migrate() created Players_v6 despite the drift of Players_v5")
+	}
+
+	// nor can a uuid tokenized by word
+	if _, err := client.Batch().ObjectsBatcher().WithObjects(&models.Object{Class: "Players_v7", Properties: map[string]interface{}{"uuid": "x"}}).Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Migrate(ctx, client, Class("Players_v7")); err == nil || !strings.Contains(err.Error(), "tokenized") {
+		t.Errorf("Migrate() error = %v, want tokenization drift", err)
+	}
+}
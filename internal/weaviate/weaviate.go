@@ -0,0 +1,450 @@
+package weaviate
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
+	"github.com/go-openapi/strfmt"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/graphql"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+// Store persists players as vectorizer-less objects of a single Weaviate class, either
+// a fixed class or the class an alias currently points to
+type Store struct {
+	client    *weaviate.Client
+	className string
+	aliases   *Aliases
+}
+
+func New(client *weaviate.Client, className string) *Store {
+	return &Store{
+		client:    client,
+		className: className,
+	}
+}
+
+// WithClient returns a copy of the store sending its requests to client, e.g. a
+// replica, classes are still resolved through the store's aliases
+func (s *Store) WithClient(client *weaviate.Client) *Store {
+	copied := *s
+	copied.client = client
+	return &copied
+}
+
+// class resolves the class requests go to, a request resolves it once so it never
+// spans two classes when the alias is promoted meanwhile
+func (s *Store) class() string {
+	if s.aliases == nil {
+		return s.className
+	}
+	return s.aliases.Resolve(s.className)
+}
+
+func (s *Store) Upsert(ctx context.Context, players []*store.Player) error {
+	if len(players) == 0 {
+		return nil
+	}
+
+	className := s.class()
+	objects := make([]*models.Object, 0, len(players))
+	for _, player := range players {
+		// an object id that isn't a UUID fails the whole batch
+		if !store.ValidID(player.ID) {
+			return fmt.Errorf("weaviate: upsert %s: not a UUID: %w", player.ID, store.ErrRejected)
+		}
+		objects = append(objects, playerToObject(className, player))
+	}
+
+	results, err := s.client.Batch().ObjectsBatcher().WithObjects(objects...).Do(ctx)
+	if err != nil {
+		return err
+	}
+
+	// the batch endpoint reports failures per object instead of failing the request,
+	// for objects it refuses to store
+	for _, result := range results {
+		if result.Result != nil && result.Result.Errors != nil && len(result.Result.Errors.Error) > 0 {
+			return fmt.Errorf("weaviate: upsert %s: %s: %w", result.ID, result.Result.Errors.Error[0].Message, store.ErrRejected)
+		}
+	}
+
+	// a player's first seasoned record replaces the one indexed without a season
+	seasoned := make([]interface{}, 0, len(players))
+	for _, player := range players {
+		if player.Stats.Season != 0 {
+			seasoned = append(seasoned, player.ID)
+		}
+	}
+
+	if len(seasoned) == 0 {
+		return nil
+	}
+
+	response, err := s.client.Batch().ObjectsBatchDeleter().
+		WithClassName(className).
+		WithWhere(whereFilter(store.Filter{
+			{Property: "uuid", Operator: store.In, Values: seasoned},
+			{Property: "season", Operator: store.Equal, Value: float64(0)},
+		})).
+		Do(ctx)
+	if err != nil {
+		return err
+	}
+
+	if response.Results != nil && response.Results.Failed > 0 {
+		return fmt.Errorf("weaviate: upsert: replacing %d records without a season failed", response.Results.Failed)
+	}
+
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	// every season of a player shares its uuid property
+	values := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, id)
+	}
+
+	response, err := s.client.Batch().ObjectsBatchDeleter().
+		WithClassName(s.class()).
+		WithWhere(whereFilter(store.Filter{{Property: "uuid", Operator: store.In, Values: values}})).
+		Do(ctx)
+	if err != nil {
+		return err
+	}
+
+	if response.Results != nil && response.Results.Failed > 0 {
+		return fmt.Errorf("weaviate: delete: %d objects failed", response.Results.Failed)
+	}
+
+	return nil
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*store.Player, error) {
+	players, err := s.seasons(ctx, id, graphql.Desc, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return players[0], nil
+}
+
+func (s *Store) History(ctx context.Context, id string) ([]*store.Player, error) {
+	return s.seasons(ctx, id, graphql.Asc, maxSeasons)
+}
+
+// maxSeasons bounds the records read for a single player
+const maxSeasons = 100
+
+// seasons returns up to limit records of a player ordered by season
+func (s *Store) seasons(ctx context.Context, id string, order graphql.SortOrder, limit int) ([]*store.Player, error) {
+	className := s.class()
+	response, err := s.client.GraphQL().Get().
+		WithClassName(className).
+		WithFields(append(playerFields, graphql.Field{Name: "_additional", Fields: []graphql.Field{{Name: "id"}, {Name: "vector"}}})...).
+		WithWhere(whereFilter(store.Filter{{Property: "uuid", Operator: store.Equal, Value: id}})).
+		WithSort(graphql.Sort{Path: []string{"season"}, Order: order}).
+		WithLimit(limit).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	hits, err := responseToHits(className, response)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(hits) == 0 {
+		return nil, store.ErrNotFound
+	}
+
+	players := make([]*store.Player, 0, len(hits))
+	for _, hit := range hits {
+		players = append(players, hit.Player)
+	}
+	return players, nil
+}
+
+func (s *Store) Scan(ctx context.Context, cursor string, limit int) ([]*store.Player, string, error) {
+	className := s.class()
+	// the cursor API pages through objects in id order, it can't be combined with filters or sorting
+	get := s.client.GraphQL().Get().
+		WithClassName(className).
+		WithFields(append(playerFields, graphql.Field{Name: "_additional", Fields: []graphql.Field{{Name: "id"}, {Name: "vector"}}})...).
+		WithLimit(limit)
+	if cursor != "" {
+		get = get.WithAfter(cursor)
+	}
+
+	response, err := get.Do(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	hits, err := responseToHits(className, response)
+	if err != nil {
+		return nil, "", err
+	}
+
+	players := make([]*store.Player, 0, len(hits))
+	for _, hit := range hits {
+		players = append(players, hit.Player)
+	}
+
+	if len(players) < limit {
+		return players, "", nil
+	}
+
+	last := players[len(players)-1]
+	return players, string(objectID(last.ID, last.Stats.Season)), nil
+}
+
+func (s *Store) Count(ctx context.Context) (int, error) {
+	className := s.class()
+	response, err := s.client.GraphQL().Aggregate().
+		WithClassName(className).
+		WithFields(graphql.Field{Name: "meta", Fields: []graphql.Field{{Name: "count"}}}).
+		Do(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(response.Errors) > 0 {
+		return 0, fmt.Errorf("weaviate: graphql: %s", response.Errors[0].Message)
+	}
+
+	aggregate, _ := response.Data["Aggregate"].(map[string]interface{})
+	results, _ := aggregate[className].([]interface{})
+	if len(results) == 0 {
+		return 0, nil
+	}
+
+	result, _ := results[0].(map[string]interface{})
+	meta, _ := result["meta"].(map[string]interface{})
+	return int(numberProperty(meta, "count")), nil
+}
+
+func (s *Store) NearVector(ctx context.Context, query store.Query) ([]store.Hit, error) {
+	// Weaviate would fall back to its default limit
+	if query.Limit <= 0 {
+		return nil, nil
+	}
+
+	className := s.class()
+	nearVector := s.client.GraphQL().NearVectorArgBuilder().WithVector(query.Vector)
+
+	get := s.client.GraphQL().Get().
+		WithClassName(className).
+		WithFields(append(playerFields, graphql.Field{Name: "_additional", Fields: []graphql.Field{{Name: "id"}, {Name: "distance"}, {Name: "vector"}}})...).
+		WithNearVector(nearVector).
+		WithLimit(query.Limit)
+
+	// vectors of other schema versions must never be compared with the query
+	filter := append(store.Filter{{Property: "schemaVersion", Operator: store.Equal, Value: float64(query.SchemaVersion)}}, query.Filter...)
+	get = get.WithWhere(whereFilter(filter))
+
+	response, err := get.Do(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return responseToHits(className, response)
+}
+
+// playerFields are the GraphQL properties needed to rebuild a store.Player, queries
+// add the _additional fields they need
+var playerFields = []graphql.Field{
+	{Name: "uuid"},
+	{Name: "level"},
+	{Name: "kost"},
+	{Name: "rank"},
+	{Name: "rankPoints"},
+	{Name: "season"},
+	{Name: "winRate"},
+	{Name: "headshotRate"},
+	{Name: "kd"},
+	{Name: "matchesPlayed"},
+	{Name: "timePlayed"},
+	{Name: "preferredRole"},
+	{Name: "operators"},
+	{Name: "operatorPickRates"},
+	{Name: "schemaVersion"},
+	{Name: "updatedAt"},
+	{Name: "platform"},
+	{Name: "region"},
+	{Name: "language"},
+	{Name: "lastSeen"},
+}
+
+func responseToHits(className string, response *models.GraphQLResponse) ([]store.Hit, error) {
+	if len(response.Errors) > 0 {
+		return nil, fmt.Errorf("weaviate: graphql: %s", response.Errors[0].Message)
+	}
+
+	get, _ := response.Data["Get"].(map[string]interface{})
+	results, _ := get[className].([]interface{})
+
+	hits := make([]store.Hit, 0, len(results))
+	for _, result := range results {
+		properties, _ := result.(map[string]interface{})
+		additional, _ := properties["_additional"].(map[string]interface{})
+
+		var vector []float32
+		values, _ := additional["vector"].([]interface{})
+		for _, value := range values {
+			number, _ := value.(float64)
+			vector = append(vector, float32(number))
+		}
+
+		player, err := propertiesToPlayer(stringProperty(additional, "id"), vector, properties)
+		if err != nil {
+			return nil, err
+		}
+
+		hits = append(hits, store.Hit{Player: player, Distance: float32(numberProperty(additional, "distance"))})
+	}
+
+	return hits, nil
+}
+
+func playerToObject(className string, player *store.Player) *models.Object {
+	// Weaviate has no map properties, pick rates are stored as two parallel arrays
+	operators := make([]string, 0, len(player.Stats.OperatorPickRates))
+	for operator := range player.Stats.OperatorPickRates {
+		operators = append(operators, operator)
+	}
+	sort.Strings(operators)
+
+	pickRates := make([]float64, 0, len(operators))
+	for _, operator := range operators {
+		pickRates = append(pickRates, player.Stats.OperatorPickRates[operator])
+	}
+
+	return &models.Object{
+		Class:  className,
+		ID:     objectID(player.ID, player.Stats.Season),
+		Vector: player.Vector,
+		Properties: map[string]interface{}{
+			"uuid":              player.ID,
+			"level":             player.Stats.Level,
+			"kost":              player.Stats.Kost,
+			"rank":              player.Stats.Rank,
+			"rankPoints":        player.Stats.RankPoints,
+			"season":            player.Stats.Season,
+			"winRate":           player.Stats.WinRate,
+			"headshotRate":      player.Stats.HeadshotRate,
+			"kd":                player.Stats.KD,
+			"matchesPlayed":     player.Stats.MatchesPlayed,
+			"timePlayed":        player.Stats.TimePlayed.Seconds(),
+			"preferredRole":     player.Stats.PreferredRole,
+			"operators":         operators,
+			"operatorPickRates": pickRates,
+			"schemaVersion":     player.SchemaVersion,
+			"updatedAt":         player.UpdatedAt.UTC().Format(time.RFC3339Nano),
+			"platform":          player.Platform,
+			"region":            player.Region,
+			"language":          player.Language,
+			"lastSeen":          player.LastSeen.UTC().Format(time.RFC3339Nano),
+		},
+	}
+}
+
+// objectID derives the object id of a player's season, name based like a UUIDv5.
+// Season 0 keeps the bare player id so records indexed before seasons were keyed
+// are still overwritten in place, Upsert deletes them once a season is indexed
+func objectID(id string, season int) strfmt.UUID {
+	if season == 0 {
+		return strfmt.UUID(id)
+	}
+
+	return nameUUID(fmt.Sprintf("%s/season/%d", id, season))
+}
+
+// nameUUID derives a stable object id from name, like a UUIDv5
+func nameUUID(name string) strfmt.UUID {
+	sum := sha1.Sum([]byte(name))
+
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return strfmt.UUID(fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16]))
+}
+
+// propertiesToPlayer rebuilds a player from an object, the player id is the uuid
+// property as the object id is per season
+func propertiesToPlayer(objectID string, vector []float32, properties map[string]interface{}) (*store.Player, error) {
+	id := stringProperty(properties, "uuid")
+	if id == "" {
+		id = objectID
+	}
+
+	updatedAt, err := time.Parse(time.RFC3339Nano, stringProperty(properties, "updatedAt"))
+	if err != nil {
+		return nil, fmt.Errorf("weaviate: object %s: %w", id, err)
+	}
+
+	lastSeen, err := time.Parse(time.RFC3339Nano, stringProperty(properties, "lastSeen"))
+	if err != nil {
+		return nil, fmt.Errorf("weaviate: object %s: %w", id, err)
+	}
+
+	operators, _ := properties["operators"].([]interface{})
+	pickRates, _ := properties["operatorPickRates"].([]interface{})
+
+	var operatorPickRates map[string]float64
+	if len(operators) > 0 && len(operators) == len(pickRates) {
+		operatorPickRates = make(map[string]float64, len(operators))
+		for i := range operators {
+			operator, _ := operators[i].(string)
+			operatorPickRates[operator], _ = pickRates[i].(float64)
+		}
+	}
+
+	return &store.Player{
+		ID: id,
+		Stats: vectors.Player{
+			Level:             int(numberProperty(properties, "level")),
+			Kost:              numberProperty(properties, "kost"),
+			Rank:              int(numberProperty(properties, "rank")),
+			RankPoints:        int(numberProperty(properties, "rankPoints")),
+			Season:            int(numberProperty(properties, "season")),
+			WinRate:           numberProperty(properties, "winRate"),
+			HeadshotRate:      numberProperty(properties, "headshotRate"),
+			KD:                numberProperty(properties, "kd"),
+			MatchesPlayed:     int(numberProperty(properties, "matchesPlayed")),
+			TimePlayed:        time.Duration(numberProperty(properties, "timePlayed") * float64(time.Second)),
+			PreferredRole:     stringProperty(properties, "preferredRole"),
+			OperatorPickRates: operatorPickRates,
+		},
+		Vector:        vector,
+		SchemaVersion: int(numberProperty(properties, "schemaVersion")),
+		UpdatedAt:     updatedAt,
+		Platform:      stringProperty(properties, "platform"),
+		Region:        stringProperty(properties, "region"),
+		Language:      stringProperty(properties, "language"),
+		LastSeen:      lastSeen,
+	}, nil
+}
+
+// numberProperty reads a numeric property, JSON decoding turns every number into a float64
+func numberProperty(properties map[string]interface{}, name string) float64 {
+	value, _ := properties[name].(float64)
+	return value
+}
+
+func stringProperty(properties map[string]interface{}, name string) string {
+	value, _ := properties[name].(string)
+	return value
+}
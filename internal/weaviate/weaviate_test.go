@@ -2,30 +2,34 @@ package weaviate
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/eliassebastian/r6index-recommendation/internal/store"
+	"github.com/eliassebastian/r6index-recommendation/internal/vectors"
+	"github.com/eliassebastian/r6index-recommendation/internal/weaviate/weaviatetest"
 	"github.com/go-openapi/strfmt"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate/graphql"
 	"github.com/weaviate/weaviate/entities/models"
 )
 
-func createSimpleTestClient() *weaviate.Client {
-	cfg := weaviate.Config{
-		Host:   "localhost:6464",
-		Scheme: "http",
-	}
+// createSimpleTestClient returns a client of an in-process Weaviate, which is shut
+// down with the test
+func createSimpleTestClient(t *testing.T) *weaviate.Client {
+	server := weaviatetest.NewServer()
+	t.Cleanup(server.Close)
 
-	return weaviate.New(cfg)
+	return server.Client()
 }
 
 func cleanupSimpleTestClient(t *testing.T, client *weaviate.Client) {
 	// Clean up test class and by that also all data
 	err := client.Schema().ClassDeleter().WithClassName("TestR6Index").Do(context.Background())
 	if err != nil {
-		cleanupSimpleTestClient(t, client)
 		t.Errorf("weaviate class delete error: got %v want nil", err)
 	}
 }
@@ -76,7 +80,7 @@ func createWeaviateTestSchemaWithVectorizorlessEuclideanClass(t *testing.T, clie
 
 func TestWeaviateData(t *testing.T) {
 	t.Run("Test Single Vector Object", func(t *testing.T) {
-		client := createSimpleTestClient()
+		client := createSimpleTestClient(t)
 		//cleanupSimpleTestClient(t, client)
 		createWeaviateTestSchemaWithVectorizorlessDefaultClass(t, client)
 
@@ -124,7 +128,7 @@ func TestWeaviateData(t *testing.T) {
 	})
 
 	t.Run("Test Batch Import Vector Object", func(t *testing.T) {
-		client := createSimpleTestClient()
+		client := createSimpleTestClient(t)
 		//cleanupSimpleTestClient(t, client)
 		createWeaviateTestSchemaWithVectorizorlessDefaultClass(t, client)
 
@@ -172,7 +176,7 @@ func TestWeaviateData(t *testing.T) {
 	})
 
 	t.Run("Test Batch Import Vector Object with Euclidean Distance", func(t *testing.T) {
-		client := createSimpleTestClient()
+		client := createSimpleTestClient(t)
 		//cleanupSimpleTestClient(t, client)
 		createWeaviateTestSchemaWithVectorizorlessEuclideanClass(t, client)
 
@@ -288,7 +292,7 @@ func TestWeaviateData(t *testing.T) {
 func TestDataToWeaviateObjectModel(t *testing.T) {
 
 	t.Run("convert input data to weaviate data model objects", func(t *testing.T) {
-		client := createSimpleTestClient()
+		client := createSimpleTestClient(t)
 		createWeaviateTestSchemaWithVectorizorlessEuclideanClass(t, client)
 
 		tests := []struct {
@@ -356,3 +360,110 @@ func TestDataToWeaviateObjectModel(t *testing.T) {
 	})
 
 }
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	client := createSimpleTestClient(t)
+	if err := Migrate(ctx, client, Class("Players_v4")); err != nil {
+		t.Fatalf("Migrate() error = %v, want nil", err)
+	}
+
+	s := New(client, "Players_v4")
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	player := func(id string, season int, vector ...float32) *store.Player {
+		return &store.Player{
+			ID:            id,
+			Stats:         vectors.Player{Level: 200, Kost: 0.5, Rank: 20, Season: season},
+			Vector:        vector,
+			SchemaVersion: 4,
+			UpdatedAt:     now,
+			Platform:      "pc",
+			LastSeen:      now,
+		}
+	}
+
+	players := []*store.Player{
+		player("6844b415-aa94-43c9-8823-9389e4816910", 29, 0, 0),
+		player("6844b415-aa94-43c9-8823-9389e4816910", 30, 1, 0),
+		player("6844b415-aa94-43c9-8823-9389e4816914", 30, 3, 0),
+		player("6844b415-aa94-43c9-8823-9389e4816923", 30, 6, 0),
+	}
+	players[3].Platform = "xbox"
+
+	if err := s.Upsert(ctx, players); err != nil {
+		t.Fatalf("Store.Upsert() error = %v, want nil", err)
+	}
+
+	if count, err := s.Count(ctx); err != nil || count != 4 {
+		t.Errorf("Store.Count() = %v, %v, want 4", count, err)
+	}
+
+	latest, err := s.Get(ctx, players[0].ID)
+	if err != nil || latest.Stats.Season != 30 || !reflect.DeepEqual(latest.Vector, []float32{1, 0}) {
+		t.Errorf("Store.Get() = %+v, %v, want the season 30 record", latest, err)
+	}
+
+	history, err := s.History(ctx, players[0].ID)
+	if err != nil || len(history) != 2 || history[0].Stats.Season != 29 {
+		t.Errorf("Store.History() = %d records, %v, want seasons 29 and 30", len(history), err)
+	}
+
+	if _, err := s.Get(ctx, "6844b415-aa94-43c9-8823-9389e4816999"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Store.Get() error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	hits, err := s.NearVector(ctx, store.Query{Vector: []float32{2, 0}, SchemaVersion: 4, Filter: store.Filter{{Property: "platform", Operator: store.Equal, Value: "pc"}}, Limit: 2})
+	if err != nil || len(hits) != 2 {
+		t.Fatalf("Store.NearVector() = %v, %v, want 2 hits", hits, err)
+	}
+
+	if hits[0].Distance != 1 || hits[1].Distance != 1 || hits[0].Player.ID == hits[1].Player.ID {
+		t.Errorf("Store.NearVector() = %v at %v and %v at %v, want the two players 1 away", hits[0].Player.ID, hits[0].Distance, hits[1].Player.ID, hits[1].Distance)
+	}
+
+	// vectors of other schema versions are never compared
+	if hits, err := s.NearVector(ctx, store.Query{Vector: []float32{2, 0}, SchemaVersion: 3, Limit: 10}); err != nil || len(hits) != 0 {
+		t.Errorf("Store.NearVector() of v3 = %v, %v, want no hits", hits, err)
+	}
+
+	var scanned int
+	for cursor := ""; ; {
+		page, next, err := s.Scan(ctx, cursor, 3)
+		if err != nil {
+			t.Fatalf("Store.Scan() error = %v, want nil", err)
+		}
+
+		scanned += len(page)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	if scanned != 4 {
+		t.Errorf("Store.Scan() returned %d records, want 4", scanned)
+	}
+
+	if err := s.Delete(ctx, []string{players[0].ID}); err != nil {
+		t.Fatalf("Store.Delete() error = %v, want nil", err)
+	}
+
+	if count, _ := s.Count(ctx); count != 2 {
+		t.Errorf("Store.Count() after Delete() = %v, want every season deleted", count)
+	}
+
+	// the first seasoned record replaces the one indexed without a season
+	unseasoned := "6844b415-aa94-43c9-8823-9389e4816931"
+	if err := s.Upsert(ctx, []*store.Player{player(unseasoned, 0, 2, 0)}); err != nil {
+		t.Fatalf("Store.Upsert() error = %v, want nil", err)
+	}
+
+	if err := s.Upsert(ctx, []*store.Player{player(unseasoned, 30, 2, 1)}); err != nil {
+		t.Fatalf("Store.Upsert() error = %v, want nil", err)
+	}
+
+	if history, err := s.History(ctx, unseasoned); err != nil || len(history) != 1 || history[0].Stats.Season != 30 {
+		t.Errorf("Store.History() = %d records, %v, want only season 30", len(history), err)
+	}
+}
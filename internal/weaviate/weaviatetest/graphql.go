@@ -0,0 +1,244 @@
+package weaviatetest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// selection is a field of a GraphQL query with its arguments and sub-selections
+type selection struct {
+	name   string
+	args   map[string]interface{}
+	fields []selection
+}
+
+// field returns the sub-selection called name
+func (s selection) field(name string) (selection, bool) {
+	for _, field := range s.fields {
+		if field.name == name {
+			return field, true
+		}
+	}
+	return selection{}, false
+}
+
+// enum is an unquoted GraphQL value, like Equal or asc
+type enum string
+
+// parser reads the subset of GraphQL the client builds: nested selections with
+// arguments whose values are strings, numbers, enums, lists and objects
+type parser struct {
+	input string
+	pos   int
+}
+
+// parseQuery parses a query document, { ... }, into its top level selections
+func parseQuery(query string) ([]selection, error) {
+	p := &parser{input: query}
+
+	fields, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.skip(); p.pos != len(p.input) {
+		return nil, p.errorf("unexpected %q after the query", p.input[p.pos:])
+	}
+	return fields, nil
+}
+
+func (p *parser) selectionSet() ([]selection, error) {
+	if err := p.expect('{'); err != nil {
+		return nil, err
+	}
+
+	var fields []selection
+	for {
+		if p.peek() == '}' {
+			p.pos++
+			return fields, nil
+		}
+
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+
+		field := selection{name: name}
+		if p.peek() == '(' {
+			if field.args, err = p.arguments(); err != nil {
+				return nil, err
+			}
+		}
+
+		if p.peek() == '{' {
+			if field.fields, err = p.selectionSet(); err != nil {
+				return nil, err
+			}
+		}
+
+		fields = append(fields, field)
+	}
+}
+
+func (p *parser) arguments() (map[string]interface{}, error) {
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+
+	args := map[string]interface{}{}
+	for p.peek() != ')' {
+		name, value, err := p.pair()
+		if err != nil {
+			return nil, err
+		}
+		args[name] = value
+	}
+
+	p.pos++
+	return args, nil
+}
+
+func (p *parser) pair() (string, interface{}, error) {
+	name, err := p.name()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := p.expect(':'); err != nil {
+		return "", nil, err
+	}
+
+	value, err := p.value()
+	return name, value, err
+}
+
+func (p *parser) value() (interface{}, error) {
+	switch c := p.peek(); {
+	case c == '{':
+		p.pos++
+		object := map[string]interface{}{}
+		for p.peek() != '}' {
+			name, value, err := p.pair()
+			if err != nil {
+				return nil, err
+			}
+			object[name] = value
+		}
+		p.pos++
+		return object, nil
+
+	case c == '[':
+		p.pos++
+		list := []interface{}{}
+		for p.peek() != ']' {
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		p.pos++
+		return list, nil
+
+	case c == '"':
+		return p.string()
+
+	case c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+		return p.number()
+
+	case c == 0:
+		return nil, p.errorf("unexpected end of query")
+	}
+
+	name, err := p.name()
+	switch name {
+	case "true", "false":
+		return name == "true", err
+	case "null":
+		return nil, err
+	}
+	return enum(name), err
+}
+
+func (p *parser) string() (string, error) {
+	start := p.pos
+	for p.pos++; p.pos < len(p.input) && p.input[p.pos] != '"'; p.pos++ {
+		if p.input[p.pos] == '\\' {
+			p.pos++
+		}
+	}
+
+	if p.pos >= len(p.input) {
+		return "", p.errorf("unterminated string")
+	}
+
+	p.pos++
+	return strconv.Unquote(p.input[start:p.pos])
+}
+
+func (p *parser) number() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.input) && strings.IndexByte("+-.0123456789eE", p.input[p.pos]) >= 0 {
+		p.pos++
+	}
+
+	number, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+	if err != nil {
+		return 0, p.errorf("invalid number %q", p.input[start:p.pos])
+	}
+	return number, nil
+}
+
+func (p *parser) name() (string, error) {
+	p.skip()
+
+	start := p.pos
+	for p.pos < len(p.input) && isNameByte(p.input[p.pos], p.pos > start) {
+		p.pos++
+	}
+
+	if p.pos == start {
+		if p.pos == len(p.input) {
+			return "", p.errorf("unexpected end of query")
+		}
+		return "", p.errorf("unexpected %q", p.input[p.pos])
+	}
+	return p.input[start:p.pos], nil
+}
+
+func isNameByte(c byte, inside bool) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (inside && c >= '0' && c <= '9')
+}
+
+func (p *parser) expect(c byte) error {
+	if p.peek() != c {
+		if p.pos == len(p.input) {
+			return p.errorf("expected %q, got the end of the query", c)
+		}
+		return p.errorf("expected %q, got %q", c, p.input[p.pos])
+	}
+
+	p.pos++
+	return nil
+}
+
+// peek returns the next significant byte without consuming it, 0 at the end
+func (p *parser) peek() byte {
+	if p.skip(); p.pos == len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+// skip moves past whitespace and commas, which GraphQL ignores
+func (p *parser) skip() {
+	for p.pos < len(p.input) && strings.IndexByte(" \t\r\n,", p.input[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("graphql: at %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
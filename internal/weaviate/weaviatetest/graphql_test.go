@@ -0,0 +1,47 @@
+package weaviatetest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	query := `{Get {Players_v4 (where:{operator: And operands:[{operator: Equal path: ["uuid"] valueText: "a \"b\""},{operator: GreaterThan path: ["season"] valueNumber: 2.5e+01}]}, nearVector:{vector: [1,-0.5]}, limit: 5, sort:[{path:["season"] order:desc}]) {uuid _additional {id distance}}}}`
+
+	operations, err := parseQuery(query)
+	if err != nil {
+		t.Fatalf("parseQuery() error = %v, want nil", err)
+	}
+
+	if len(operations) != 1 || operations[0].name != "Get" || len(operations[0].fields) != 1 {
+		t.Fatalf("parseQuery() = %+v, want a single Get", operations)
+	}
+
+	class := operations[0].fields[0]
+	where := map[string]interface{}{
+		"operator": enum("And"),
+		"operands": []interface{}{
+			map[string]interface{}{"operator": enum("Equal"), "path": []interface{}{"uuid"}, "valueText": `a "b"`},
+			map[string]interface{}{"operator": enum("GreaterThan"), "path": []interface{}{"season"}, "valueNumber": 25.0},
+		},
+	}
+
+	if class.name != "Players_v4" || !reflect.DeepEqual(class.args["where"], where) {
+		t.Errorf("parseQuery() where = %#v, want %#v", class.args["where"], where)
+	}
+
+	if class.args["limit"] != 5.0 || !reflect.DeepEqual(class.args["nearVector"], map[string]interface{}{"vector": []interface{}{1.0, -0.5}}) {
+		t.Errorf("parseQuery() args = %v, want limit 5 and the near vector", class.args)
+	}
+
+	additional, ok := class.field("_additional")
+	if len(class.fields) != 2 || !ok || len(additional.fields) != 2 || additional.fields[1].name != "distance" {
+		t.Errorf("parseQuery() fields = %+v, want uuid and _additional id and distance", class.fields)
+	}
+
+	for _, invalid := range []string{"", "{Get {Players", `{Get {Players (limit: ) {uuid}}}`, `{Get {Players (where:{valueText: "a}) {uuid}}}`, "{Get {}} }"} {
+		if _, err := parseQuery(invalid); err == nil {
+			t.Errorf("parseQuery(%q) error = nil, want an error", invalid)
+		}
+	}
+}
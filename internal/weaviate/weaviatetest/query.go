@@ -0,0 +1,509 @@
+package weaviatetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+// defaultLimit is the number of results of a query without a limit
+const defaultLimit = 25
+
+func (s *Server) serveGraphQL(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Query string `json:"query"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+
+	operations, err := parseQuery(body.Query)
+	if err != nil {
+		writeJSON(w, http.StatusOK, graphQLError(err))
+		return
+	}
+
+	data := map[string]models.JSONObject{}
+	for _, operation := range operations {
+		results := map[string]interface{}{}
+		for _, class := range operation.fields {
+			var result interface{}
+			switch operation.name {
+			case "Get":
+				result, err = s.get(class)
+			case "Aggregate":
+				result, err = s.aggregate(class)
+			default:
+				err = fmt.Errorf("%s queries are not supported", operation.name)
+			}
+
+			if err != nil {
+				writeJSON(w, http.StatusOK, graphQLError(err))
+				return
+			}
+			results[class.name] = result
+		}
+		data[operation.name] = results
+	}
+
+	writeJSON(w, http.StatusOK, models.GraphQLResponse{Data: data})
+}
+
+func graphQLError(err error) models.GraphQLResponse {
+	return models.GraphQLResponse{Errors: []*models.GraphQLError{{Message: err.Error()}}}
+}
+
+// hit is an object matched by a query, with its distance to the query vector
+type hit struct {
+	object   *models.Object
+	distance float64
+}
+
+// get runs a Get query on a single class
+func (s *Server) get(query selection) ([]interface{}, error) {
+	hits, near, err := s.search(query)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]interface{}, 0, len(hits))
+	for _, hit := range hits {
+		properties, _ := hit.object.Properties.(map[string]interface{})
+
+		result := map[string]interface{}{}
+		for _, field := range query.fields {
+			if field.name != "_additional" {
+				result[field.name] = properties[field.name]
+				continue
+			}
+
+			additional := map[string]interface{}{}
+			for _, extra := range field.fields {
+				switch extra.name {
+				case "id":
+					additional["id"] = hit.object.ID
+				case "vector":
+					additional["vector"] = hit.object.Vector
+				case "distance":
+					additional["distance"] = nil
+					if near {
+						additional["distance"] = hit.distance
+					}
+				default:
+					return nil, fmt.Errorf("_additional %s is not supported", extra.name)
+				}
+			}
+			result["_additional"] = additional
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// aggregate runs an Aggregate query on a single class, only the meta count is supported
+func (s *Server) aggregate(query selection) ([]interface{}, error) {
+	if _, ok := s.classes[query.name]; !ok {
+		return nil, fmt.Errorf("Cannot query field %q on type \"AggregateObjectsObj\".", query.name)
+	}
+
+	count := 0
+	for _, object := range s.sorted(query.name) {
+		ok, err := matchArgs(s.classes[query.name], object, query.args)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			count++
+		}
+	}
+
+	result := map[string]interface{}{}
+	for _, field := range query.fields {
+		if field.name != "meta" {
+			return nil, fmt.Errorf("aggregating %s is not supported", field.name)
+		}
+		result["meta"] = map[string]interface{}{"count": count}
+	}
+	return []interface{}{result}, nil
+}
+
+// search returns the objects a Get query matches in order, and whether they were
+// ordered by distance to a query vector
+func (s *Server) search(query selection) ([]hit, bool, error) {
+	class, ok := s.classes[query.name]
+	if !ok {
+		return nil, false, fmt.Errorf("Cannot query field %q on type \"GetObjectsObj\".", query.name)
+	}
+
+	vector, near, err := s.queryVector(query)
+	if err != nil {
+		return nil, false, err
+	}
+
+	after, _ := query.args["after"].(string)
+	if after != "" && (near || query.args["where"] != nil) {
+		return nil, false, fmt.Errorf("after can't be combined with where or near filters")
+	}
+
+	config, _ := class.VectorIndexConfig.(map[string]interface{})
+	distance, _ := config["distance"].(string)
+
+	var hits []hit
+	for _, object := range s.sorted(query.name) {
+		if after != "" && string(object.ID) <= after {
+			continue
+		}
+
+		ok, err := matchArgs(class, object, query.args)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if !ok {
+			continue
+		}
+
+		if !near {
+			hits = append(hits, hit{object: object})
+			continue
+		}
+
+		if len(object.Vector) != len(vector) {
+			continue
+		}
+
+		d := measure(distance, vector, object.Vector)
+		if max, ok := nearArgument(query, "distance"); ok && d > max {
+			continue
+		}
+		hits = append(hits, hit{object: object, distance: d})
+	}
+
+	if near {
+		sort.SliceStable(hits, func(i, j int) bool { return hits[i].distance < hits[j].distance })
+	} else if err := sortHits(hits, query.args["sort"]); err != nil {
+		return nil, false, err
+	}
+
+	offset, _ := query.args["offset"].(float64)
+	if int(offset) >= len(hits) {
+		return nil, near, nil
+	}
+	hits = hits[int(offset):]
+
+	limit := float64(defaultLimit)
+	if value, ok := query.args["limit"].(float64); ok {
+		limit = value
+	}
+
+	if int(limit) < len(hits) {
+		hits = hits[:int(limit)]
+	}
+	return hits, near, nil
+}
+
+// queryVector returns the vector of a nearVector or nearObject argument
+func (s *Server) queryVector(query selection) ([]float32, bool, error) {
+	if nearVector, ok := query.args["nearVector"].(map[string]interface{}); ok {
+		values, _ := nearVector["vector"].([]interface{})
+
+		vector := make([]float32, 0, len(values))
+		for _, value := range values {
+			number, ok := value.(float64)
+			if !ok {
+				return nil, false, fmt.Errorf("nearVector vector must be numbers")
+			}
+			vector = append(vector, float32(number))
+		}
+		return vector, true, nil
+	}
+
+	if nearObject, ok := query.args["nearObject"].(map[string]interface{}); ok {
+		id, _ := nearObject["id"].(string)
+
+		object := s.find(query.name, strfmt.UUID(id))
+		if object == nil {
+			return nil, false, fmt.Errorf("nearObject: object %s not found", id)
+		}
+		return object.Vector, true, nil
+	}
+
+	return nil, false, nil
+}
+
+// nearArgument returns a numeric argument of the near filter of query
+func nearArgument(query selection, name string) (float64, bool) {
+	for _, filter := range []string{"nearVector", "nearObject"} {
+		if near, ok := query.args[filter].(map[string]interface{}); ok {
+			value, ok := near[name].(float64)
+			return value, ok
+		}
+	}
+	return 0, false
+}
+
+// measure returns the distance between two vectors under a class's distance metric
+func measure(metric string, a, b []float32) float64 {
+	var dot, normA, normB, squared float64
+	for i := range a {
+		x, y := float64(a[i]), float64(b[i])
+		dot += x * y
+		normA += x * x
+		normB += y * y
+		squared += (x - y) * (x - y)
+	}
+
+	switch metric {
+	case "l2-squared":
+		return squared
+	case "dot":
+		return -dot
+	}
+
+	if normA == 0 || normB == 0 {
+		return 1
+	}
+	return 1 - dot/math.Sqrt(normA*normB)
+}
+
+// sortHits orders hits by the sort argument of a query, by id when there is none
+func sortHits(hits []hit, argument interface{}) error {
+	clauses, _ := argument.([]interface{})
+	for i := len(clauses) - 1; i >= 0; i-- {
+		clause, _ := clauses[i].(map[string]interface{})
+		path, _ := clause["path"].([]interface{})
+		order, _ := clause["order"].(enum)
+		if len(path) != 1 {
+			return fmt.Errorf("sort path must name a single property")
+		}
+
+		property, _ := path[0].(string)
+		sort.SliceStable(hits, func(i, j int) bool {
+			a, _ := hits[i].object.Properties.(map[string]interface{})
+			b, _ := hits[j].object.Properties.(map[string]interface{})
+
+			c, _ := compare(a[property], b[property])
+			if order == "desc" {
+				return c > 0
+			}
+			return c < 0
+		})
+	}
+	return nil
+}
+
+// matchArgs reports whether object of class matches the where argument of a query
+func matchArgs(class *models.Class, object *models.Object, args map[string]interface{}) (bool, error) {
+	where, ok := args["where"]
+	if !ok {
+		return true, nil
+	}
+
+	// the parsed argument has the shape of the REST where filter
+	raw, err := json.Marshal(where)
+	if err != nil {
+		return false, err
+	}
+
+	var filter models.WhereFilter
+	if err := json.Unmarshal(raw, &filter); err != nil {
+		return false, fmt.Errorf("where: %v", err)
+	}
+
+	return matchWhere(class, object, &filter)
+}
+
+// matchWhere reports whether object of class matches filter. Like Weaviate, a text
+// value equals the filter's when it holds every token of it
+func matchWhere(class *models.Class, object *models.Object, filter *models.WhereFilter) (bool, error) {
+	switch filter.Operator {
+	case "And", "Or":
+		for _, operand := range filter.Operands {
+			ok, err := matchWhere(class, object, operand)
+			if err != nil {
+				return false, err
+			}
+
+			if ok != (filter.Operator == "And") {
+				return ok, nil
+			}
+		}
+		return filter.Operator == "And", nil
+	}
+
+	if len(filter.Path) != 1 {
+		return false, fmt.Errorf("where path must name a single property")
+	}
+
+	want, err := filterValue(filter)
+	if err != nil {
+		return false, err
+	}
+
+	var values []interface{}
+	if filter.Path[0] == "id" {
+		values = []interface{}{string(object.ID)}
+	} else {
+		properties, _ := object.Properties.(map[string]interface{})
+		switch value := properties[filter.Path[0]].(type) {
+		case nil:
+		case []interface{}:
+			values = value
+		default:
+			values = []interface{}{value}
+		}
+	}
+
+	var tokenization string
+	if class != nil {
+		if property := propertyOf(class, filter.Path[0]); property != nil {
+			tokenization = property.Tokenization
+		}
+	}
+
+	// array properties match when any of their values does
+	for _, value := range values {
+		if text, ok := want.(string); ok && tokenization != "" && (filter.Operator == "Equal" || filter.Operator == "NotEqual") {
+			value, _ := value.(string)
+			if contains(tokens(tokenization, value), tokens(tokenization, text)) == (filter.Operator == "Equal") {
+				return true, nil
+			}
+			continue
+		}
+
+		if _, isDate := want.(time.Time); isDate {
+			text, _ := value.(string)
+			if value, err = time.Parse(time.RFC3339Nano, text); err != nil {
+				continue
+			}
+		}
+
+		c, ok := compare(value, want)
+		if !ok {
+			continue
+		}
+
+		var matched bool
+		switch filter.Operator {
+		case "Equal":
+			matched = c == 0
+		case "NotEqual":
+			matched = c != 0
+		case "GreaterThan":
+			matched = c > 0
+		case "GreaterThanEqual":
+			matched = c >= 0
+		case "LessThan":
+			matched = c < 0
+		case "LessThanEqual":
+			matched = c <= 0
+		default:
+			return false, fmt.Errorf("where operator %s is not supported", filter.Operator)
+		}
+
+		if matched {
+			return true, nil
+		}
+	}
+
+	return filter.Operator == "NotEqual" && len(values) == 0, nil
+}
+
+// tokens splits text like Weaviate's tokenization of a property
+func tokens(tokenization, text string) []string {
+	switch tokenization {
+	case "field":
+		return []string{strings.TrimSpace(text)}
+	case "whitespace":
+		return strings.Fields(text)
+	case "lowercase":
+		return strings.Fields(strings.ToLower(text))
+	}
+
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// contains reports whether have holds every one of want, and want isn't empty
+func contains(have, want []string) bool {
+	if len(want) == 0 {
+		return false
+	}
+
+	set := make(map[string]bool, len(have))
+	for _, token := range have {
+		set[token] = true
+	}
+
+	for _, token := range want {
+		if !set[token] {
+			return false
+		}
+	}
+	return true
+}
+
+// filterValue returns the value a where condition compares with
+func filterValue(filter *models.WhereFilter) (interface{}, error) {
+	switch {
+	case filter.ValueText != nil:
+		return *filter.ValueText, nil
+	case filter.ValueString != nil:
+		return *filter.ValueString, nil
+	case filter.ValueNumber != nil:
+		return *filter.ValueNumber, nil
+	case filter.ValueInt != nil:
+		return float64(*filter.ValueInt), nil
+	case filter.ValueBoolean != nil:
+		return *filter.ValueBoolean, nil
+	case filter.ValueDate != nil:
+		return time.Parse(time.RFC3339Nano, *filter.ValueDate)
+	}
+	return nil, fmt.Errorf("where condition on %s has no value", strings.Join(filter.Path, "."))
+}
+
+// compare orders two values of the same type, ok is false when they can't be compared
+func compare(a, b interface{}) (int, bool) {
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b), true
+		}
+	case float64:
+		if b, ok := b.(float64); ok {
+			switch {
+			case a < b:
+				return -1, true
+			case a > b:
+				return 1, true
+			}
+			return 0, true
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			if a == b {
+				return 0, true
+			}
+			if !a {
+				return -1, true
+			}
+			return 1, true
+		}
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			return a.Compare(b), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,50 @@
+package weaviatetest
+
+import (
+	"testing"
+
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+func TestMatchWhereTokenization(t *testing.T) {
+	id := "6844b415-aa94-43c9-8823-9389e4816910"
+	object := &models.Object{Properties: map[string]interface{}{"uuid": id, "platform": "PC Xbox"}}
+
+	equal := func(property, value string) *models.WhereFilter {
+		return &models.WhereFilter{Operator: "Equal", Path: []string{property}, ValueText: &value}
+	}
+
+	testCases := []struct {
+		tokenization string
+		filter       *models.WhereFilter
+		want         bool
+	}{
+		// word matches any value holding every word of the filter's
+		{"word", equal("uuid", "aa94"), true},
+		{"word", equal("uuid", "AA94 6844b415"), true},
+		{"word", equal("uuid", "aa95"), false},
+		{"field", equal("uuid", id), true},
+		{"field", equal("uuid", "aa94"), false},
+		{"field", equal("uuid", " "+id+" "), true},
+		{"whitespace", equal("platform", "Xbox"), true},
+		{"whitespace", equal("platform", "xbox"), false},
+		{"lowercase", equal("platform", "xbox"), true},
+	}
+
+	for _, testCase := range testCases {
+		class := &models.Class{Properties: []*models.Property{
+			{Name: "uuid", DataType: []string{"text"}, Tokenization: testCase.tokenization},
+			{Name: "platform", DataType: []string{"text"}, Tokenization: testCase.tokenization},
+		}}
+
+		got, err := matchWhere(class, object, testCase.filter)
+		if err != nil || got != testCase.want {
+			t.Errorf("matchWhere(%s, %s = %q) = %v, %v, want %v", testCase.tokenization, testCase.filter.Path[0], *testCase.filter.ValueText, got, err, testCase.want)
+		}
+
+		testCase.filter.Operator = "NotEqual"
+		if got, _ := matchWhere(class, object, testCase.filter); got == testCase.want {
+			t.Errorf("matchWhere(%s, %s != %q) = %v, want %v", testCase.tokenization, testCase.filter.Path[0], *testCase.filter.ValueText, got, !testCase.want)
+		}
+	}
+}
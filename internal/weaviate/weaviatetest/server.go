@@ -0,0 +1,420 @@
+// Package weaviatetest runs an in-process stand-in for the subset of the Weaviate REST
+// and GraphQL API this project uses, so stores can be tested without a Weaviate
+package weaviatetest
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+// Version is the Weaviate version the stand-in reports
+const Version = "1.18.2"
+
+// Server is a Weaviate holding its schema and objects in memory. Like Weaviate with
+// auto-schema enabled, writing to an unknown class or property creates it
+type Server struct {
+	*httptest.Server
+
+	mutex   sync.Mutex
+	classes map[string]*models.Class
+	objects map[string]map[strfmt.UUID]*models.Object
+}
+
+// NewServer starts a server, callers must Close it when done
+func NewServer() *Server {
+	s := &Server{
+		classes: map[string]*models.Class{},
+		objects: map[string]map[strfmt.UUID]*models.Object{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Client returns a client talking to the server
+func (s *Server) Client() *weaviate.Client {
+	address, _ := url.Parse(s.URL)
+	return weaviate.New(weaviate.Config{Host: address.Host, Scheme: address.Scheme})
+}
+
+// Objects returns the number of objects stored in class
+func (s *Server) Objects(class string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.objects[class])
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	path := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1"), "/"), "/")
+	switch {
+	case path[0] == "meta" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"version": Version, "modules": map[string]interface{}{}})
+	case path[0] == "schema":
+		s.serveSchema(w, r, path[1:])
+	case path[0] == "objects":
+		s.serveObjects(w, r, path[1:])
+	case path[0] == "batch" && len(path) == 2 && path[1] == "objects":
+		s.serveBatch(w, r)
+	case path[0] == "graphql" && r.Method == http.MethodPost:
+		s.serveGraphQL(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "%s %s is not supported", r.Method, r.URL.Path)
+	}
+}
+
+func (s *Server) serveSchema(w http.ResponseWriter, r *http.Request, path []string) {
+	switch {
+	case len(path) == 0 && r.Method == http.MethodGet:
+		names := make([]string, 0, len(s.classes))
+		for name := range s.classes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		classes := make([]*models.Class, 0, len(names))
+		for _, name := range names {
+			classes = append(classes, s.classes[name])
+		}
+		writeJSON(w, http.StatusOK, models.Schema{Classes: classes})
+
+	case len(path) == 0 && r.Method == http.MethodPost:
+		var class models.Class
+		if !readJSON(w, r, &class) {
+			return
+		}
+
+		if _, ok := s.classes[class.Class]; ok {
+			writeError(w, http.StatusUnprocessableEntity, "class name %q already exists", class.Class)
+			return
+		}
+
+		s.classes[class.Class] = withDefaults(&class)
+		writeJSON(w, http.StatusOK, s.classes[class.Class])
+
+	case len(path) == 1 && r.Method == http.MethodGet:
+		class, ok := s.classes[path[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "class %q not found", path[0])
+			return
+		}
+		writeJSON(w, http.StatusOK, class)
+
+	case len(path) == 1 && r.Method == http.MethodDelete:
+		delete(s.classes, path[0])
+		delete(s.objects, path[0])
+		w.WriteHeader(http.StatusOK)
+
+	case len(path) == 2 && path[1] == "properties" && r.Method == http.MethodPost:
+		class, ok := s.classes[path[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "class %q not found", path[0])
+			return
+		}
+
+		var property models.Property
+		if !readJSON(w, r, &property) {
+			return
+		}
+
+		if propertyOf(class, property.Name) != nil {
+			writeError(w, http.StatusUnprocessableEntity, "property %q already exists on %s", property.Name, class.Class)
+			return
+		}
+
+		class.Properties = append(class.Properties, withTokenization(&property))
+		writeJSON(w, http.StatusOK, property)
+
+	default:
+		writeError(w, http.StatusNotFound, "%s %s is not supported", r.Method, r.URL.Path)
+	}
+}
+
+func (s *Server) serveObjects(w http.ResponseWriter, r *http.Request, path []string) {
+	switch {
+	case len(path) == 0 && r.Method == http.MethodPost:
+		var object models.Object
+		if !readJSON(w, r, &object) {
+			return
+		}
+
+		if err := s.put(&object); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "%v", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, object)
+
+	case len(path) == 0 && r.Method == http.MethodGet:
+		s.listObjects(w, r.URL.Query())
+
+	case (len(path) == 1 || len(path) == 2) && r.Method == http.MethodGet:
+		class, id := "", strfmt.UUID(path[len(path)-1])
+		if len(path) == 2 {
+			class = path[0]
+		}
+
+		object := s.find(class, id)
+		if object == nil {
+			writeError(w, http.StatusNotFound, "object %s not found", id)
+			return
+		}
+		writeJSON(w, http.StatusOK, view(object, r.URL.Query()))
+
+	default:
+		writeError(w, http.StatusNotFound, "%s %s is not supported", r.Method, r.URL.Path)
+	}
+}
+
+// listObjects lists the objects of a class in id order, like the cursor API
+func (s *Server) listObjects(w http.ResponseWriter, query url.Values) {
+	limit := 25
+	if value, err := strconv.Atoi(query.Get("limit")); err == nil {
+		limit = value
+	}
+
+	var objects []*models.Object
+	for _, object := range s.sorted(query.Get("class")) {
+		if after := query.Get("after"); after != "" && string(object.ID) <= after {
+			continue
+		}
+
+		if len(objects) == limit {
+			break
+		}
+		objects = append(objects, view(object, query))
+	}
+
+	writeJSON(w, http.StatusOK, models.ObjectsListResponse{Objects: objects, TotalResults: int64(len(objects))})
+}
+
+func (s *Server) serveBatch(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var body struct {
+			Objects []*models.Object `json:"objects"`
+		}
+		if !readJSON(w, r, &body) {
+			return
+		}
+
+		results := make([]models.ObjectsGetResponse, 0, len(body.Objects))
+		for _, object := range body.Objects {
+			status := "SUCCESS"
+			result := &models.ObjectsGetResponseAO2Result{Status: &status}
+			if err := s.put(object); err != nil {
+				status = "FAILED"
+				result.Errors = &models.ErrorResponse{Error: []*models.ErrorResponseErrorItems0{{Message: err.Error()}}}
+			}
+			results = append(results, models.ObjectsGetResponse{Object: *object, Result: result})
+		}
+		writeJSON(w, http.StatusOK, results)
+
+	case http.MethodDelete:
+		var body models.BatchDelete
+		if !readJSON(w, r, &body) {
+			return
+		}
+
+		if body.Match == nil || body.Match.Where == nil {
+			writeError(w, http.StatusUnprocessableEntity, "match.where is required")
+			return
+		}
+
+		var matches int64
+		for id, object := range s.objects[body.Match.Class] {
+			ok, err := matchWhere(s.classes[body.Match.Class], object, body.Match.Where)
+			if err != nil {
+				writeError(w, http.StatusUnprocessableEntity, "%v", err)
+				return
+			}
+
+			if ok {
+				matches++
+				if body.DryRun == nil || !*body.DryRun {
+					delete(s.objects[body.Match.Class], id)
+				}
+			}
+		}
+
+		writeJSON(w, http.StatusOK, models.BatchDeleteResponse{
+			Match:   &models.BatchDeleteResponseMatch{Class: body.Match.Class, Where: body.Match.Where},
+			DryRun:  body.DryRun,
+			Results: &models.BatchDeleteResponseResults{Matches: matches, Successful: matches, Limit: 10000},
+		})
+
+	default:
+		writeError(w, http.StatusNotFound, "%s %s is not supported", r.Method, r.URL.Path)
+	}
+}
+
+// put creates or replaces an object, creating its class and properties when needed
+func (s *Server) put(object *models.Object) error {
+	if object.Class == "" {
+		return fmt.Errorf("object has no class")
+	}
+
+	if object.ID == "" {
+		object.ID = newUUID()
+	} else if !strfmt.IsUUID(string(object.ID)) {
+		return fmt.Errorf("id %q is not a uuid", object.ID)
+	}
+
+	class, ok := s.classes[object.Class]
+	if !ok {
+		class = withDefaults(&models.Class{Class: object.Class})
+		s.classes[object.Class] = class
+	}
+
+	properties, _ := object.Properties.(map[string]interface{})
+	for name, value := range properties {
+		if propertyOf(class, name) == nil {
+			class.Properties = append(class.Properties, withTokenization(&models.Property{Name: name, DataType: []string{inferDataType(value)}}))
+		}
+	}
+
+	if s.objects[object.Class] == nil {
+		s.objects[object.Class] = map[strfmt.UUID]*models.Object{}
+	}
+
+	now := time.Now().UnixMilli()
+	object.CreationTimeUnix, object.LastUpdateTimeUnix = now, now
+	stored := *object
+	stored.Properties = properties
+	s.objects[object.Class][object.ID] = &stored
+	return nil
+}
+
+// find returns the object with id, in class unless class is empty
+func (s *Server) find(class string, id strfmt.UUID) *models.Object {
+	for name, objects := range s.objects {
+		if class != "" && name != class {
+			continue
+		}
+
+		if object, ok := objects[id]; ok {
+			return object
+		}
+	}
+	return nil
+}
+
+// sorted returns the objects of class in id order
+func (s *Server) sorted(class string) []*models.Object {
+	objects := make([]*models.Object, 0, len(s.objects[class]))
+	for _, object := range s.objects[class] {
+		objects = append(objects, object)
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].ID < objects[j].ID })
+	return objects
+}
+
+// view returns the object as the REST API shows it, the vector only when included
+func view(object *models.Object, query url.Values) *models.Object {
+	viewed := *object
+	if !strings.Contains(query.Get("include"), "vector") {
+		viewed.Vector = nil
+	}
+	return &viewed
+}
+
+// withDefaults fills in what Weaviate sets on a class created without it
+func withDefaults(class *models.Class) *models.Class {
+	if class.Vectorizer == "" {
+		class.Vectorizer = "none"
+	}
+
+	config, _ := class.VectorIndexConfig.(map[string]interface{})
+	defaults := map[string]interface{}{"distance": "cosine", "ef": -1, "efConstruction": 128, "maxConnections": 64}
+	for key, value := range config {
+		defaults[key] = value
+	}
+	class.VectorIndexConfig = defaults
+
+	if class.VectorIndexType == "" {
+		class.VectorIndexType = "hnsw"
+	}
+
+	for _, property := range class.Properties {
+		withTokenization(property)
+	}
+	return class
+}
+
+// withTokenization tokenizes text properties by word unless they say otherwise
+func withTokenization(property *models.Property) *models.Property {
+	if property.Tokenization == "" && len(property.DataType) > 0 && (strings.HasPrefix(property.DataType[0], "text") || strings.HasPrefix(property.DataType[0], "string")) {
+		property.Tokenization = "word"
+	}
+	return property
+}
+
+func propertyOf(class *models.Class, name string) *models.Property {
+	for _, property := range class.Properties {
+		if property.Name == name {
+			return property
+		}
+	}
+	return nil
+}
+
+// inferDataType is the data type auto-schema gives a property first written with value
+func inferDataType(value interface{}) string {
+	switch value := value.(type) {
+	case bool:
+		return "boolean"
+	case float64, json.Number:
+		return "number"
+	case string:
+		if _, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return "date"
+		}
+		return "text"
+	case []interface{}:
+		if len(value) > 0 {
+			return inferDataType(value[0]) + "[]"
+		}
+	}
+	return "text"
+}
+
+func newUUID() strfmt.UUID {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return strfmt.UUID(fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16]))
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, target interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(target); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body: %v", err)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeJSON(w, status, models.ErrorResponse{Error: []*models.ErrorResponseErrorItems0{{Message: fmt.Sprintf(format, args...)}}})
+}
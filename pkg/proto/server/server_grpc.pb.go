@@ -23,6 +23,24 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type RecommendationServiceClient interface {
 	Index(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*Response, error)
+	BulkDelete(ctx context.Context, in *BulkDeleteRequest, opts ...grpc.CallOption) (*Response, error)
+	GetPlayer(ctx context.Context, in *GetPlayerRequest, opts ...grpc.CallOption) (*GetPlayerResponse, error)
+	Recommend(ctx context.Context, in *RecommendRequest, opts ...grpc.CallOption) (*RecommendResponse, error)
+	RecommendByStats(ctx context.Context, in *RecommendByStatsRequest, opts ...grpc.CallOption) (*RecommendResponse, error)
+	Block(ctx context.Context, in *BlockRequest, opts ...grpc.CallOption) (*Response, error)
+	Unblock(ctx context.Context, in *BlockRequest, opts ...grpc.CallOption) (*Response, error)
+	RecordTeammates(ctx context.Context, in *TeammatesRequest, opts ...grpc.CallOption) (*Response, error)
+	BuildSquad(ctx context.Context, in *SquadRequest, opts ...grpc.CallOption) (*SquadResponse, error)
+	// admin: estimated distribution of the raw feature values of indexed players
+	Statistics(ctx context.Context, in *StatisticsRequest, opts ...grpc.CallOption) (*StatisticsResponse, error)
+	// admin: re-embed every stored record into a new collection and switch reads over once done
+	Reindex(ctx context.Context, in *ReindexRequest, opts ...grpc.CallOption) (*ReindexResponse, error)
+	ReindexStatus(ctx context.Context, in *ReindexStatusRequest, opts ...grpc.CallOption) (*ReindexResponse, error)
+	// admin: switch back to the collection served before the last re-index
+	Rollback(ctx context.Context, in *RollbackRequest, opts ...grpc.CallOption) (*ReindexResponse, error)
+	// admin: configuration, quota use and request metrics of every tenant
+	Tenants(ctx context.Context, in *TenantsRequest, opts ...grpc.CallOption) (*TenantsResponse, error)
 }
 
 type recommendationServiceClient struct {
@@ -42,11 +60,155 @@ func (c *recommendationServiceClient) Index(ctx context.Context, in *Request, op
 	return out, nil
 }
 
+func (c *recommendationServiceClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/RecommendationService/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recommendationServiceClient) BulkDelete(ctx context.Context, in *BulkDeleteRequest, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/RecommendationService/BulkDelete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recommendationServiceClient) GetPlayer(ctx context.Context, in *GetPlayerRequest, opts ...grpc.CallOption) (*GetPlayerResponse, error) {
+	out := new(GetPlayerResponse)
+	err := c.cc.Invoke(ctx, "/RecommendationService/GetPlayer", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recommendationServiceClient) Recommend(ctx context.Context, in *RecommendRequest, opts ...grpc.CallOption) (*RecommendResponse, error) {
+	out := new(RecommendResponse)
+	err := c.cc.Invoke(ctx, "/RecommendationService/Recommend", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recommendationServiceClient) RecommendByStats(ctx context.Context, in *RecommendByStatsRequest, opts ...grpc.CallOption) (*RecommendResponse, error) {
+	out := new(RecommendResponse)
+	err := c.cc.Invoke(ctx, "/RecommendationService/RecommendByStats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recommendationServiceClient) Block(ctx context.Context, in *BlockRequest, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/RecommendationService/Block", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recommendationServiceClient) Unblock(ctx context.Context, in *BlockRequest, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/RecommendationService/Unblock", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recommendationServiceClient) RecordTeammates(ctx context.Context, in *TeammatesRequest, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/RecommendationService/RecordTeammates", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recommendationServiceClient) BuildSquad(ctx context.Context, in *SquadRequest, opts ...grpc.CallOption) (*SquadResponse, error) {
+	out := new(SquadResponse)
+	err := c.cc.Invoke(ctx, "/RecommendationService/BuildSquad", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recommendationServiceClient) Statistics(ctx context.Context, in *StatisticsRequest, opts ...grpc.CallOption) (*StatisticsResponse, error) {
+	out := new(StatisticsResponse)
+	err := c.cc.Invoke(ctx, "/RecommendationService/Statistics", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recommendationServiceClient) Reindex(ctx context.Context, in *ReindexRequest, opts ...grpc.CallOption) (*ReindexResponse, error) {
+	out := new(ReindexResponse)
+	err := c.cc.Invoke(ctx, "/RecommendationService/Reindex", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recommendationServiceClient) ReindexStatus(ctx context.Context, in *ReindexStatusRequest, opts ...grpc.CallOption) (*ReindexResponse, error) {
+	out := new(ReindexResponse)
+	err := c.cc.Invoke(ctx, "/RecommendationService/ReindexStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recommendationServiceClient) Rollback(ctx context.Context, in *RollbackRequest, opts ...grpc.CallOption) (*ReindexResponse, error) {
+	out := new(ReindexResponse)
+	err := c.cc.Invoke(ctx, "/RecommendationService/Rollback", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recommendationServiceClient) Tenants(ctx context.Context, in *TenantsRequest, opts ...grpc.CallOption) (*TenantsResponse, error) {
+	out := new(TenantsResponse)
+	err := c.cc.Invoke(ctx, "/RecommendationService/Tenants", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // RecommendationServiceServer is the server API for RecommendationService service.
 // All implementations must embed UnimplementedRecommendationServiceServer
 // for forward compatibility
 type RecommendationServiceServer interface {
 	Index(context.Context, *Request) (*Response, error)
+	Delete(context.Context, *DeleteRequest) (*Response, error)
+	BulkDelete(context.Context, *BulkDeleteRequest) (*Response, error)
+	GetPlayer(context.Context, *GetPlayerRequest) (*GetPlayerResponse, error)
+	Recommend(context.Context, *RecommendRequest) (*RecommendResponse, error)
+	RecommendByStats(context.Context, *RecommendByStatsRequest) (*RecommendResponse, error)
+	Block(context.Context, *BlockRequest) (*Response, error)
+	Unblock(context.Context, *BlockRequest) (*Response, error)
+	RecordTeammates(context.Context, *TeammatesRequest) (*Response, error)
+	BuildSquad(context.Context, *SquadRequest) (*SquadResponse, error)
+	// admin: estimated distribution of the raw feature values of indexed players
+	Statistics(context.Context, *StatisticsRequest) (*StatisticsResponse, error)
+	// admin: re-embed every stored record into a new collection and switch reads over once done
+	Reindex(context.Context, *ReindexRequest) (*ReindexResponse, error)
+	ReindexStatus(context.Context, *ReindexStatusRequest) (*ReindexResponse, error)
+	// admin: switch back to the collection served before the last re-index
+	Rollback(context.Context, *RollbackRequest) (*ReindexResponse, error)
+	// admin: configuration, quota use and request metrics of every tenant
+	Tenants(context.Context, *TenantsRequest) (*TenantsResponse, error)
 	mustEmbedUnimplementedRecommendationServiceServer()
 }
 
@@ -57,6 +219,48 @@ type UnimplementedRecommendationServiceServer struct {
 func (UnimplementedRecommendationServiceServer) Index(context.Context, *Request) (*Response, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Index not implemented")
 }
+func (UnimplementedRecommendationServiceServer) Delete(context.Context, *DeleteRequest) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedRecommendationServiceServer) BulkDelete(context.Context, *BulkDeleteRequest) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BulkDelete not implemented")
+}
+func (UnimplementedRecommendationServiceServer) GetPlayer(context.Context, *GetPlayerRequest) (*GetPlayerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPlayer not implemented")
+}
+func (UnimplementedRecommendationServiceServer) Recommend(context.Context, *RecommendRequest) (*RecommendResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Recommend not implemented")
+}
+func (UnimplementedRecommendationServiceServer) RecommendByStats(context.Context, *RecommendByStatsRequest) (*RecommendResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RecommendByStats not implemented")
+}
+func (UnimplementedRecommendationServiceServer) Block(context.Context, *BlockRequest) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Block not implemented")
+}
+func (UnimplementedRecommendationServiceServer) Unblock(context.Context, *BlockRequest) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Unblock not implemented")
+}
+func (UnimplementedRecommendationServiceServer) RecordTeammates(context.Context, *TeammatesRequest) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RecordTeammates not implemented")
+}
+func (UnimplementedRecommendationServiceServer) BuildSquad(context.Context, *SquadRequest) (*SquadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BuildSquad not implemented")
+}
+func (UnimplementedRecommendationServiceServer) Statistics(context.Context, *StatisticsRequest) (*StatisticsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Statistics not implemented")
+}
+func (UnimplementedRecommendationServiceServer) Reindex(context.Context, *ReindexRequest) (*ReindexResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Reindex not implemented")
+}
+func (UnimplementedRecommendationServiceServer) ReindexStatus(context.Context, *ReindexStatusRequest) (*ReindexResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReindexStatus not implemented")
+}
+func (UnimplementedRecommendationServiceServer) Rollback(context.Context, *RollbackRequest) (*ReindexResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Rollback not implemented")
+}
+func (UnimplementedRecommendationServiceServer) Tenants(context.Context, *TenantsRequest) (*TenantsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Tenants not implemented")
+}
 func (UnimplementedRecommendationServiceServer) mustEmbedUnimplementedRecommendationServiceServer() {}
 
 // UnsafeRecommendationServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -88,6 +292,258 @@ func _RecommendationService_Index_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _RecommendationService_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecommendationServiceServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/RecommendationService/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecommendationServiceServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecommendationService_BulkDelete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BulkDeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecommendationServiceServer).BulkDelete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/RecommendationService/BulkDelete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecommendationServiceServer).BulkDelete(ctx, req.(*BulkDeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecommendationService_GetPlayer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPlayerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecommendationServiceServer).GetPlayer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/RecommendationService/GetPlayer",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecommendationServiceServer).GetPlayer(ctx, req.(*GetPlayerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecommendationService_Recommend_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RecommendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecommendationServiceServer).Recommend(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/RecommendationService/Recommend",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecommendationServiceServer).Recommend(ctx, req.(*RecommendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecommendationService_RecommendByStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RecommendByStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecommendationServiceServer).RecommendByStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/RecommendationService/RecommendByStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecommendationServiceServer).RecommendByStats(ctx, req.(*RecommendByStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecommendationService_Block_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BlockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecommendationServiceServer).Block(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/RecommendationService/Block",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecommendationServiceServer).Block(ctx, req.(*BlockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecommendationService_Unblock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BlockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecommendationServiceServer).Unblock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/RecommendationService/Unblock",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecommendationServiceServer).Unblock(ctx, req.(*BlockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecommendationService_RecordTeammates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TeammatesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecommendationServiceServer).RecordTeammates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/RecommendationService/RecordTeammates",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecommendationServiceServer).RecordTeammates(ctx, req.(*TeammatesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecommendationService_BuildSquad_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SquadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecommendationServiceServer).BuildSquad(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/RecommendationService/BuildSquad",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecommendationServiceServer).BuildSquad(ctx, req.(*SquadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecommendationService_Statistics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatisticsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecommendationServiceServer).Statistics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/RecommendationService/Statistics",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecommendationServiceServer).Statistics(ctx, req.(*StatisticsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecommendationService_Reindex_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReindexRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecommendationServiceServer).Reindex(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/RecommendationService/Reindex",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecommendationServiceServer).Reindex(ctx, req.(*ReindexRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecommendationService_ReindexStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReindexStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecommendationServiceServer).ReindexStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/RecommendationService/ReindexStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecommendationServiceServer).ReindexStatus(ctx, req.(*ReindexStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecommendationService_Rollback_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RollbackRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecommendationServiceServer).Rollback(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/RecommendationService/Rollback",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecommendationServiceServer).Rollback(ctx, req.(*RollbackRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecommendationService_Tenants_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TenantsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecommendationServiceServer).Tenants(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/RecommendationService/Tenants",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecommendationServiceServer).Tenants(ctx, req.(*TenantsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // RecommendationService_ServiceDesc is the grpc.ServiceDesc for RecommendationService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -99,6 +555,62 @@ var RecommendationService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Index",
 			Handler:    _RecommendationService_Index_Handler,
 		},
+		{
+			MethodName: "Delete",
+			Handler:    _RecommendationService_Delete_Handler,
+		},
+		{
+			MethodName: "BulkDelete",
+			Handler:    _RecommendationService_BulkDelete_Handler,
+		},
+		{
+			MethodName: "GetPlayer",
+			Handler:    _RecommendationService_GetPlayer_Handler,
+		},
+		{
+			MethodName: "Recommend",
+			Handler:    _RecommendationService_Recommend_Handler,
+		},
+		{
+			MethodName: "RecommendByStats",
+			Handler:    _RecommendationService_RecommendByStats_Handler,
+		},
+		{
+			MethodName: "Block",
+			Handler:    _RecommendationService_Block_Handler,
+		},
+		{
+			MethodName: "Unblock",
+			Handler:    _RecommendationService_Unblock_Handler,
+		},
+		{
+			MethodName: "RecordTeammates",
+			Handler:    _RecommendationService_RecordTeammates_Handler,
+		},
+		{
+			MethodName: "BuildSquad",
+			Handler:    _RecommendationService_BuildSquad_Handler,
+		},
+		{
+			MethodName: "Statistics",
+			Handler:    _RecommendationService_Statistics_Handler,
+		},
+		{
+			MethodName: "Reindex",
+			Handler:    _RecommendationService_Reindex_Handler,
+		},
+		{
+			MethodName: "ReindexStatus",
+			Handler:    _RecommendationService_ReindexStatus_Handler,
+		},
+		{
+			MethodName: "Rollback",
+			Handler:    _RecommendationService_Rollback_Handler,
+		},
+		{
+			MethodName: "Tenants",
+			Handler:    _RecommendationService_Tenants_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "pkg/proto/server/server.proto",